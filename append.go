@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// lastPart returns the index of the highest-numbered part in dir named the
+// way a split with this prefix, extension and padding names them, or 0 if
+// there is none.
+func lastPart(dir, prefix, ext string, pad int) (int, error) {
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	last := 0
+	for _, e := range entries {
+		digits, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		if digits, ok = strings.CutSuffix(digits, "."+ext); !ok {
+			continue
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil || n < 1 || fmt.Sprintf("%0*d", pad, n) != digits {
+			continue
+		}
+		last = max(last, n)
+	}
+	return last, nil
+}
+
+// measurePart counts the lines and bytes of the part at path and reports
+// whether it ends with a newline, as an empty part counts.
+func measurePart(path string) (lines, size int64, endsLine bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	defer f.Close()
+	buf := make([]byte, 64*1024)
+	last := byte('\n')
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+			size += int64(n)
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			return lines, size, last == '\n', nil
+		}
+		if err != nil {
+			return 0, 0, false, err
+		}
+	}
+}
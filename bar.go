@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/basemax/filesplitter/splitter"
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	barWidth    = 30
+	barInterval = 200 * time.Millisecond
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// progressBar draws a single self-updating status line on the terminal.
+// Log lines are printed above it by clearing and redrawing the bar.
+type progressBar struct {
+	mu    sync.Mutex
+	start time.Time
+	line  string
+	frame int
+}
+
+// stdoutIsTerminal reports whether progress can be drawn in place.
+func stdoutIsTerminal() bool {
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+func newProgressBar() *progressBar {
+	return &progressBar{start: time.Now()}
+}
+
+// update is the splitter progress callback.
+func (b *progressBar) update(p splitter.Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	elapsed := time.Since(b.start).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.BytesRead) / elapsed
+	}
+	if p.TotalBytes > 0 {
+		frac := min(float64(p.BytesRead)/float64(p.TotalBytes), 1)
+		filled := int(frac * barWidth)
+		eta := "--:--"
+		if rate > 0 {
+			eta = formatETA(time.Duration(float64(p.TotalBytes-p.BytesRead) / rate * float64(time.Second)))
+		}
+		b.line = fmt.Sprintf("⏳ [%s%s] %5.1f%%  %.1f MB/s  %s / %s  part %d  ETA %s",
+			strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled), frac*100,
+			rate/(1024*1024), formatMB(p.BytesRead), formatMB(p.TotalBytes), p.Part, eta)
+	} else {
+		b.frame = (b.frame + 1) % len(spinnerFrames)
+		b.line = fmt.Sprintf("%s %s  %d lines  %.1f MB/s  part %d",
+			spinnerFrames[b.frame], formatMB(p.BytesRead), p.Lines, rate/(1024*1024), p.Part)
+	}
+	fmt.Print("\r\033[K" + b.line)
+}
+
+// above runs print with the bar cleared, then redraws the bar below it.
+func (b *progressBar) above(print func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.line != "" {
+		fmt.Print("\r\033[K")
+	}
+	print()
+	if b.line != "" {
+		fmt.Print(b.line)
+	}
+}
+
+// clear removes the bar for good.
+func (b *progressBar) clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.line != "" {
+		fmt.Print("\r\033[K")
+		b.line = ""
+	}
+}
+
+func formatMB(n int64) string {
+	return fmt.Sprintf("%.2f MB", float64(n)/(1024*1024))
+}
+
+func formatETA(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
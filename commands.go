@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// command is a filesplitter subcommand.
+type command struct {
+	name    string
+	args    string // synopsis after the command name
+	summary string
+	run     func(args []string) error
+}
+
+// commands is filled in by init because the commands' usage refers back
+// to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"split", "-in FILE [-lines N | -size SIZE | -bytes SIZE | -pattern RE] [flags]", "Split a file into parts", func(args []string) error { return runSplit(args, false) }},
+		{"merge", "-out FILE [flags] [PART...]", "Concatenate parts back into one file", runMerge},
+		{"verify", "-report FILE | -verify-file FILE [flags]", "Check parts against the sizes and checksums in a -report or -verify-file", runVerify},
+		{"info", "-in FILE [flags]", "Describe a file: size, lines and detected format", runInfo},
+		{"config", "print [command] [flags]", "Print a command's settings merged from the config file, environment and flags", runConfig},
+		{"completion", "bash|zsh|fish", "Print a shell completion script", runCompletion},
+		{"version", "", "Print version and build information", runVersion},
+	}
+}
+
+func main() {
+	err := dispatch(os.Args[1:])
+	if err == nil || errors.Is(err, errHelp) {
+		return
+	}
+	logError(err.Error())
+	os.Exit(exitCode(err))
+}
+
+// dispatch runs the command named by args[0]. Flags without a command are
+// the legacy form of split.
+func dispatch(args []string) error {
+	if len(args) == 0 {
+		printCommands()
+		return usageErrorf("no command given")
+	}
+	if strings.HasPrefix(args[0], "-") {
+		return runSplit(args, true)
+	}
+	if args[0] == "help" {
+		if len(args) < 2 {
+			printCommands()
+			return nil
+		}
+		if c := findCommand(args[1]); c != nil {
+			return c.run([]string{"-h"})
+		}
+		return usageErrorf("unknown command %q", args[1])
+	}
+	if c := findCommand(args[0]); c != nil {
+		return c.run(args[1:])
+	}
+	printCommands()
+	return usageErrorf("unknown command %q", args[0])
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func printCommands() {
+	fmt.Fprintf(os.Stderr, "Usage: filesplitter <command> [flags]\n\nCommands:\n")
+	width := 0
+	for _, c := range commands {
+		width = max(width, len(c.name))
+	}
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-*s  %s\n", width, c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'filesplitter help <command>' for the flags of a command.\n")
+	printExitCodes(os.Stderr)
+}
+
+// hiddenFlags are accepted but left out of the -h output.
+var hiddenFlags = map[string]bool{"inject-error": true}
+
+// newFlagSet returns the flag set of the named command with focused usage.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		c := findCommand(name)
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: filesplitter %s %s\n\n%s.\n\nFlags:\n", name, c.args, c.summary)
+		visible := flag.NewFlagSet(name, flag.ContinueOnError)
+		visible.SetOutput(out)
+		fs.VisitAll(func(f *flag.Flag) {
+			if !hiddenFlags[f.Name] {
+				visible.Var(f.Value, f.Name, f.Usage)
+				visible.Lookup(f.Name).DefValue = f.DefValue
+			}
+		})
+		visible.PrintDefaults()
+		printExitCodes(out)
+	}
+	return fs
+}
+
+// errHelp means -h was given and usage has been printed.
+var errHelp = errors.New("help requested")
+
+// parseFlags parses args into fs and then fills the flags args left
+// unset from the config file and environment. The flag package has already
+// printed the problem and usage when parsing fails.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if collectFlags != nil {
+		collectFlags(fs)
+		return errHelp
+	}
+	err := fs.Parse(args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return errHelp
+	case err != nil:
+		return usageErrorf("%v", err)
+	}
+	if fs.NArg() > 0 && fs.Name() != "merge" {
+		return usageErrorf("unexpected argument %q", fs.Arg(0))
+	}
+	sources, err := applyDefaults(fs)
+	if err != nil {
+		return err
+	}
+	if printConfig {
+		printSettings(fs, sources)
+		return errHelp
+	}
+	return nil
+}
+
+// commonFlags are the flags every command shares.
+type commonFlags struct {
+	config    *string
+	quiet     *bool
+	noColor   *bool
+	plain     *bool
+	outputDir *string
+	logFile   *string
+	logFormat *string
+}
+
+func addCommonFlags(fs *flag.FlagSet) *commonFlags {
+	return &commonFlags{
+		config:    fs.String("config", "", "Read default flag values from this TOML file (default: ~/"+defaultConfigFile+" if it exists)"),
+		quiet:     fs.Bool("q", false, "Quiet mode: nothing on stdout, only warnings and errors on stderr"),
+		noColor:   fs.Bool("no-color", false, "Disable colored output (also honors NO_COLOR)"),
+		plain:     fs.Bool("plain", false, "Plain output: no color, emoji or banner; [INFO]/[WARN]/[ERROR] tags"),
+		outputDir: fs.String("outdir", ".", "Directory of the parts; split also writes to s3://bucket/prefix or sftp://[user@]host[:port]/path"),
+		logFile:   fs.String("log-file", "", "Append an uncolored, timestamped copy of every log event to this file, even with -q"),
+		logFormat: fs.String("log-format", formatPretty, "Log output format: pretty, plain or json"),
+	}
+}
+
+// setup configures the console from the common flags, runs validate, if
+// any, and only then opens the log file so that invalid flags touch no file.
+func (c *commonFlags) setup(validate func() error) error {
+	if *c.plain {
+		*c.logFormat = formatPlain
+	}
+	if err := con.setup(*c.logFormat, *c.noColor); err != nil {
+		return err
+	}
+	con.quiet = *c.quiet
+	if validate != nil {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	if *c.logFile != "" {
+		if err := con.openLogFile(*c.logFile); err != nil {
+			return &splitter.OutputError{Part: *c.logFile, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// enumFlags lists the values of flags that take one of a fixed set.
+var enumFlags = map[string][]string{
+	"log-format":             {formatPretty, formatPlain, formatJSON},
+	"max-line-length-action": {"warn", "error"},
+	"decode":                 {"base64", "hex"},
+}
+
+// dirFlags take a directory; pathFlags and -config take a file.
+var dirFlags = map[string]bool{"outdir": true}
+
+// completionShells are the shells "completion" writes scripts for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// collectFlags, when set, receives each command's flag set in parseFlags
+// instead of the command running. Completion uses it to read the flags
+// every command actually registers.
+var collectFlags func(fs *flag.FlagSet)
+
+// commandFlags returns the visible flags of every command that has a flag
+// set, keyed by command name.
+func commandFlags() map[string][]*flag.Flag {
+	all := map[string][]*flag.Flag{}
+	collectFlags = func(fs *flag.FlagSet) {
+		fs.VisitAll(func(f *flag.Flag) {
+			if !hiddenFlags[f.Name] {
+				all[fs.Name()] = append(all[fs.Name()], f)
+			}
+		})
+	}
+	defer func() { collectFlags = nil }()
+	for _, c := range commands {
+		if c.name != "config" && c.name != "completion" && c.name != "version" {
+			c.run(nil)
+		}
+	}
+	return all
+}
+
+// runCompletion implements "completion bash|zsh|fish".
+func runCompletion(args []string) error {
+	if len(args) != 1 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		c := findCommand("completion")
+		fmt.Fprintf(os.Stderr, "Usage: filesplitter completion %s\n\n%s.\n", c.args, c.summary)
+		if len(args) == 1 {
+			return errHelp
+		}
+		return usageErrorf("expected one of %s", strings.Join(completionShells, ", "))
+	}
+	flags := commandFlags()
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(os.Stdout, flags)
+	case "zsh":
+		fmt.Fprintln(os.Stdout, "#compdef filesplitter")
+		fmt.Fprintln(os.Stdout, "autoload -U +X bashcompinit && bashcompinit")
+		writeBashCompletion(os.Stdout, flags)
+	case "fish":
+		writeFishCompletion(os.Stdout, flags)
+	default:
+		return usageErrorf("unknown shell %q: expected one of %s", args[0], strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	return names
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// sortedCommands returns the names in flags in a stable order.
+func sortedCommands(flags map[string][]*flag.Flag) []string {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeBashCompletion(w io.Writer, flags map[string][]*flag.Flag) {
+	fmt.Fprintf(w, `# bash completion for filesplitter, generated by 'filesplitter completion bash'
+_filesplitter() {
+	local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+	local cmd=${COMP_WORDS[1]} flags=""
+	COMPREPLY=()
+	if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then
+		COMPREPLY=($(compgen -W "%s help" -- "$cur"))
+		return
+	fi
+	case $cmd in
+	-*) cmd=split ;;
+	help) COMPREPLY=($(compgen -W "%[1]s" -- "$cur")); return ;;
+	completion) COMPREPLY=($(compgen -W "%[2]s" -- "$cur")); return ;;
+	config)
+		if [[ $COMP_CWORD -eq 2 ]]; then COMPREPLY=($(compgen -W "print" -- "$cur")); return; fi
+		if [[ $COMP_CWORD -eq 3 && $cur != -* ]]; then COMPREPLY=($(compgen -W "%[3]s" -- "$cur")); return; fi
+		cmd=${COMP_WORDS[3]}
+		[[ $cmd == -* || $COMP_CWORD -eq 3 ]] && cmd=split
+		;;
+	esac
+	case $prev in
+`, strings.Join(commandNames(), " "), strings.Join(completionShells, " "), strings.Join(sortedCommands(flags), " "))
+
+	var files, dirs []string
+	enums := map[string]bool{}
+	for _, name := range sortedCommands(flags) {
+		for _, f := range flags[name] {
+			switch {
+			case dirFlags[f.Name]:
+				dirs = appendUnique(dirs, "-"+f.Name)
+			case pathFlags[f.Name] || f.Name == "config":
+				files = appendUnique(files, "-"+f.Name)
+			case enumFlags[f.Name] != nil && !enums[f.Name]:
+				enums[f.Name] = true
+				fmt.Fprintf(w, "\t-%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f.Name, strings.Join(enumFlags[f.Name], " "))
+			}
+		}
+	}
+	fmt.Fprintf(w, "\t%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(files, "|"))
+	fmt.Fprintf(w, "\t%s) COMPREPLY=($(compgen -d -- \"$cur\")); return ;;\n", strings.Join(dirs, "|"))
+	fmt.Fprint(w, "\tesac\n\tcase $cmd in\n")
+	for _, name := range sortedCommands(flags) {
+		var valued, all []string
+		for _, f := range flags[name] {
+			all = append(all, "-"+f.Name)
+			if !isBoolFlag(f) {
+				valued = append(valued, "-"+f.Name)
+			}
+		}
+		fmt.Fprintf(w, "\t%s)\n\t\tcase $prev in %s) return ;; esac\n\t\tflags=%q ;;\n", name, strings.Join(valued, "|"), strings.Join(all, " "))
+	}
+	fmt.Fprint(w, `	esac
+	COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+}
+complete -F _filesplitter filesplitter
+`)
+}
+
+func writeFishCompletion(w io.Writer, flags map[string][]*flag.Flag) {
+	fmt.Fprintln(w, "# fish completion for filesplitter, generated by 'filesplitter completion fish'")
+	fmt.Fprintln(w, "complete -c filesplitter -f")
+	for _, c := range commands {
+		fmt.Fprintf(w, "complete -c filesplitter -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.summary))
+	}
+	fmt.Fprintf(w, "complete -c filesplitter -n '__fish_seen_subcommand_from help' -a %s\n", fishQuote(strings.Join(commandNames(), " ")))
+	fmt.Fprintf(w, "complete -c filesplitter -n '__fish_seen_subcommand_from completion' -a %s\n", fishQuote(strings.Join(completionShells, " ")))
+	fmt.Fprintln(w, "complete -c filesplitter -n '__fish_seen_subcommand_from config' -a print")
+	for _, name := range sortedCommands(flags) {
+		cond := fishQuote("__fish_seen_subcommand_from " + name)
+		for _, f := range flags[name] {
+			line := fmt.Sprintf("complete -c filesplitter -n %s -o %s -d %s", cond, f.Name, fishQuote(f.Usage))
+			switch {
+			case enumFlags[f.Name] != nil:
+				line += " -x -a " + fishQuote(strings.Join(enumFlags[f.Name], " "))
+			case dirFlags[f.Name]:
+				line += " -x -a '(__fish_complete_directories)'"
+			case pathFlags[f.Name] || f.Name == "config":
+				line += " -r -F"
+			case !isBoolFlag(f):
+				line += " -x"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// fishQuote single-quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
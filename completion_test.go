@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeCompletion writes the script "completion shell" generates to a
+// file in dir and returns its path.
+func writeCompletion(t *testing.T, dir, shell string) string {
+	t.Helper()
+	res := runCLI(t, dir, nil, "completion", shell)
+	if res.code != exitOK {
+		t.Fatalf("completion %s: exit code %d\nstderr: %s", shell, res.code, res.stderr)
+	}
+	path := filepath.Join(dir, "filesplitter."+shell)
+	if err := os.WriteFile(path, []byte(res.stdout), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestCompletionSyntax checks each generated script with its shell's
+// syntax check, for the shells that are installed.
+func TestCompletionSyntax(t *testing.T) {
+	checks := map[string][]string{"bash": {"-n"}, "zsh": {"-n"}, "fish": {"--no-execute"}}
+	for _, shell := range completionShells {
+		t.Run(shell, func(t *testing.T) {
+			bin, err := exec.LookPath(shell)
+			if err != nil {
+				t.Skipf("%s is not installed", shell)
+			}
+			path := writeCompletion(t, t.TempDir(), shell)
+			if out, err := exec.Command(bin, append(checks[shell], path)...).CombinedOutput(); err != nil {
+				t.Errorf("%s %v: %v\n%s", shell, checks[shell], err, out)
+			}
+		})
+	}
+}
+
+// TestBashCompletion runs the bash completion function on command lines
+// and checks what it offers.
+func TestBashCompletion(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+	path := writeCompletion(t, t.TempDir(), "bash")
+	tests := []struct {
+		line string   // the words typed so far, the last one being completed
+		want []string // among the completions
+		not  []string // not among them
+	}{
+		{"filesplitter ", []string{"split", "merge", "verify", "completion"}, nil},
+		{"filesplitter sp", []string{"split"}, []string{"merge"}},
+		{"filesplitter split -pat", []string{"-pattern"}, []string{"-lines"}},
+		{"filesplitter split -size-al", []string{"-size-align-lines"}, nil},
+		{"filesplitter -li", []string{"-lines"}, nil},
+		{"filesplitter merge -o", []string{"-out", "-outdir"}, []string{"-lines"}},
+		{"filesplitter split -log-format ", []string{"pretty", "plain", "json"}, nil},
+		{"filesplitter split -lines ", nil, []string{"-lines"}},
+		{"filesplitter completion ", []string{"bash", "zsh", "fish"}, nil},
+	}
+	for _, tt := range tests {
+		words := strings.Split(tt.line, " ")
+		script := `source "$1"; shift; COMP_WORDS=("$@"); COMP_CWORD=$(($# - 1)); _filesplitter; printf '%s\n' "${COMPREPLY[@]}"`
+		cmd := exec.Command(bash, append([]string{"-c", script, "bash", path}, words...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Errorf("%q: %v\n%s", tt.line, err, out)
+			continue
+		}
+		got := map[string]bool{}
+		for _, w := range strings.Fields(string(out)) {
+			got[w] = true
+		}
+		for _, w := range tt.want {
+			if !got[w] {
+				t.Errorf("%q: %q not offered in %q", tt.line, w, out)
+			}
+		}
+		for _, w := range tt.not {
+			if got[w] {
+				t.Errorf("%q: %q offered", tt.line, w)
+			}
+		}
+	}
+}
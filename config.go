@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultConfigFile is read from the home directory when -config is not
+// given and the file exists.
+const defaultConfigFile = ".filesplitter.toml"
+
+// envPrefix starts the environment variables that set flags, e.g.
+// FILESPLITTER_OUTDIR for -outdir.
+const envPrefix = "FILESPLITTER_"
+
+// pathFlags hold paths, which are resolved against the config file's
+// directory when given relative in the file.
+var pathFlags = map[string]bool{
+	"in": true, "out": true, "outdir": true, "log-file": true,
+	"report": true, "verify-file": true, "done-file": true, "state-file": true, "event-pipe": true,
+	"cpuprofile": true, "memprofile": true,
+}
+
+// configEntry is one key = value line of a config file.
+type configEntry struct {
+	key, value string
+	line       int
+}
+
+// configFile is a parsed config file. Keys before any section apply to
+// split; [split], [merge], [verify] and [info] hold a command's keys.
+type configFile struct {
+	path     string
+	sections map[string][]configEntry
+}
+
+// printConfig is set by "config print": parseFlags then prints the merged
+// settings of the command instead of running it.
+var printConfig bool
+
+// runConfig implements "config print [command] [flags]".
+func runConfig(args []string) error {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		c := findCommand("config")
+		fmt.Fprintf(os.Stderr, "Usage: filesplitter config %s\n\n%s.\n", c.args, c.summary)
+		return errHelp
+	}
+	if len(args) == 0 || args[0] != "print" {
+		return usageErrorf("usage: filesplitter config print [command] [flags]")
+	}
+	args = args[1:]
+	name := "split"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	c := findCommand(name)
+	if c == nil || c.name == "config" || c.name == "completion" || c.name == "version" {
+		return usageErrorf("unknown command %q", name)
+	}
+	printConfig = true
+	return c.run(args)
+}
+
+// setting is a default flag value from the config file or environment.
+type setting struct {
+	value  string
+	source string // "path:line" or "env NAME"
+	line   int    // in the config file; 0 for the environment
+	env    bool
+}
+
+// applyDefaults sets the flags of fs that were not given on the command
+// line from the config file, then from FILESPLITTER_* environment
+// variables, which take precedence over the file. Defaults that a flag on
+// the command line rules out are dropped; see dropOverridden. It returns
+// where each flag's value came from.
+func applyDefaults(fs *flag.FlagSet) (map[string]string, error) {
+	sources := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { sources[f.Name] = "flag" })
+	defaults := map[string]setting{}
+	var problems []string
+	path, explicit := fs.Lookup("config").Value.String(), true
+	if path == "" {
+		path, explicit = defaultConfigPath(), false
+	}
+	if path != "" {
+		cfg, err := loadConfig(path)
+		if err != nil {
+			if explicit || !os.IsNotExist(err) {
+				return nil, usageErrorf("config: %v", err)
+			}
+		} else {
+			problems = cfg.collect(fs, sources, defaults)
+		}
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v, ok := os.LookupEnv(name); ok && sources[f.Name] != "flag" && f.Name != "config" {
+			defaults[f.Name] = setting{value: v, source: "env " + name, env: true}
+		}
+	})
+	dropOverridden(defaults, sources)
+
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	// File values first and in line order, as their problems are reported.
+	sort.Slice(names, func(i, j int) bool {
+		a, b := defaults[names[i]], defaults[names[j]]
+		if a.env != b.env {
+			return b.env
+		}
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		s := defaults[name]
+		if err := fs.Set(name, s.value); err != nil {
+			if s.env && len(problems) == 0 {
+				return nil, usageErrorf("invalid %s %q: %v", strings.TrimPrefix(s.source, "env "), s.value, err)
+			}
+			if !s.env {
+				problems = append(problems, fmt.Sprintf("%s: invalid %s %q: %v", s.source, name, s.value, err))
+			}
+			continue
+		}
+		sources[name] = s.source
+	}
+	if len(problems) > 0 {
+		return nil, usageErrorf("config:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return sources, nil
+}
+
+// dropOverridden removes the defaults that conflict with a flag given on
+// the command line, which wins, and then those that need a flag given
+// nowhere, which would otherwise fail a command line that is valid on its
+// own. Defaults that conflict with each other are left for validation to
+// report.
+func dropOverridden(defaults map[string]setting, sources map[string]string) {
+	canonical := func(name string) string {
+		if n, ok := flagAliases[name]; ok {
+			return n
+		}
+		return name
+	}
+	given := map[string]bool{}
+	for name, src := range sources {
+		if src == "flag" {
+			given[canonical(name)] = true
+		}
+	}
+	for name := range defaults {
+		n := canonical(name)
+		for _, c := range flagConflicts {
+			if (c.a == n && given[c.b]) || (c.b == n && given[c.a]) {
+				delete(defaults, name)
+				break
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		have := maps.Clone(given)
+		for name := range defaults {
+			have[canonical(name)] = true
+		}
+		for name := range defaults {
+			n := canonical(name)
+			for _, r := range flagRequires {
+				if r.flag == n && !have[r.needs] {
+					delete(defaults, name)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+}
+
+// defaultConfigPath is ~/.filesplitter.toml, or "" without a home
+// directory.
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, defaultConfigFile)
+}
+
+// loadConfig parses the TOML subset used for config files: [section]
+// headers and key = value lines whose value is a string, number or boolean.
+func loadConfig(path string) (*configFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg := &configFile{path: path, sections: map[string][]configEntry{}}
+	section := "split"
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name, ok := strings.CutSuffix(stripComment(line), "]")
+			name = strings.TrimSpace(strings.TrimPrefix(name, "["))
+			if !ok || findCommand(name) == nil || name == "config" {
+				return nil, fmt.Errorf("%s:%d: unknown section %s", path, n, line)
+			}
+			section = name
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		value, err := configValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		cfg.sections[section] = append(cfg.sections[section], configEntry{strings.TrimSpace(key), value, n})
+	}
+	return cfg, sc.Err()
+}
+
+// configValue decodes a TOML string, number or boolean to flag syntax.
+func configValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		end := strings.LastIndex(raw, `"`)
+		if end == 0 || strings.TrimSpace(stripComment(raw[end+1:])) != "" {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return strconv.Unquote(raw[:end+1])
+	case strings.HasPrefix(raw, "'"):
+		end := strings.LastIndex(raw, "'")
+		if end == 0 || strings.TrimSpace(stripComment(raw[end+1:])) != "" {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return raw[1:end], nil
+	}
+	v := strings.TrimSpace(stripComment(raw))
+	if v == "" || strings.HasPrefix(v, "[") || strings.HasPrefix(v, "{") {
+		return "", fmt.Errorf("expected a string, number or boolean, got %q", raw)
+	}
+	return strings.ReplaceAll(v, "_", ""), nil
+}
+
+// stripComment removes a trailing # comment from an unquoted value.
+func stripComment(s string) string {
+	if i := strings.Index(s, "#"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// collect adds the values of the section for fs's command to defaults,
+// except those of flags already in sources, and returns a problem for
+// each key that is not a flag of the command. Other commands' sections are
+// left alone.
+func (c *configFile) collect(fs *flag.FlagSet, sources map[string]string, defaults map[string]setting) []string {
+	var problems []string
+	for _, e := range c.sections[fs.Name()] {
+		f := fs.Lookup(e.key)
+		if f == nil || e.key == "config" {
+			problems = append(problems, fmt.Sprintf("%s:%d: unknown key %q for %s", c.path, e.line, e.key, fs.Name()))
+			continue
+		}
+		if sources[e.key] == "flag" {
+			continue
+		}
+		v := e.value
+		if pathFlags[e.key] && v != "-" && v != "" && !filepath.IsAbs(v) {
+			v = filepath.Join(filepath.Dir(c.path), v)
+		}
+		defaults[e.key] = setting{value: v, source: fmt.Sprintf("%s:%d", c.path, e.line), line: e.line}
+	}
+	return problems
+}
+
+// printSettings writes every flag of fs with its value and where the value
+// came from, in TOML so the output can seed a config file.
+func printSettings(fs *flag.FlagSet, sources map[string]string) {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlags[f.Name] && f.Name != "config" {
+			names = append(names, f.Name)
+		}
+	})
+	sort.Strings(names)
+	fmt.Printf("[%s]\n", fs.Name())
+	for _, name := range names {
+		f := fs.Lookup(name)
+		source := sources[name]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Printf("%s = %s # %s\n", name, tomlValue(f), source)
+	}
+}
+
+// tomlValue renders the current value of f as a TOML value.
+func tomlValue(f *flag.Flag) string {
+	v := f.Value.String()
+	if g, ok := f.Value.(flag.Getter); ok {
+		switch g.Get().(type) {
+		case bool, int, int64, uint, uint64, float64:
+			return v
+		}
+	}
+	return strconv.Quote(v)
+}
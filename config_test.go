@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestConfigPrecedence checks that config file and environment values
+// yield to the command-line flags they conflict with, and still conflict
+// with each other.
+func TestConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	input := writeInput(t, dir, 10) // "line N\n" is 7 or 8 bytes
+	config := filepath.Join(dir, "c.toml")
+	if err := os.WriteFile(config, []byte("lines = 3\nalign-lines = 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		env   []string
+		args  []string
+		parts int    // expected parts when want is ""
+		want  string // in stderr of a usage error
+	}{
+		{"config alone", nil, []string{"-config", config}, 5, ""}, // 2 lines each, on the -align-lines grid
+		// -align-lines is dropped with the -lines it needs.
+		{"flag overrides config", nil, []string{"-config", config, "-bytes", "40B"}, 2, ""},
+		{"flag overrides env", []string{"FILESPLITTER_SIZE=1MB"}, []string{"-lines", "5"}, 2, ""},
+		{"env overrides config", []string{"FILESPLITTER_LINES=5"}, []string{"-config", config}, 3, ""},
+		{"env conflicts with config", []string{"FILESPLITTER_BYTES=40B"}, []string{"-config", config}, 0, "-bytes and -lines cannot be used together"},
+		{"flag conflicts with flag", nil, []string{"-lines", "3", "-bytes", "40B"}, 0, "-bytes and -lines cannot be used together"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(dir, "out", string(rune('a'+i)))
+			args := append([]string{"split", "-in", input, "-outdir", out}, tt.args...)
+			cmd := cliCommand(dir, args...)
+			cmd.Env = append(cmd.Env, tt.env...)
+			var stderr strings.Builder
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+			if tt.want != "" {
+				if err == nil || !strings.Contains(stderr.String(), tt.want) {
+					t.Fatalf("got %v, want an error with %q\nstderr: %s", err, tt.want, stderr.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%v\nstderr: %s", err, stderr.String())
+			}
+			names, _ := filepath.Glob(filepath.Join(out, "part*"))
+			if len(names) != tt.parts {
+				t.Errorf("got %d parts %v, want %d", len(names), names, tt.parts)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/basemax/filesplitter/splitter"
+	"github.com/mattn/go-isatty"
+)
+
+// estimateSample returns the start of the input for
+// splitter.EstimateParts, reading a regular file in place or peeking
+// through input otherwise. The returned reader replaces input.
+func estimateSample(file *os.File, input io.Reader) ([]byte, io.Reader) {
+	if file != nil {
+		if stat, err := file.Stat(); err == nil && stat.Mode().IsRegular() {
+			sample := make([]byte, splitter.DetectSampleSize)
+			n, _ := file.ReadAt(sample, 0)
+			return sample[:n], input
+		}
+	}
+	br := bufio.NewReaderSize(input, splitter.DetectSampleSize)
+	sample, _ := br.Peek(splitter.DetectSampleSize)
+	return sample, br
+}
+
+// confirmParts asks on the terminal before a split expected to create more
+// than limit parts in dir. Without a terminal to ask on, -yes is required.
+func confirmParts(estimate, limit int64, dir string, yes bool) error {
+	if estimate <= limit || yes {
+		return nil
+	}
+	fd := os.Stdin.Fd()
+	if !isatty.IsTerminal(fd) && !isatty.IsCygwinTerminal(fd) {
+		return usageErrorf("about to create ~%d files in %s, over -confirm-above %d: pass -yes to proceed", estimate, dir, limit)
+	}
+	fmt.Fprintf(os.Stderr, "About to create ~%d files in %s — continue? [y/N] ", estimate, dir)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return usageErrorf("aborted: nothing was created")
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/basemax/filesplitter/splitter"
+	"github.com/fatih/color"
+)
+
+// Log formats accepted by -log-format.
+const (
+	formatPretty = "pretty" // colored, emoji-prefixed messages
+	formatPlain  = "plain"  // [INFO]-style tags, no color or emoji
+	formatJSON   = "json"   // one JSON object per event
+)
+
+// console decides once, at startup, how CLI messages look. Pretty and
+// plain output show a human-readable text; JSON output shows a fixed
+// message with the event's values as separate fields. Info events go to
+// stdout, warnings and errors to stderr.
+type console struct {
+	format string
+	json   *slog.Logger // stdout
+	errs   *slog.Logger // stderr
+	quiet  bool         // drop info events, leaving stdout empty
+	file   io.Writer    // uncolored copy of every event, see openLogFile
+}
+
+// levelSuccess is an info event shown in the pretty format's success style.
+const levelSuccess = slog.LevelInfo + 1
+
+var con = &console{format: formatPretty}
+
+// setup applies the output format and color choice. Color is also disabled
+// when NO_COLOR is set or stdout is not a terminal.
+func (c *console) setup(format string, noColor bool) error {
+	switch format {
+	case formatPretty, formatPlain:
+	case formatJSON:
+		c.json = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+		c.errs = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	default:
+		return usageErrorf("invalid -log-format %q: expected pretty, plain or json", format)
+	}
+	c.format = format
+	if noColor || format != formatPretty || os.Getenv("NO_COLOR") != "" || !stdoutIsTerminal() {
+		color.NoColor = true
+	}
+	return nil
+}
+
+// decorated reports whether the banner and progress bar may be shown:
+// only in pretty, non-quiet output to a terminal.
+func (c *console) decorated() bool {
+	return c.format == formatPretty && !c.quiet && stdoutIsTerminal()
+}
+
+// openLogFile appends every later event to path, whatever the console
+// format and -q, starting with a header for this run.
+func (c *console) openLogFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	c.file = f
+	_, err = fmt.Fprintf(f, "%s ===== filesplitter %s started: %s\n",
+		time.Now().Format(time.RFC3339), version, strings.Join(os.Args[1:], " "))
+	return err
+}
+
+// emit writes one event. text is shown by pretty and plain output and the
+// log file; JSON output uses msg, or text without its emoji when msg is
+// empty, plus args.
+func (c *console) emit(level slog.Level, text, msg string, args ...any) {
+	if c.file != nil {
+		fmt.Fprintf(c.file, "%s %s %s\n", time.Now().Format(time.RFC3339), levelTag(level), stripEmoji(text))
+	}
+	if c.quiet && level < slog.LevelWarn {
+		return
+	}
+	out := os.Stdout
+	if level >= slog.LevelWarn {
+		out = os.Stderr
+	}
+	switch c.format {
+	case formatJSON:
+		if msg == "" {
+			msg = stripEmoji(text)
+		}
+		if level == levelSuccess {
+			level = slog.LevelInfo
+		}
+		l := c.json
+		if out == os.Stderr {
+			l = c.errs
+		}
+		l.Log(context.Background(), level, msg, args...)
+	case formatPlain:
+		fmt.Fprintf(out, "%s %s\n", levelTag(level), stripEmoji(text))
+	default:
+		switch {
+		case level >= slog.LevelError:
+			color.New(color.FgRed).Fprintf(out, "❌ %s\n", text)
+		case level >= slog.LevelWarn:
+			color.New(color.FgYellow).Fprintf(out, "⚠️  %s\n", text)
+		case level == levelSuccess:
+			color.New(color.FgCyan).Fprintf(out, "🎯 %s\n", text)
+		default:
+			color.New(color.FgGreen).Fprintf(out, "✅ %s\n", text)
+		}
+	}
+}
+
+func levelTag(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "[ERROR]"
+	case level >= slog.LevelWarn:
+		return "[WARN]"
+	default:
+		return "[INFO]"
+	}
+}
+
+// stripEmoji removes leading pictographs and the spacing after them.
+func stripEmoji(msg string) string {
+	return strings.TrimLeftFunc(msg, func(r rune) bool {
+		return unicode.Is(unicode.So, r) || unicode.IsSpace(r) || unicode.Is(unicode.Mn, r) || r == '️'
+	})
+}
+
+func logInfo(msg string)  { con.emit(slog.LevelInfo, msg, "") }
+func logError(msg string) { con.emit(slog.LevelError, msg, "") }
+func logWarn(msg string)  { con.emit(slog.LevelWarn, msg, "") }
+
+// logSummary reports a completed split, or what a dry run would have
+// written, as a block of totals taken from res. In JSON it is a single
+// "done" event whose fields are stable for log pipelines.
+func logSummary(res *splitter.Result, lineStats, dryRun bool) {
+	parts := keptParts(res)
+	var largest int64
+	for _, p := range res.Parts {
+		largest = max(largest, p.Bytes)
+	}
+	bytesPerSec, linesPerSec := res.Throughput()
+	if con.format != formatJSON {
+		block := []string{"🎉 Done!"}
+		row := func(label, format string, args ...any) {
+			block = append(block, fmt.Sprintf("   %-10s "+format, append([]any{label + ":"}, args...)...))
+		}
+		row("Input", "%s, %d lines", splitter.SizeString(res.BytesRead), res.Lines)
+		if dryRun {
+			block[0] = "🔍 Dry run, nothing was written."
+			row("Output", "%d parts would be created, largest %s", parts, splitter.SizeString(largest))
+		} else {
+			row("Output", "%d parts, %s written, largest %s", parts, splitter.SizeString(res.BytesWritten), splitter.SizeString(largest))
+		}
+		row("Elapsed", "%s, %.1f MB/s, %.0f lines/s", res.Elapsed.Round(time.Millisecond), bytesPerSec/(1024*1024), linesPerSec)
+		if res.Skipped() > 0 {
+			var why []string
+			if res.Duplicates > 0 {
+				why = append(why, fmt.Sprintf("%d near-duplicates", res.Duplicates))
+			}
+			if res.SampledOut > 0 {
+				why = append(why, fmt.Sprintf("%d sampled out", res.SampledOut))
+			}
+			row("Skipped", "%d lines (%s)", res.Skipped(), strings.Join(why, ", "))
+		}
+		if res.TruncatedLines > 0 {
+			row("Truncated", "%d long lines", res.TruncatedLines)
+		}
+		if res.PrunedParts > 0 {
+			row("Pruned", "%d empty parts", res.PrunedParts)
+		}
+		if res.PeakMemory > 0 {
+			row("Memory", "%s at peak", splitter.SizeString(int64(res.PeakMemory)))
+		}
+		con.emit(levelSuccess, strings.Join(block, "\n"), "")
+		if res.LongLines > 0 {
+			logWarn(fmt.Sprintf("%d lines were over the -max-line-length limit", res.LongLines))
+		}
+		if lineStats {
+			sum := splitter.SummarizeLineStats(res.Parts)
+			logInfo(fmt.Sprintf("📏 Line lengths over %d lines: min %d, max %d, mean %.2f, stddev %.2f",
+				sum.Lines, sum.Min, sum.Max, sum.Mean, sum.Stddev))
+		}
+		return
+	}
+	args := []any{
+		"parts", parts,
+		"dry_run", dryRun,
+		"largest_part_bytes", largest,
+		"pruned_parts", res.PrunedParts,
+		"sampled_out", res.SampledOut,
+		"skipped_lines", res.Skipped(),
+		"lines", res.Lines,
+		"bytes_read", res.BytesRead,
+		"bytes_written", res.BytesWritten,
+		"duplicates", res.Duplicates,
+		"long_lines", res.LongLines,
+		"truncated_lines", res.TruncatedLines,
+		"elapsed_ms", res.Elapsed.Milliseconds(),
+		"bytes_per_sec", bytesPerSec,
+		"lines_per_sec", linesPerSec,
+	}
+	if res.PeakMemory > 0 {
+		args = append(args, "peak_memory_bytes", res.PeakMemory)
+	}
+	if lineStats {
+		sum := splitter.SummarizeLineStats(res.Parts)
+		args = append(args, slog.Group("line_lengths",
+			"min", sum.Min, "max", sum.Max, "mean", sum.Mean, "stddev", sum.Stddev))
+	}
+	con.emit(slog.LevelInfo, fmt.Sprintf("Done: %d parts from %d lines", parts, res.Lines), "done", args...)
+}
+
+// logInterrupted reports how far an interrupted split got and what became
+// of its incomplete parts.
+func logInterrupted(e *splitter.CancelError) {
+	con.emit(slog.LevelWarn, fmt.Sprintf("Interrupted at byte %d, line %d: %d parts are complete", e.BytesRead, e.Lines, e.PartsCompleted),
+		"interrupted", "bytes_read", e.BytesRead, "lines", e.Lines, "parts_completed", e.PartsCompleted, "partial", e.Partial)
+	for _, name := range e.Partial {
+		con.emit(slog.LevelWarn, "Incomplete part left as "+name, "partial part", "file", name)
+	}
+}
+
+// printCounts writes the totals of a -count run to stdout, one
+// "name value" pair per line so scripts can pick out what they need, or
+// as one JSON object in JSON output. The brief form of -count-only has
+// only the lines and bytes.
+func printCounts(res *splitter.Result, brief bool) {
+	switch {
+	case brief && con.format == formatJSON:
+		json.NewEncoder(os.Stdout).Encode(struct {
+			Lines int64 `json:"lines"`
+			Bytes int64 `json:"bytes"`
+		}{res.Lines, res.BytesRead})
+	case brief:
+		fmt.Printf("Lines: %d, Bytes: %d\n", res.Lines, res.BytesRead)
+	case con.format == formatJSON:
+		json.NewEncoder(os.Stdout).Encode(struct {
+			Lines       int64 `json:"lines"`
+			Bytes       int64 `json:"bytes"`
+			LongestLine int64 `json:"longest_line"`
+			Parts       int   `json:"parts"`
+		}{res.Lines, res.BytesRead, res.LongestLine, keptParts(res)})
+	default:
+		fmt.Printf("lines %d\nbytes %d\nlongest_line %d\nparts %d\n", res.Lines, res.BytesRead, res.LongestLine, keptParts(res))
+	}
+}
+
+// printPlan writes the parts of a -dry-json run to stdout as a JSON
+// array.
+func printPlan(res *splitter.Result) error {
+	type plannedPart struct {
+		Name      string `json:"name"`
+		Bytes     int64  `json:"bytes"`
+		Lines     int64  `json:"lines"`
+		StartLine int64  `json:"start_line"`
+		EndLine   int64  `json:"end_line"`
+		Capture   string `json:"capture,omitempty"`
+	}
+	plan := make([]plannedPart, 0, len(res.Parts))
+	for _, p := range res.Parts {
+		plan = append(plan, plannedPart{p.Name, p.Bytes, p.Lines, p.StartLine, p.EndLine, p.Capture})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(plan); err != nil {
+		return &splitter.OutputError{Part: "stdout", Err: err}
+	}
+	return nil
+}
+
+// keptParts counts the parts of res that were not pruned.
+func keptParts(res *splitter.Result) int {
+	n := 0
+	for _, p := range res.Parts {
+		if !p.Pruned {
+			n++
+		}
+	}
+	return n
+}
+
+// cliLogger routes splitter log events to the console. When a progress
+// bar is active, events are printed above it. Pretty and plain output show
+// the event's "file" value after its message.
+type cliLogger struct {
+	bar *progressBar
+}
+
+func (l cliLogger) Info(msg string, args ...any)  { l.log(slog.LevelInfo, msg, args) }
+func (l cliLogger) Warn(msg string, args ...any)  { l.log(slog.LevelWarn, msg, args) }
+func (l cliLogger) Error(msg string, args ...any) { l.log(slog.LevelError, msg, args) }
+
+func (l cliLogger) log(level slog.Level, msg string, args []any) {
+	text := msg
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] == "file" {
+			text = fmt.Sprintf("%s: %v", msg, args[i+1])
+		}
+	}
+	emit := func() { con.emit(level, text, stripEmoji(msg), args...) }
+	if l.bar != nil {
+		l.bar.above(emit)
+		return
+	}
+	emit()
+}
+
+// partLog reports each part as it is closed, for -v and -vv.
+type partLog struct {
+	bar     *progressBar
+	total   int64 // input size, or 0 when unknown
+	reasons bool  // -vv: also say why each part was started
+	last    time.Time
+	lastEnd int64
+}
+
+func newPartLog(bar *progressBar, total int64, reasons bool) *partLog {
+	return &partLog{bar: bar, total: total, reasons: reasons, last: time.Now()}
+}
+
+// done logs a closed part with the input read so far and the read rate
+// since the part before it. With -parallel-hash a part is reported once
+// its checksum is done, so the rate is taken up to when it was closed.
+func (l *partLog) done(info splitter.PartInfo, stats splitter.PartStats) {
+	now := stats.Closed
+	if now.IsZero() {
+		now = time.Now()
+	}
+	rate := float64(stats.InputEnd-l.lastEnd) / (1024 * 1024) / max(now.Sub(l.last).Seconds(), 1e-6)
+	l.last, l.lastEnd = now, stats.InputEnd
+
+	text := fmt.Sprintf("📦 Closed part %d: %d lines, %s", info.Index, stats.Lines, splitter.SizeString(stats.Bytes))
+	args := []any{"part", info.Index, "file", info.Name, "lines", stats.Lines, "bytes", stats.Bytes, "bytes_read", stats.InputEnd, "mb_per_sec", rate}
+	if l.total > 0 {
+		pct := float64(stats.InputEnd) * 100 / float64(l.total)
+		text += fmt.Sprintf(", %.1f%% of input", pct)
+		args = append(args, "percent", pct)
+	}
+	text += fmt.Sprintf(", %.1f MB/s", rate)
+	if l.reasons {
+		text += fmt.Sprintf(" (reason: %s)", info.Reason)
+		args = append(args, "reason", info.Reason.String())
+	}
+	emit := func() { con.emit(slog.LevelInfo, text+": "+info.Name, "part closed", args...) }
+	if l.bar != nil {
+		l.bar.above(emit)
+		return
+	}
+	emit()
+}
+
+// logProgress is the CLI's progress callback.
+func logProgress(p splitter.Progress) {
+	args := []any{"bytes_read", p.BytesRead, "lines", p.Lines, "part", p.Part}
+	if p.TotalBytes > 0 {
+		con.emit(slog.LevelInfo, fmt.Sprintf("⏳ %.1f%% (%.2f / %.2f MB), %d lines, part %d",
+			float64(p.BytesRead)*100/float64(p.TotalBytes),
+			float64(p.BytesRead)/(1024*1024), float64(p.TotalBytes)/(1024*1024), p.Lines, p.Part),
+			"progress", append(args, "total_bytes", p.TotalBytes)...)
+		return
+	}
+	con.emit(slog.LevelInfo, fmt.Sprintf("⏳ %.2f MB, %d lines, part %d", float64(p.BytesRead)/(1024*1024), p.Lines, p.Part),
+		"progress", args...)
+}
+
+// printBanner prints the decorative banner in pretty, non-quiet output to
+// a terminal.
+func printBanner() {
+	if !con.decorated() {
+		return
+	}
+	color.Cyan(`
+📁 FileSplitter v%s by Max Base
+📦 Split massive files by lines, size, or pattern with style!
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+`, version)
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestCount(t *testing.T) {
+	dir := t.TempDir()
+	input := writeInput(t, dir, 10) // 9 lines of 7 bytes and one of 8
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-count-only"}, "Lines: 10, Bytes: 71\n"},
+		{[]string{"-count-only", "-log-format", "json"}, `{"lines":10,"bytes":71}` + "\n"},
+		{[]string{"-count"}, "lines 10\nbytes 71\nlongest_line 8\nparts 1\n"},
+		{[]string{"-count", "-lines", "4"}, "lines 10\nbytes 71\nlongest_line 8\nparts 3\n"},
+		{[]string{"-count", "-log-format", "json", "-lines", "4"}, `{"lines":10,"bytes":71,"longest_line":8,"parts":3}` + "\n"},
+	}
+	for _, tt := range tests {
+		res := runCLI(t, dir, nil, append([]string{"split", "-in", input}, tt.args...)...)
+		if res.code != exitOK || res.stdout != tt.want {
+			t.Errorf("%v: exit code %d, stdout %q; want %q\nstderr: %s", tt.args, res.code, res.stdout, tt.want, res.stderr)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// failedMarker is written next to the -done-file when a split fails, after
+// the Hadoop _SUCCESS/_FAILED convention.
+const failedMarker = "_FAILED"
+
+// clearMarkers removes markers left by an earlier run so watchers never
+// see a stale one while parts are being written.
+func clearMarkers(donePath string) {
+	os.Remove(donePath)
+	os.Remove(filepath.Join(filepath.Dir(donePath), failedMarker))
+}
+
+// writeDoneFile writes a summary of res to path once every part is closed.
+// It is written under a temporary name and renamed, so its appearance
+// means it is complete.
+func writeDoneFile(path string, res *splitter.Result) error {
+	names := make([]string, 0, len(res.Parts))
+	var pruned []string
+	for _, p := range res.Parts {
+		if p.Pruned {
+			pruned = append(pruned, filepath.Base(p.Name))
+		} else {
+			names = append(names, filepath.Base(p.Name))
+		}
+	}
+	b, err := json.MarshalIndent(struct {
+		Version      string    `json:"version"`
+		Parts        []string  `json:"parts"`
+		Pruned       []string  `json:"pruned,omitempty"`
+		Lines        int64     `json:"lines"`
+		BytesWritten int64     `json:"bytes_written"`
+		ElapsedMS    int64     `json:"elapsed_ms"`
+		Finished     time.Time `json:"finished"`
+	}{version, names, pruned, res.Lines, res.BytesWritten, res.Elapsed.Milliseconds(), time.Now()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// writeFailedMarker records splitErr in a _FAILED file next to donePath.
+func writeFailedMarker(donePath string, splitErr error) error {
+	return os.WriteFile(filepath.Join(filepath.Dir(donePath), failedMarker), []byte(splitErr.Error()+"\n"), 0o644)
+}
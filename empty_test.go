@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestEmptyInputFile checks that an empty input file leaves the output
+// directory empty with a warning, and that -allow-empty writes one empty
+// part.
+func TestEmptyInputFile(t *testing.T) {
+	dir := t.TempDir()
+	input := writeInput(t, dir, 0)
+	out := filepath.Join(dir, "out")
+	res := runCLI(t, dir, nil, "split", "-in", input, "-lines", "10", "-outdir", out)
+	if res.code != exitOK {
+		t.Fatalf("exit code %d, want %d\nstderr: %s", res.code, exitOK, res.stderr)
+	}
+	if !strings.Contains(res.stdout+res.stderr, "Input is empty, no parts created") {
+		t.Errorf("no empty input warning in:\n%s%s", res.stdout, res.stderr)
+	}
+	if names, _ := filepath.Glob(filepath.Join(out, "*")); len(names) != 0 {
+		t.Errorf("output holds %v, want nothing", names)
+	}
+
+	out = filepath.Join(dir, "allow")
+	res = runCLI(t, dir, nil, "split", "-in", input, "-lines", "10", "-outdir", out, "-allow-empty")
+	if res.code != exitOK {
+		t.Fatalf("-allow-empty exit code %d, want %d\nstderr: %s", res.code, exitOK, res.stderr)
+	}
+	names, _ := filepath.Glob(filepath.Join(out, "*"))
+	if len(names) != 1 || filepath.Base(names[0]) != "part001.txt" {
+		t.Fatalf("-allow-empty output holds %v, want only part001.txt", names)
+	}
+	if fi, err := os.Stat(names[0]); err != nil {
+		t.Error(err)
+	} else if fi.Size() != 0 {
+		t.Errorf("part001.txt is %d bytes, want it empty", fi.Size())
+	}
+}
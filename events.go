@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// eventPipe writes newline-delimited JSON events about a split to a FIFO
+// or file set up by an orchestrator. A nil eventPipe writes nothing.
+type eventPipe struct {
+	f    *os.File
+	path string
+}
+
+// pipeEvent is one event line. Fields that don't apply to an event are
+// left out.
+type pipeEvent struct {
+	Event string `json:"event"`
+	File  string `json:"file,omitempty"`
+	Part  int    `json:"part,omitempty"`
+	Path  string `json:"path,omitempty"`
+	Parts *int   `json:"parts,omitempty"`
+	Error string `json:"error,omitempty"`
+	TS    string `json:"ts"`
+}
+
+// openEventPipe opens an existing FIFO or file for events. It is never
+// created: without it, or without a reader on the FIFO, a warning is
+// logged and the split goes on without events.
+func openEventPipe(path string) *eventPipe {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|openNonblock, 0)
+	if err != nil {
+		logWarn(fmt.Sprintf("No events will be sent: %v", err))
+		return nil
+	}
+	return &eventPipe{f: f, path: path}
+}
+
+func (p *eventPipe) send(e pipeEvent) {
+	if p == nil || p.f == nil {
+		return
+	}
+	e.TS = time.Now().UTC().Format(time.RFC3339Nano)
+	line, _ := json.Marshal(e)
+	if _, err := p.f.Write(append(line, '\n')); err != nil {
+		// The reader went away; stop rather than warn on every event.
+		logWarn(fmt.Sprintf("No more events will be sent to %s: %v", p.path, err))
+		p.close()
+	}
+}
+
+func (p *eventPipe) start(file string) { p.send(pipeEvent{Event: "start", File: file}) }
+
+func (p *eventPipe) partReady(part int, path string) {
+	p.send(pipeEvent{Event: "part_ready", Part: part, Path: path})
+}
+
+func (p *eventPipe) done(parts int) { p.send(pipeEvent{Event: "done", Parts: &parts}) }
+
+func (p *eventPipe) failed(err error) { p.send(pipeEvent{Event: "failed", Error: err.Error()}) }
+
+func (p *eventPipe) close() {
+	if p != nil && p.f != nil {
+		p.f.Close()
+		p.f = nil
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// openNonblock is 0 where FIFOs can't be opened without blocking.
+const openNonblock = 0
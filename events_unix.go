@@ -0,0 +1,9 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// openNonblock makes opening a FIFO fail at once, instead of waiting, when
+// nothing is reading it.
+const openNonblock = syscall.O_NONBLOCK
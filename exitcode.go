@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// Exit codes returned by the CLI.
+const (
+	exitOK          = 0
+	exitUsage       = 1 // invalid flags or flag combinations
+	exitInput       = 2 // the input could not be opened or read
+	exitOutput      = 3 // a part could not be created, written or closed
+	exitVerify      = 4 // the output did not match what was expected
+	exitInterrupted = 5 // the split was cancelled by a signal
+)
+
+// exitCodeHelp describes each exit code for the -h output, in order.
+var exitCodeHelp = []struct {
+	code int
+	text string
+}{
+	{exitOK, "success"},
+	{exitUsage, "invalid flags or flag combinations, or a declined confirmation"},
+	{exitInput, "the input could not be opened or read"},
+	{exitOutput, "a part or other output file could not be created, written or closed"},
+	{exitVerify, "the output did not match what was expected (-expect-parts, verify)"},
+	{exitInterrupted, "interrupted by SIGINT or SIGTERM"},
+}
+
+// printExitCodes writes the exit code contract for usage output.
+func printExitCodes(w io.Writer) {
+	fmt.Fprintf(w, "\nExit codes:\n")
+	for _, e := range exitCodeHelp {
+		fmt.Fprintf(w, "  %d  %s\n", e.code, e.text)
+	}
+}
+
+// usageError is an invalid flag value or combination.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string { return e.msg }
+
+func usageErrorf(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// verifyError is a verify run that found parts not matching their report
+// or checksum file.
+type verifyError struct {
+	msg string
+}
+
+func (e *verifyError) Error() string { return e.msg }
+
+// exitCode maps err to the exit code for its failure class.
+func exitCode(err error) int {
+	var (
+		usageErr  *usageError
+		cancelErr *splitter.CancelError
+		countErr  *splitter.PartCountError
+		inputErr  *splitter.InputError
+		outputErr *splitter.OutputError
+		verifyErr *verifyError
+	)
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usageErr):
+		return exitUsage
+	case errors.As(err, &cancelErr):
+		return exitInterrupted
+	case errors.As(err, &countErr), errors.As(err, &verifyErr):
+		return exitVerify
+	case errors.As(err, &inputErr):
+		return exitInput
+	case errors.As(err, &outputErr):
+		return exitOutput
+	default:
+		return exitUsage
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	input := writeInput(t, dir, 100)
+	notDir := filepath.Join(dir, "afile")
+	if err := os.WriteFile(notDir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"success", []string{"split", "-in", input, "-lines", "30", "-outdir", filepath.Join(dir, "ok")}, exitOK},
+		{"no command", nil, exitUsage},
+		{"invalid flag", []string{"split", "-in", input, "-lines", "-3"}, exitUsage},
+		{"invalid regex", []string{"split", "-in", input, "-pattern", "["}, exitUsage},
+		{"missing input", []string{"split", "-in", filepath.Join(dir, "missing.txt"), "-lines", "3"}, exitInput},
+		{"unwritable outdir", []string{"split", "-in", input, "-lines", "3", "-outdir", filepath.Join(notDir, "sub")}, exitOutput},
+		{"create failure", []string{"split", "-in", input, "-lines", "30", "-outdir", filepath.Join(dir, "create"), "-inject-error", "create:2"}, exitOutput},
+		{"write failure", []string{"split", "-in", input, "-lines", "30", "-outdir", filepath.Join(dir, "write"), "-inject-error", "write:2"}, exitOutput},
+		{"unexpected part count", []string{"split", "-in", input, "-lines", "30", "-outdir", filepath.Join(dir, "expect"), "-expect-parts", "2"}, exitVerify},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runCLI(t, dir, nil, tt.args...)
+			if res.code != tt.code {
+				t.Errorf("exit code %d, want %d\nstderr: %s", res.code, tt.code, res.stderr)
+			}
+			if tt.code != exitOK && !strings.Contains(res.stderr, "❌") {
+				t.Errorf("no failure line on stderr: %q", res.stderr)
+			}
+		})
+	}
+}
+
+// TestExitCodeWriteFailureKeepsCompleteParts checks that an injected write
+// failure leaves the parts before it and removes the part it cut short.
+func TestExitCodeWriteFailureKeepsCompleteParts(t *testing.T) {
+	dir := t.TempDir()
+	input := writeInput(t, dir, 100)
+	out := filepath.Join(dir, "out")
+	res := runCLI(t, dir, nil, "split", "-in", input, "-lines", "30", "-outdir", out, "-inject-error", "write:2")
+	if res.code != exitOutput {
+		t.Fatalf("exit code %d, want %d\nstderr: %s", res.code, exitOutput, res.stderr)
+	}
+	names, err := filepath.Glob(filepath.Join(out, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || filepath.Base(names[0]) != "part001.txt" {
+		t.Errorf("parts left: %v, want only part001.txt", names)
+	}
+	if !strings.Contains(res.stderr, "part002.txt") {
+		t.Errorf("failure doesn't name the part: %q", res.stderr)
+	}
+}
+
+func TestExitCodeInterrupted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGINT can't be sent to a process on Windows")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	cmd := cliCommand(dir, "split", "-in", "-", "-lines", "2", "-outdir", out)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// Keep stdin open, so the split is still running when it is interrupted.
+	fmt.Fprint(stdin, "a\nb\nc\n")
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(out, "part002.txt")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			t.Fatal("the split never started part002.txt")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.Wait()
+	stdin.Close()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitInterrupted {
+		t.Fatalf("got %v, want exit code %d", err, exitInterrupted)
+	}
+}
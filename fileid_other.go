@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileID returns 0: file identity is only tracked on Unix.
+func fileID(os.FileInfo) uint64 { return 0 }
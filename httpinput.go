@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// isURL reports whether -in names an HTTP(S) URL rather than a file.
+func isURL(in string) bool {
+	return strings.HasPrefix(in, "http://") || strings.HasPrefix(in, "https://")
+}
+
+// headerList is a repeatable "Name: value" flag.
+type headerList []string
+
+func (h *headerList) String() string { return strings.Join(*h, ", ") }
+
+func (h *headerList) Set(s string) error {
+	name, _, ok := strings.Cut(s, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected Name: value, got %q", s)
+	}
+	*h = append(*h, s)
+	return nil
+}
+
+// openURL starts a GET of url with headers and returns the response body
+// and its size, or -1 when the server didn't send a Content-Length. Any
+// status other than 200 is an input error.
+func openURL(url string, headers []string) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, &splitter.InputError{Err: err}
+	}
+	for _, h := range headers {
+		name, value, _ := strings.Cut(h, ":")
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, &splitter.InputError{Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, &splitter.InputError{Err: fmt.Errorf("GET %s: %s", url, resp.Status)}
+	}
+	return resp.Body, resp.ContentLength, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// runInfo reports the size, line count and detected format of a file
+// without splitting it.
+func runInfo(args []string) error {
+	fs := newFlagSet("info")
+	cf := addCommonFlags(fs)
+	inputFile := fs.String("in", "", "Input file to inspect, or - for stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := cf.setup(func() error {
+		if *inputFile == "" {
+			return usageErrorf("-in is required")
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	file, name := os.Stdin, "stdin"
+	if *inputFile != "-" {
+		f, err := os.Open(*inputFile)
+		if err != nil {
+			return &splitter.InputError{Err: err}
+		}
+		defer f.Close()
+		file, name = f, *inputFile
+	}
+	br := bufio.NewReaderSize(file, 64*1024)
+	sample, _ := br.Peek(splitter.DetectSampleSize)
+	format := splitter.DetectFormat(name, sample)
+	d := splitter.Detect(sample)
+
+	var size, lines, longest, cur int64
+	for {
+		chunk, err := br.ReadSlice('\n')
+		size += int64(len(chunk))
+		cur += int64(len(bytes.TrimRight(chunk, "\r\n")))
+		if len(chunk) > 0 && chunk[len(chunk)-1] == '\n' || err == io.EOF && cur > 0 {
+			lines++
+			longest = max(longest, cur)
+			cur = 0
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return &splitter.InputError{Offset: size, Err: err}
+		}
+	}
+
+	delim, eol := "none", "LF"
+	if d.Delimiter != "" {
+		delim = strconv.Quote(d.Delimiter)
+	}
+	if d.CRLF {
+		eol = "CRLF"
+	}
+	con.emit(slog.LevelInfo, fmt.Sprintf("📄 %s: %d bytes, %d lines, longest line %d bytes", name, size, lines, longest),
+		"file info", "file", name, "bytes", size, "lines", lines, "longest_line", longest)
+	con.emit(slog.LevelInfo, fmt.Sprintf("🔍 Format %s, delimiter %s, encoding %s, line endings %s", format, delim, d.Encoding, eol),
+		"detected", "format", format, "delimiter", d.Delimiter, "encoding", d.Encoding, "crlf", d.CRLF)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// openInputs opens every file of a comma-separated -in.
+func openInputs(paths []string) ([]*os.File, error) {
+	files := make([]*os.File, 0, len(paths))
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			closeInputs(files)
+			return nil, &splitter.InputError{Err: err}
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func closeInputs(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// interleaveInputs merges the lines of files round-robin, or by timestamp.
+func interleaveInputs(files []*os.File, byTime bool) *splitter.InterleavedReader {
+	rs := make([]io.Reader, len(files))
+	for i, f := range files {
+		rs[i] = f
+	}
+	if byTime {
+		return splitter.MergeByTime(rs...)
+	}
+	return splitter.Interleave(rs...)
+}
+
+// sortLines sorts the lines of input for -sort, logging how it went. An
+// interrupt while sorting counts as a cancelled split.
+func sortLines(ctx context.Context, input io.Reader, memory string) (*splitter.SortedReader, error) {
+	limit, err := parseSize(memory)
+	if err != nil {
+		return nil, usageErrorf("invalid -sort-memory: %v", err)
+	}
+	con.emit(slog.LevelInfo, "🔃 Sorting input", "sorting", "memory", limit)
+	start := time.Now()
+	sorted, err := splitter.SortLines(ctx, input, limit, "")
+	if ctx.Err() != nil {
+		return nil, &splitter.CancelError{Err: ctx.Err()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	how := "in memory"
+	if sorted.Spilled() {
+		how = "through temporary files"
+	}
+	con.emit(slog.LevelInfo, fmt.Sprintf("🔃 Sorted %d lines %s in %s", sorted.Lines(), how, time.Since(start).Round(time.Millisecond)),
+		"sorted", "lines", sorted.Lines(), "spilled", sorted.Spilled())
+	return sorted, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// jsonSchema maps each JSON log event, by msg, to its keys and the JSON
+// type of each value. Changing it breaks log pipelines that parse the
+// output, so only add to it.
+var jsonSchema = map[string]map[string]string{
+	"input":    {"file": "string", "bytes": "number"},
+	"Creating": {"part": "number", "file": "string", "reason": "string"},
+	"part closed": {
+		"part": "number", "file": "string", "lines": "number", "bytes": "number",
+		"bytes_read": "number", "mb_per_sec": "number", "percent": "number",
+	},
+	"done": {
+		"parts": "number", "dry_run": "bool", "largest_part_bytes": "number", "pruned_parts": "number",
+		"sampled_out": "number", "skipped_lines": "number", "lines": "number", "bytes_read": "number",
+		"bytes_written": "number", "duplicates": "number", "long_lines": "number", "truncated_lines": "number",
+		"elapsed_ms": "number", "bytes_per_sec": "number", "lines_per_sec": "number",
+	},
+}
+
+// jsonType names the JSON type of a value decoded into any.
+func jsonType(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case nil:
+		return "null"
+	}
+	return "object"
+}
+
+// parseJSONLog decodes each line of out as one log event and checks the
+// time, level and msg every event carries.
+func parseJSONLog(t *testing.T, out string) []map[string]any {
+	t.Helper()
+	var events []map[string]any
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		var ev map[string]any
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("log line %q is not a JSON object: %v", line, err)
+		}
+		ts, _ := ev["time"].(string)
+		if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+			t.Errorf("event %q has time %v, want an RFC 3339 timestamp", line, ev["time"])
+		}
+		if level, _ := ev["level"].(string); !slices.Contains([]string{"INFO", "WARN", "ERROR"}, level) {
+			t.Errorf("event %q has level %v", line, ev["level"])
+		}
+		if _, ok := ev["msg"].(string); !ok {
+			t.Fatalf("event %q has no msg", line)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestJSONLogSchema(t *testing.T) {
+	dir := t.TempDir()
+	input := writeInput(t, dir, 100)
+	res := runCLI(t, dir, nil, "split", "-in", input, "-lines", "30", "-outdir", filepath.Join(dir, "out"), "-log-format", "json", "-v")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d, want %d\nstderr: %s", res.code, exitOK, res.stderr)
+	}
+	if res.stderr != "" {
+		t.Errorf("stderr is not empty:\n%s", res.stderr)
+	}
+	count := map[string]int{}
+	for _, ev := range parseJSONLog(t, res.stdout) {
+		msg := ev["msg"].(string)
+		count[msg]++
+		schema, ok := jsonSchema[msg]
+		if !ok {
+			t.Errorf("unexpected event %v", ev)
+			continue
+		}
+		for key, want := range schema {
+			if got := jsonType(ev[key]); got != want {
+				t.Errorf("%q event has %s %v (%s), want a %s", msg, key, ev[key], got, want)
+			}
+		}
+		for key := range ev {
+			if _, ok := schema[key]; !ok && key != "time" && key != "level" && key != "msg" {
+				t.Errorf("%q event has an unexpected key %s", msg, key)
+			}
+		}
+	}
+	want := map[string]int{"input": 1, "Creating": 4, "part closed": 4, "done": 1}
+	for msg, n := range want {
+		if count[msg] != n {
+			t.Errorf("%d %q events, want %d", count[msg], msg, n)
+		}
+	}
+}
+
+// TestJSONLogError checks that a failure is one JSON event on stderr.
+func TestJSONLogError(t *testing.T) {
+	dir := t.TempDir()
+	res := runCLI(t, dir, nil, "split", "-in", filepath.Join(dir, "missing.txt"), "-lines", "30", "-log-format", "json")
+	if res.code != exitInput {
+		t.Fatalf("exit code %d, want %d\nstderr: %s", res.code, exitInput, res.stderr)
+	}
+	if res.stdout != "" {
+		t.Errorf("stdout is not empty:\n%s", res.stdout)
+	}
+	events := parseJSONLog(t, res.stderr)
+	if len(events) != 1 || events[0]["level"] != "ERROR" || !strings.HasPrefix(events[0]["msg"].(string), "input error:") {
+		t.Errorf("stderr events %v, want one input error", events)
+	}
+}
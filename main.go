@@ -2,208 +2,909 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	_ "time/tzdata" // -ts-tz works where the system has no zoneinfo
 
-	"github.com/fatih/color"
+	"github.com/basemax/filesplitter/splitter"
 )
 
-const bufSize = 128 * 1024 // 128KB buffer for I/O
-
-func logInfo(msg string)    { color.Green("✅ %s", msg) }
-func logError(msg string)   { color.Red("❌ %s", msg) }
-func logWarn(msg string)    { color.Yellow("⚠️  %s", msg) }
-func logSuccess(msg string) { color.Cyan("🎯 %s", msg) }
+// dirMode derives a directory mode from a file mode by adding search
+// permission wherever read permission is granted.
+func dirMode(perm os.FileMode) os.FileMode {
+	if perm == 0 {
+		return 0o777
+	}
+	return perm | (perm&0o444)>>2
+}
 
-func printBanner() {
-	color.Cyan(`
-📁 FileSplitter v1.0 by Max Base
-📦 Split massive files by lines, size, or pattern with style!
-━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
-`)
+// parseFault parses an -inject-error value such as "write:3".
+func parseFault(s string) (splitter.Fault, error) {
+	op, n, ok := strings.Cut(s, ":")
+	part, err := strconv.Atoi(n)
+	if !ok || err != nil {
+		return splitter.Fault{}, fmt.Errorf("invalid -inject-error %q: expected create:N or write:N", s)
+	}
+	return splitter.Fault{Op: op, Part: part}, nil
 }
 
-func main() {
+// runSplit is the split command. Every failure is returned so main can
+// map it to an exit code. legacy is set for an invocation without a
+// command, which still splits but is deprecated.
+func runSplit(args []string, legacy bool) error {
+	fs := newFlagSet("split")
+	cf := addCommonFlags(fs)
+	quiet, outputDir := cf.quiet, cf.outputDir
+	showVersion := fs.Bool("version", false, "Print version and build information and exit")
+	inputFile := fs.String("in", "", "Input file path (e.g., usernames.txt), an http(s):// URL, or - for stdin; several comma-separated files are merged line by line")
+	var httpHeaders headerList
+	fs.Var(&httpHeaders, "http-header", "Header to send when -in is a URL, as \"Name: value\" (e.g., Authorization); repeatable")
+	sortInput := fs.Bool("sort", false, "Sort the input's lines in byte order before splitting, so each part is sorted and the parts follow each other; inputs larger than -sort-memory are sorted through temporary files in $TMPDIR")
+	sortMemory := fs.String("sort-memory", "256MB", "Memory -sort may hold lines in before writing them to a temporary file")
+	mergeByTime := fs.Bool("merge-by-time", false, "Merge comma-separated -in files by the timestamp on each line instead of round-robin")
+	stdinName := fs.String("stdin-filename", "stdin", "Name used for the input when reading from stdin")
+	linesPerFile := fs.Int("lines", 0, "Split by number of lines (e.g., 1000000)")
+	alignLines := fs.Int("align-lines", 0, "With -lines, end parts on multiples of this many input lines")
+	maxLine := fs.String("max-line", "", "Fail if any line is longer than this, including its line ending (e.g., 1MB)")
+	maxLineLength := fs.String("max-line-length", "", "Warn about each line longer than this, including its line ending (e.g., 64KB)")
+	maxLineAction := fs.String("max-line-length-action", "warn", "What -max-line-length does with a long line: warn or error")
+	truncate := fs.Int("truncate", 0, "Cut lines longer than this many bytes down to that length, ending in ...")
+	softWrap := fs.Int("softwrap", 0, "Break lines longer than this many characters onto several lines of at most that width, in the same part")
+	sizePerFile := fs.String("size", "", "Split by max size (e.g., 100MB, 500KB)")
+	sizeAlign := fs.Bool("size-align-lines", false, "With -size, copy exactly the size into each part, then up to the next line end; parts may exceed -size by part of a line, but the split is faster")
+	sizeSchedule := fs.String("size-schedule", "", "Comma-separated size limits for successive parts, starting over after the last; end with ... to keep the last size (e.g., 10MB,10MB,100MB...)")
+	bytesPerFile := fs.String("bytes", "", "Split into parts of exactly this size, ignoring lines (e.g., 10MB)")
+	fs.Bool("copy-ok", false, "Allow running without a split criterion, copying the input into a single part")
+	decode := fs.String("decode", "", "Decode base64 or hex input before splitting")
+	transform := fs.String("transform", "", "Pipe the input through this shell command and split its output (e.g., 'jq -c .')")
+	pattern := fs.String("pattern", "", "Split file whenever this pattern is matched")
+	fixed := fs.Bool("fixed", false, "Match -pattern as a plain string instead of a regex")
+	ignoreCase := fs.Bool("ignore-case", false, "Match -pattern regardless of letter case, like prefixing it with (?i)")
+	recordBegin := fs.String("record-begin", "", "Regex of the first line of a multi-line record (e.g., '^-----BEGIN CERTIFICATE'); records are never split across parts")
+	recordEnd := fs.String("record-end", "", "Regex of the last line of a record started by -record-begin (e.g., '^-----END CERTIFICATE')")
+	paragraphs := fs.Bool("paragraph", false, "Never split a paragraph (lines up to a blank line) across parts; -size and -lines are checked at paragraph boundaries")
+	slug := fs.Bool("slug", false, "Name parts after -pattern's named group as a lowercase slug, e.g. 'Chapter 1: Intro' gives part_chapter-1-intro.txt")
+	matchesPerPart := fs.Int("matches-per-part", 1, "With -pattern, rotate on every Nth match instead of every match")
+	expectParts := fs.Int("expect-parts", 0, "Fail unless exactly this many parts are produced")
+	outPrefix := fs.String("prefix", "part", "Output filename prefix")
+	fileExt := fs.String("ext", "txt", "Output file extension")
+	padWidth := fs.Int("pad", 3, "Zero padding width for file index")
+	timestamp := fs.Bool("ts", false, "Add timestamp to filenames")
+	tsZone := fs.String("ts-tz", "", "Time zone of the -ts timestamp, as an IANA name such as America/New_York or UTC; implies -ts (default: the local time zone)")
+	tsFormat := fs.String("ts-format", "", "Layout of the -ts timestamp, written as Go's reference time Mon Jan 2 15:04:05 MST 2006 (e.g., 20060102 or 2006-01-02T15-04-05), or unix for Unix seconds; implies -ts (default 20060102_150405)")
+	dryRun := fs.Bool("dry", false, "Dry run mode (preview only)")
+	confirmAbove := fs.Int64("confirm-above", 10000, "Ask before creating more than about this many parts (0 to never ask)")
+	reverseIndex := fs.Bool("reverse-index", false, "Number the parts from last to first; the input is read twice, so it must be a regular file")
+	yes := fs.Bool("yes", false, "Don't ask before creating more than -confirm-above parts; needed to go ahead when not on a terminal")
+	countOnly := fs.Bool("count", false, "Only print the input's lines, bytes, longest line and projected part count, like wc (as JSON with -log-format json); nothing is written")
+	fs.BoolVar(countOnly, "count-only", false, "Like -count, but print only 'Lines: N, Bytes: N'")
+	dryJSON := fs.Bool("dry-json", false, "Dry run that prints only the planned parts to stdout, as a JSON array with each part's name, bytes, lines and line range")
+	selectColumns := fs.String("select-columns", "", "Write only these 1-based columns, in this order (e.g., 1,3,5)")
+	fieldSep := fs.String("field-sep", ",", "Input field separator for -select-columns and -columns")
+	fs.StringVar(fieldSep, "delim", ",", "Alias for -field-sep")
+	vertical := fs.Bool("vertical", false, "Split by columns: one part per -columns group, each row written to every part")
+	columnGroups := fs.String("columns", "", "Column groups for -vertical, 1-based columns or ranges (e.g., 1-3,5)")
+	stripNewline := fs.Bool("strip-newline", false, "Remove the line ending from each line before writing it")
+	recordSep := fs.String("output-record-sep", "", "Write this after each line in place of its line ending; escapes such as \\x00 or \\t are allowed")
+	fuzzyDedupe := fs.Bool("fuzzy-dedupe", false, "Skip lines that are near-duplicates of earlier lines (SimHash)")
+	similarity := fs.Float64("similarity", 0.8, "Similarity from 0 to 1 at which -fuzzy-dedupe treats lines as duplicates")
+	dedupeLimit := fs.Int("fuzzy-dedupe-limit", 1000000, "Remember at most this many line fingerprints for -fuzzy-dedupe (0 for no limit)")
+	allowEmpty := fs.Bool("allow-empty", false, "Write one empty part for empty input instead of none")
+	syslogSplit := fs.Bool("syslog-split", false, "Route each line to <prefix>_<level>.log by its syslog priority: critical, error, warn, info, debug or unparsed")
+	timeField := fs.String("time-field", "", "Start a new part when the timestamp captured by this regex's first group enters a new -time-window (e.g., '^(\\d{4}-\\d{2}-\\d{2}T\\d{2})')")
+	timeWindow := fs.String("time-window", "", "Window length for -time-field, such as 1h, 15m or 1d")
+	rotateEvery := fs.Duration("rotate-every", 0, "Start a new part once the current one has been open this long (e.g., 15m), for slow streams")
+	maxIdle := fs.Duration("max-idle", 0, "Close the current part once no input has arrived for this long (e.g., 30s), so a slow stream's lines are handed on promptly")
+	overlap := fs.Int("overlap", 0, "Start each part after the first with the last this many lines of the part before it, for sliding-window processing")
+	inlineManifest := fs.Bool("inline-manifest", false, "Append a manifest of all parts to the end of the last part, as comment lines")
+	commentPrefix := fs.String("comment-prefix", "#", "Start each -inline-manifest line with this, to suit the data (e.g., //, --)")
+	shuffle := fs.Int("shuffle", 0, "Write each line to one of this many parts chosen at random (e.g., for train/test splits)")
+	sampleRate := fs.Float64("sample", 0, "Write only this fraction of lines, chosen at random (e.g., 0.1)")
+	seed := fs.Uint64("seed", 0, "Seed for -shuffle and -sample, to reproduce a split (default: random, logged at the end)")
+	pruneEmpty := fs.Bool("prune-empty", false, "Delete parts that are empty once closed and renumber the parts after them")
+	noRenumber := fs.Bool("no-renumber", false, "With -prune-empty, keep the gap in part numbers and list pruned parts in -report and -done-file")
+	header := fs.Bool("header", false, "With -vertical, treat the first row as a header that must contain every selected column")
+	outFieldSep := fs.String("output-field-sep", "", "Output field separator for -select-columns and -columns (default: same as -field-sep)")
+	lineStats := fs.Bool("line-stats", false, "Compute min/max/mean/stddev line lengths per part")
+	reportPath := fs.String("report", "", "Write per-part stats to this CSV file (.tsv for tab-separated)")
+	sumsPath := fs.String("verify-file", "", "Write the parts' SHA-256 checksums to this file in sha256sum format, for sha256sum -c or verify -verify-file")
+	progressEvery := fs.Duration("progress", 0, "Log progress at this interval when not drawing a progress bar (e.g., 5s)")
+	noBar := fs.Bool("no-progress", false, "Don't draw a progress bar on the terminal")
+	rateLimit := fs.String("rate-limit", "", "Throttle output to this rate (e.g., 50MB/s)")
+	rateBurst := fs.String("rate-limit-burst", "", "Burst size for -rate-limit (default: one second of output)")
+	fileMode := fs.String("mode", "", "Permissions for parts and a created output directory, in octal (e.g., 0600)")
+	preservePerms := fs.Bool("preserve-perms", false, "Give parts the same permissions as the input file")
+	fifo := fs.Bool("fifo", false, "Write each part to a named pipe, created if missing, for a downstream reader to drain without touching disk (Unix only)")
+	atomic := fs.Bool("atomic", false, "Write each part under a .partial name and rename it when complete")
+	preallocate := fs.Bool("preallocate", false, "Reserve each part's -size or -bytes on disk when it is created, trimming it to the real length on close; reduces fragmentation and fails early when the disk is too full")
+	bufferSize := fs.String("buffer", "128KB", "Size of the read buffer and of each part's write buffer (e.g., 4MB); larger helps slow disks and network filesystems, smaller saves memory")
+	pipelineDepth := fs.Int("pipeline-depth", 4, "Read the input this many buffers ahead on a separate goroutine, overlapping reads with writes; 0 reads and writes in turn")
+	retryOnError := fs.Int("retry-on-error", 0, "Retry creating or writing a part up to this many times when it fails with a transient error such as EIO or ESTALE, waiting 100ms and doubling up to 10s")
+	mmapInput := fs.Bool("mmap", false, "Read a regular -in file through a memory mapping instead of copying it into the read buffer; other inputs are read normally")
+	fsync := fs.Bool("fsync", false, "Flush each part to disk (fsync) before moving on; slower, but parts survive a crash")
+	rmPartial := fs.Bool("rm-partial", false, "Delete the incomplete part when the split is interrupted")
+	markPartial := fs.Bool("mark-partial", false, "Rename the incomplete part to <name>.partial when the split is interrupted")
+	appendLast := fs.Bool("append", false, "Add lines to the last existing part in -outdir until it reaches its limits, then start new parts after it")
+	incremental := fs.Bool("incremental", false, "Split only what was appended to -in since the last run, tracked in -state-file")
+	stateFile := fs.String("state-file", "", "State file for -incremental (e.g., state.json)")
+	autoMode := fs.Bool("auto-mode", false, "Pick the split mode from the input's detected format (e.g., byte chunks for binary data)")
+	autoDetect := fs.Bool("auto-detect", false, "Guess the field separator, encoding and line endings from the first 8KB of input")
+	verbose := fs.Bool("v", false, "Verbose: log the lines and bytes of each part as it is closed, with the share of input read and the read rate")
+	veryVerbose := fs.Bool("vv", false, "Like -v, and also say why each part was started (lines, size, pattern, ...)")
+	eventsPath := fs.String("event-pipe", "", "Write JSON lines for start, each part_ready and done to this existing FIFO or file (e.g., /tmp/events.fifo)")
+	doneFile := fs.String("done-file", "", "Write this marker file (e.g., out/_SUCCESS) with a summary once every part is complete; _FAILED is written beside it on failure")
+	serveAddr := fs.String("serve", "", "Run as an HTTP service on this address (e.g., :8080) instead of splitting -in")
+	serveMaxBody := fs.String("serve-max-body", "0B", "With -serve, reject request bodies larger than this (e.g., 10GB); 0B for no limit")
+	parallelHash := fs.Int("parallel-hash", min(4, runtime.NumCPU()), "Compute the checksums of -report, -verify-file and -inline-manifest on this many background goroutines, one part each, so a closed part is hashed while the next is written; 0 hashes as parts are written")
+	threads := fs.Int("threads", 0, "Run Go code on at most this many CPUs at once (GOMAXPROCS), garbage collection included (default: all CPUs)")
+	maxMemory := fs.String("max-memory", "", "Keep memory use near this budget (e.g., 512MB) by shrinking buffers, -fuzzy-dedupe fingerprints and -overlap lines to fit")
+	cpuProfile := fs.String("cpuprofile", "", "Write a pprof CPU profile of the split to this file")
+	memProfile := fs.String("memprofile", "", "Write a pprof heap profile to this file when the split ends")
+	injectError := fs.String("inject-error", "", "Testing hook: force a failure, as create:N or write:N")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := cf.setup(func() error { return validateFlags(fs) }); err != nil {
+		return err
+	}
+	stopProfiles, err := startProfiles(*cpuProfile, *memProfile)
+	if err != nil {
+		return err
+	}
+	defer stopProfiles()
+	if *countOnly || *dryJSON {
+		// Keep stdout for the counts or the plan.
+		con.quiet = true
+	}
+	if *showVersion {
+		fmt.Println(versionString())
+		return nil
+	}
 	printBanner()
+	if legacy {
+		logWarn("Running without a command is deprecated and will be removed in the next release; use: filesplitter split ...")
+	}
+	if *threads > 0 {
+		runtime.GOMAXPROCS(*threads)
+		n := runtime.GOMAXPROCS(0)
+		con.emit(slog.LevelInfo, fmt.Sprintf("🧵 Using %d threads", n), "threads", "gomaxprocs", n)
+	}
 
-	inputFile := flag.String("in", "", "Input file path (e.g., usernames.txt)")
-	linesPerFile := flag.Int("lines", 0, "Split by number of lines (e.g., 1000000)")
-	sizePerFile := flag.String("size", "", "Split by max size (e.g., 100MB, 500KB)")
-	pattern := flag.String("pattern", "", "Split file whenever this pattern is matched")
-	outPrefix := flag.String("prefix", "part", "Output filename prefix")
-	outputDir := flag.String("outdir", ".", "Output directory")
-	fileExt := flag.String("ext", "txt", "Output file extension")
-	padWidth := flag.Int("pad", 3, "Zero padding width for file index")
-	timestamp := flag.Bool("ts", false, "Add timestamp to filenames")
-	dryRun := flag.Bool("dry", false, "Dry run mode (preview only)")
-	quiet := flag.Bool("q", false, "Quiet mode (suppress logs)")
+	if *serveAddr != "" {
+		maxBody, err := parseSize(*serveMaxBody)
+		if err != nil {
+			return usageErrorf("invalid -serve-max-body: %v", err)
+		}
+		return serve(*serveAddr, maxBody)
+	}
 
-	flag.Parse()
+	// Counting and -dry-json are quiet dry runs.
+	if *countOnly || *dryJSON {
+		*dryRun = true
+	}
 
-	if *inputFile == "" {
-		logError("Input file is required! Use -in flag.")
-		os.Exit(1)
+	inputName := *inputFile
+	file := os.Stdin
+	var input io.Reader = file
+	var inputSize int64
+	// sizeHint is the input's size for estimating the part count, or 0 when
+	// unknown.
+	var sizeHint int64
+	if isURL(*inputFile) {
+		body, size, err := openURL(*inputFile, httpHeaders)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		// There is no file to stat; nil makes those checks fail cleanly.
+		file, input, inputSize, sizeHint = nil, body, size, size
+		if size >= 0 {
+			con.emit(slog.LevelInfo, fmt.Sprintf("🌐 Input URL: %s (%s)", inputName, splitter.SizeString(size)),
+				"input", "url", inputName, "bytes", size)
+		} else {
+			con.emit(slog.LevelInfo, "🌐 Input URL: "+inputName, "input", "url", inputName)
+		}
+	} else if paths := strings.Split(*inputFile, ","); len(paths) > 1 {
+		files, err := openInputs(paths)
+		if err != nil {
+			return err
+		}
+		defer closeInputs(files)
+		m := interleaveInputs(files, *mergeByTime)
+		defer m.Close()
+		// The first input stands in for file-level checks such as
+		// -preserve-perms.
+		file, input = files[0], m
+		for _, f := range files {
+			if stat, err := f.Stat(); err == nil && stat.Mode().IsRegular() {
+				sizeHint += stat.Size()
+			}
+		}
+		order := "round-robin"
+		if *mergeByTime {
+			order = "by timestamp"
+		}
+		con.emit(slog.LevelInfo, fmt.Sprintf("📄 Input: %d files merged %s", len(files), order),
+			"input", "files", paths, "order", order)
+	} else {
+		if *inputFile == "-" {
+			inputName = *stdinName
+		} else {
+			f, err := os.Open(*inputFile)
+			if err != nil {
+				return &splitter.InputError{Err: err}
+			}
+			file, input = f, f
+		}
+		defer file.Close()
+
+		if stat, err := file.Stat(); err == nil && stat.Mode().IsRegular() {
+			sizeHint = stat.Size()
+			con.emit(slog.LevelInfo, fmt.Sprintf("📄 Input File: %s (%s)", inputName, splitter.SizeString(stat.Size())),
+				"input", "file", inputName, "bytes", stat.Size())
+		} else {
+			con.emit(slog.LevelInfo, "📄 Input: "+inputName, "input", "file", inputName)
+		}
+	}
+
+	var sample []byte
+	if *autoDetect || *autoMode {
+		if stat, err := file.Stat(); err == nil && stat.Mode().IsRegular() && input == io.Reader(file) {
+			sample = make([]byte, splitter.DetectSampleSize)
+			n, _ := file.ReadAt(sample, 0)
+			sample = sample[:n]
+		} else {
+			br := bufio.NewReaderSize(input, splitter.DetectSampleSize)
+			sample, _ = br.Peek(splitter.DetectSampleSize)
+			input = br
+		}
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if *autoDetect {
+		d := splitter.Detect(sample)
+		if d.Delimiter != "" && !explicit["field-sep"] && !explicit["delim"] {
+			*fieldSep = d.Delimiter
+		}
+		delim, eol := "none", "LF"
+		if d.Delimiter != "" {
+			delim = strconv.Quote(d.Delimiter)
+		}
+		if d.CRLF {
+			eol = "CRLF"
+		}
+		con.emit(slog.LevelInfo, fmt.Sprintf("🔍 Detected: delimiter %s, encoding %s, line endings %s", delim, d.Encoding, eol),
+			"detected", "delimiter", d.Delimiter, "encoding", d.Encoding, "crlf", d.CRLF)
+		if d.Encoding == "utf-16le" {
+			logWarn("Input looks like UTF-16LE; lines are split on \\n bytes, so convert it to UTF-8 first")
+		}
+	}
+	if *autoMode {
+		format := splitter.DetectFormat(inputName, sample)
+		modeSet := explicit["bytes"] || explicit["pattern"] || explicit["vertical"]
+		mode := "lines"
+		switch {
+		case modeSet:
+			mode = "as given by flags"
+		case format == splitter.FormatBinary && *sizePerFile != "":
+			// Line boundaries mean nothing in binary data.
+			*bytesPerFile, *sizePerFile = *sizePerFile, ""
+			mode = "byte chunks"
+		case format == splitter.FormatBinary:
+			logWarn("Input looks binary; pass -size to split it into exact byte chunks")
+		case format == splitter.FormatCSV && !explicit["field-sep"] && !explicit["delim"]:
+			if d := splitter.Detect(sample); d.Delimiter != "" {
+				*fieldSep = d.Delimiter
+			}
+		}
+		con.emit(slog.LevelInfo, fmt.Sprintf("🧭 Detected format: %s, splitting by %s", format, mode),
+			"format detected", "format", format, "mode", mode)
 	}
 
-	file, err := os.Open(*inputFile)
+	maxSizeBytes, err := parseSize(*sizePerFile)
+	if err != nil {
+		return usageErrorf("invalid -size: %v", err)
+	}
+	maxLineBytes, err := parseSize(*maxLine)
 	if err != nil {
-		logError("Failed to open input file: " + err.Error())
-		os.Exit(1)
+		return usageErrorf("invalid -max-line: %v", err)
+	}
+	warnLineBytes, err := parseSize(*maxLineLength)
+	if err != nil {
+		return usageErrorf("invalid -max-line-length: %v", err)
+	}
+	if *maxLineAction == "error" {
+		// A hard limit of its own, or the tighter of the two.
+		if maxLineBytes == 0 || (warnLineBytes > 0 && warnLineBytes < maxLineBytes) {
+			maxLineBytes = warnLineBytes
+		}
+		warnLineBytes = 0
 	}
-	defer file.Close()
 
-	stat, _ := file.Stat()
-	if !*quiet {
-		logInfo(fmt.Sprintf("📄 Input File: %s (%.2f MB)", *inputFile, float64(stat.Size())/(1024*1024)))
+	chunkBytes, err := parseSize(*bytesPerFile)
+	if err != nil {
+		return usageErrorf("invalid -bytes: %v", err)
 	}
 
-	maxSizeBytes, err := parseSize(*sizePerFile)
+	rateBytes, err := parseSize(strings.TrimSuffix(strings.TrimSpace(*rateLimit), "/s"))
 	if err != nil {
-		logWarn("Invalid size format: " + err.Error())
-		maxSizeBytes = 0
+		return usageErrorf("invalid -rate-limit: %v", err)
+	}
+	burstBytes, err := parseSize(*rateBurst)
+	if err != nil {
+		return usageErrorf("invalid -rate-limit-burst: %v", err)
+	}
+	bufBytes, err := parseSize(*bufferSize)
+	if err != nil {
+		return usageErrorf("invalid -buffer: %v", err)
+	}
+	memBytes, err := parseSize(*maxMemory)
+	if err != nil {
+		return usageErrorf("invalid -max-memory: %v", err)
+	}
+	if memBytes > 0 {
+		debug.SetMemoryLimit(memBytes)
+	}
+	if longest := max(maxLineBytes, warnLineBytes); longest > bufBytes {
+		logWarn(fmt.Sprintf("Lines up to %s are allowed, but those longer than the %s -buffer are read in pieces, which is slower", splitter.SizeString(longest), splitter.SizeString(bufBytes)))
 	}
 
-	var re *regexp.Regexp
-	if *pattern != "" {
-		re, err = regexp.Compile(*pattern)
+	var perm os.FileMode
+	switch {
+	case *fileMode != "":
+		m, err := strconv.ParseUint(*fileMode, 8, 32)
+		if err != nil || m > 0o777 {
+			return usageErrorf("invalid -mode %q: expected octal permissions such as 0600", *fileMode)
+		}
+		perm = os.FileMode(m)
+	case *preservePerms:
+		stat, err := file.Stat()
 		if err != nil {
-			logError("Invalid regex pattern: " + err.Error())
-			os.Exit(1)
+			return &splitter.InputError{Err: err}
+		}
+		if stat.Mode().IsRegular() {
+			perm = stat.Mode().Perm()
 		}
 	}
 
-	splitFile(file, *linesPerFile, maxSizeBytes, re, *outputDir, *outPrefix, *fileExt, *padWidth, *timestamp, *dryRun, *quiet)
-}
+	partDir, remote := *outputDir, isRemote(*outputDir)
+	if remote {
+		if *fifo || *appendLast || *preallocate || *fsync {
+			return usageErrorf("-fifo, -append, -preallocate and -fsync need a local -outdir")
+		}
+		var err error
+		if partDir, err = remoteDir(*outputDir); err != nil {
+			return usageErrorf("invalid -outdir: %v", err)
+		}
+	}
 
-func splitFile(file *os.File, maxLines int, maxSizeBytes int64, pattern *regexp.Regexp, outputDir, prefix, ext string, padWidth int, useTS, dryRun, quiet bool) {
-	reader := bufio.NewReaderSize(file, bufSize)
-	lineCount := 0
-	part := 1
-	var written int64 = 0
-	var out *os.File
-	var writer *bufio.Writer
-
-	createNewPart := func() error {
-		if out != nil {
-			writer.Flush()
-			out.Close()
-		}
-		suffix := fmt.Sprintf("%0*d", padWidth, part)
-		if useTS {
-			suffix = fmt.Sprintf("%s_%s", suffix, time.Now().Format("20060102_150405"))
-		}
-		filename := filepath.Join(outputDir, fmt.Sprintf("%s%s.%s", prefix, suffix, ext))
-		if dryRun {
-			if !quiet {
-				logInfo("[DryRun] Would create: " + filename)
-			}
+	var state splitState
+	var stateEnd int64
+	if *incremental {
+		var err error
+		if state, err = loadState(*stateFile); err != nil {
+			return usageErrorf("invalid -state-file: %v", err)
+		}
+		stat, err := file.Stat()
+		if err != nil {
+			return &splitter.InputError{Err: err}
+		}
+		if id := fileID(stat); (state.Inode != 0 && id != state.Inode) || stat.Size() < state.Offset {
+			logWarn("Input was rotated or truncated since the last run, starting from its beginning")
+			state.Offset = 0
+		}
+		state.Inode, state.Size = fileID(stat), stat.Size()
+		if stateEnd, err = lastLineEnd(file, state.Offset, stat.Size()); err != nil {
+			return &splitter.InputError{Err: err}
+		}
+		if stateEnd == state.Offset {
+			logInfo("No new complete lines since the last run")
 			return nil
 		}
-		f, err := os.Create(filename)
+		con.emit(slog.LevelInfo, fmt.Sprintf("📌 Resuming at byte %d, after part %d", state.Offset, state.LastPart),
+			"resume", "offset", state.Offset, "last_part", state.LastPart)
+		input = io.NewSectionReader(file, state.Offset, stateEnd-state.Offset)
+		sizeHint = stateEnd - state.Offset
+	}
+
+	firstPart, appendTo := state.LastPart+1, 0
+	var appendLines, appendBytes int64
+	if *appendLast {
+		last := state.LastPart
+		if !*incremental {
+			var err error
+			if last, err = lastPart(*outputDir, *outPrefix, *fileExt, *padWidth); err != nil {
+				return &splitter.OutputError{Part: *outputDir, Err: err}
+			}
+		}
+		name := filepath.Join(*outputDir, fmt.Sprintf("%s%0*d.%s", *outPrefix, *padWidth, last, *fileExt))
+		lines, size, endsLine, err := measurePart(name)
+		switch {
+		case last == 0 || errors.Is(err, os.ErrNotExist):
+			logWarn("No existing part to append to, starting a new one")
+		case err != nil:
+			return &splitter.OutputError{Part: name, Err: err}
+		case !endsLine:
+			logWarn(fmt.Sprintf("%s doesn't end with a newline, starting a new part after it", name))
+			firstPart = last + 1
+		default:
+			firstPart, appendTo, appendLines, appendBytes = last, last, lines, size
+		}
+	}
+
+	if *reverseIndex && !rereadable(file, input) {
+		return usageErrorf("-reverse-index reads the input twice and needs a regular file, not a pipe, URL or several inputs")
+	}
+
+	var bar *progressBar
+	if !*noBar && con.decorated() {
+		bar = newProgressBar()
+	}
+
+	if (*syslogSplit || *timeField != "") && !explicit["ext"] {
+		*fileExt = "log"
+	}
+	opts := []splitter.Option{
+		splitter.WithMaxLines(*linesPerFile),
+		splitter.WithAlignLines(*alignLines),
+		splitter.WithMaxSize(maxSizeBytes),
+		splitter.WithMaxLineLength(maxLineBytes),
+		splitter.WithLongLineWarning(warnLineBytes),
+		splitter.WithTruncate(*truncate),
+		splitter.WithSoftWrap(*softWrap),
+		splitter.WithByteChunks(chunkBytes),
+		splitter.WithDecoding(*decode),
+		splitter.WithMatchesPerPart(*matchesPerPart),
+		splitter.WithExpectParts(*expectParts),
+		splitter.WithNaming(*outPrefix, *fileExt, *padWidth),
+		splitter.WithOutputDir(partDir),
+		splitter.WithFirstPart(firstPart),
+		splitter.WithRateLimit(rateBytes, burstBytes),
+		splitter.WithRotateEvery(*rotateEvery),
+		splitter.WithMaxIdle(*maxIdle),
+		splitter.WithBufferSize(int(bufBytes)),
+		splitter.WithPipelineDepth(*pipelineDepth),
+		splitter.WithMaxMemory(memBytes),
+		splitter.WithOverlap(*overlap),
+		splitter.WithLogger(cliLogger{bar: bar}),
+	}
+
+	if *pattern != "" {
+		re, err := compilePattern(*pattern, *fixed, *ignoreCase)
+		if err != nil {
+			return usageErrorf("invalid regex pattern: %v", err)
+		}
+		opts = append(opts, splitter.WithPattern(re))
+	}
+	if *slug {
+		opts = append(opts, splitter.WithSlug())
+	}
+	if *sizeAlign {
+		opts = append(opts, splitter.WithSizeAlignLines())
+	}
+
+	if *selectColumns != "" {
+		selector, err := splitter.NewColumnSelector(*selectColumns, *fieldSep, *outFieldSep)
+		if err != nil {
+			return usageErrorf("%v", err)
+		}
+		opts = append(opts, splitter.WithColumns(selector))
+	}
+
+	openParts := 1 // parts written at the same time, each with its own write buffer
+	if *vertical {
+		groups, err := splitter.NewColumnGroups(*columnGroups, *fieldSep, *outFieldSep)
+		if err != nil {
+			return usageErrorf("invalid -columns: %v", err)
+		}
+		opts = append(opts, splitter.WithVertical(groups...))
+		openParts = len(groups)
+	}
+	if *header {
+		opts = append(opts, splitter.WithHeader())
+	}
+	if *allowEmpty {
+		opts = append(opts, splitter.WithAllowEmpty())
+	}
+	if *shuffle > 0 {
+		opts = append(opts, splitter.WithShuffle(*shuffle))
+		openParts = *shuffle
+	}
+	if *syslogSplit {
+		opts = append(opts, splitter.WithSyslogSplit())
+		openParts = len(splitter.SyslogLevels)
+	}
+	if *sizeSchedule != "" {
+		sizes, hold, err := parseSchedule(*sizeSchedule)
 		if err != nil {
+			return usageErrorf("invalid -size-schedule: %v", err)
+		}
+		opts = append(opts, splitter.WithSizeSchedule(sizes, hold))
+	}
+	if *timeField != "" {
+		re, err := regexp.Compile(*timeField)
+		if err != nil {
+			return usageErrorf("invalid -time-field: %v", err)
+		}
+		window, err := parseWindow(*timeWindow)
+		if err != nil {
+			return usageErrorf("invalid -time-window: %v", err)
+		}
+		opts = append(opts, splitter.WithTimeWindow(re, window))
+	}
+	if *recordBegin != "" {
+		begin, err := regexp.Compile(*recordBegin)
+		if err != nil {
+			return usageErrorf("invalid -record-begin: %v", err)
+		}
+		end, err := regexp.Compile(*recordEnd)
+		if err != nil {
+			return usageErrorf("invalid -record-end: %v", err)
+		}
+		opts = append(opts, splitter.WithRecords(begin, end))
+	}
+	if *paragraphs {
+		opts = append(opts, splitter.WithParagraphs())
+	}
+	if inputSize > 0 {
+		opts = append(opts, splitter.WithInputSize(inputSize))
+	}
+	if *sampleRate > 0 {
+		opts = append(opts, splitter.WithSample(*sampleRate))
+	}
+	if explicit["seed"] {
+		opts = append(opts, splitter.WithSeed(*seed))
+	}
+	if *pruneEmpty {
+		opts = append(opts, splitter.WithPruneEmpty(!*noRenumber))
+	}
+	if *fuzzyDedupe {
+		if *similarity <= 0 || *similarity > 1 {
+			return usageErrorf("invalid -similarity %g: must be above 0 and at most 1", *similarity)
+		}
+		opts = append(opts, splitter.WithFuzzyDedupe(*similarity, *dedupeLimit))
+	}
+	if *stripNewline || *recordSep != "" {
+		sep, err := strconv.Unquote(`"` + *recordSep + `"`)
+		if err != nil {
+			return usageErrorf("invalid -output-record-sep %q: %v", *recordSep, err)
+		}
+		opts = append(opts, splitter.WithRecordSeparator(sep))
+	}
+
+	if *transform != "" {
+		opts = append(opts, splitter.WithTransform("sh", "-c", *transform))
+	}
+	if *injectError != "" {
+		fault, err := parseFault(*injectError)
+		if err != nil {
+			return usageErrorf("%v", err)
+		}
+		opts = append(opts, splitter.WithFault(fault))
+	}
+	switch {
+	case *tsFormat != "":
+		opts = append(opts, splitter.WithTimestampFormat(*tsFormat))
+	case *timestamp:
+		opts = append(opts, splitter.WithTimestamp())
+	}
+	if *tsZone != "" {
+		loc, err := time.LoadLocation(*tsZone)
+		if err != nil {
+			return usageErrorf("invalid -ts-tz: %v", err)
+		}
+		opts = append(opts, splitter.WithTimestampLocation(loc))
+	}
+	if *mmapInput {
+		opts = append(opts, splitter.WithMmap())
+	}
+	if appendTo > 0 {
+		opts = append(opts, splitter.WithAppend(appendTo, appendLines, appendBytes))
+	}
+	if *dryRun {
+		opts = append(opts, splitter.WithDryRun())
+	}
+	var reserve int64
+	if *preallocate {
+		reserve = max(maxSizeBytes, chunkBytes)
+	}
+	retry := splitter.Retry{Attempts: *retryOnError, OnRetry: func(op, path string, attempt int, err error) {
+		logWarn(fmt.Sprintf("Could not %s %s: %v; retry %d of %d", op, path, err, attempt, *retryOnError))
+	}}
+	writeBuf := splitter.WriteBufferSize(memBytes, int(bufBytes), openParts)
+	if writeBuf < int(bufBytes) {
+		logWarn(fmt.Sprintf("Write buffers reduced from %s to %s to stay within -max-memory", splitter.SizeString(bufBytes), splitter.SizeString(int64(writeBuf))))
+	}
+	var backend splitter.OutputBackend
+	switch {
+	case remote && !*dryRun:
+		var closeRemote func()
+		if backend, closeRemote, err = openRemote(*outputDir, perm, retry); err != nil {
 			return err
 		}
-		out = f
-		writer = bufio.NewWriterSize(out, bufSize)
-		if !quiet {
-			logInfo("✂️  Creating: " + filename)
+		defer closeRemote()
+		opts = append(opts, splitter.WithOutputBackend(backend))
+	case *fifo:
+		opts = append(opts, splitter.WithSink(splitter.FIFOSink{Mode: perm, BufferSize: writeBuf}))
+	case *atomic:
+		opts = append(opts, splitter.WithOutputBackend(splitter.LocalFS{Mode: perm, Sync: *fsync, BufferSize: writeBuf, Preallocate: reserve, Retry: retry}))
+	default:
+		opts = append(opts, splitter.WithSink(splitter.FileSink{Mode: perm, Sync: *fsync, BufferSize: writeBuf, Preallocate: reserve, Retry: retry}))
+	}
+	if *rmPartial {
+		opts = append(opts, splitter.WithRemovePartial())
+	}
+	if *markPartial {
+		opts = append(opts, splitter.WithMarkPartial())
+	}
+	if (*reportPath != "" || *sumsPath != "" || *inlineManifest) && !*dryRun {
+		opts = append(opts, splitter.WithChecksum(), splitter.WithParallelHash(*parallelHash))
+	}
+	if *inlineManifest {
+		opts = append(opts, splitter.WithTrailer(manifestTrailer(*commentPrefix)))
+	}
+	if *lineStats {
+		opts = append(opts, splitter.WithLineStats())
+	}
+	switch {
+	case bar != nil:
+		opts = append(opts, splitter.WithProgress(bar.update, barInterval))
+	case *progressEvery > 0 && (!*quiet || *cf.logFile != ""):
+		opts = append(opts, splitter.WithProgress(logProgress, *progressEvery))
+	}
+
+	var events *eventPipe
+	if *eventsPath != "" {
+		events = openEventPipe(*eventsPath)
+		defer events.close()
+	}
+	var parts *partLog
+	if *verbose || *veryVerbose {
+		total := sizeHint
+		if *transform != "" || *decode != "" {
+			total = 0
 		}
-		written = 0
-		lineCount = 0
-		part++
-		return nil
+		parts = newPartLog(bar, total, *veryVerbose)
+	}
+	if events != nil || parts != nil {
+		opts = append(opts, splitter.WithPartDone(func(info splitter.PartInfo, stats splitter.PartStats) {
+			events.partReady(info.Index, info.Name)
+			if parts != nil {
+				parts.done(info, stats)
+			}
+		}))
 	}
 
-	err := createNewPart()
+	s, err := splitter.New(opts...)
 	if err != nil {
-		logError("Unable to start: " + err.Error())
-		return
+		return usageErrorf("invalid options:\n%v", err)
 	}
 
-	for {
-		lineBytes, err := reader.ReadSlice('\n')
-		if err == io.EOF {
-			if len(lineBytes) > 0 {
-				if dryRun == false {
-					writer.Write(lineBytes)
-				}
-			}
-			break
+	if sizeHint > 0 && (*dryRun || *confirmAbove > 0) {
+		if sample == nil {
+			sample, input = estimateSample(file, input)
 		}
-		if err != nil {
-			if errors.Is(err, bufio.ErrBufferFull) {
-				if dryRun == false {
-					writer.Write(lineBytes)
-				}
-				continue
+		estimate := s.EstimateParts(sizeHint, sample)
+		if *dryRun && estimate > 0 {
+			con.emit(slog.LevelInfo, fmt.Sprintf("🔍 Estimated ~%d parts from the input size; reading it to count exactly", estimate),
+				"estimate", "parts", estimate)
+		}
+		if !*dryRun && *confirmAbove > 0 {
+			if err := confirmParts(estimate, *confirmAbove, *outputDir, *yes); err != nil {
+				return err
 			}
-			logError("Error reading line: " + err.Error())
-			break
 		}
+	}
+	if !*dryRun {
+		if err := makeOutputDir(backend, partDir, perm); err != nil {
+			return &splitter.OutputError{Part: *outputDir, Err: err}
+		}
+	}
 
-		if (maxLines > 0 && lineCount >= maxLines) ||
-			(maxSizeBytes > 0 && written+int64(len(lineBytes)) > maxSizeBytes) ||
-			(pattern != nil && pattern.Match(lineBytes)) {
-			err := createNewPart()
-			if err != nil {
-				logError("Failed to create new part: " + err.Error())
-				break
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// After the first signal, a second one kills the process at once.
+		<-ctx.Done()
+		stop()
+	}()
+
+	markDone := *doneFile != "" && !*dryRun
+	if markDone {
+		clearMarkers(*doneFile)
+	}
+	if *sortInput {
+		sortMem := *sortMemory
+		if memBytes > 0 && !explicit["sort-memory"] {
+			// The sort's buffer may grow to twice what it holds.
+			sortMem = fmt.Sprintf("%dB", min(256<<20, memBytes/4))
+		}
+		sorted, err := sortLines(ctx, input, sortMem)
+		if err != nil {
+			return err
+		}
+		defer sorted.Close()
+		input = sorted
+	}
+	if *reverseIndex {
+		if s, err = reverseIndexed(ctx, s, opts, file, input, explicit["seed"]); err != nil {
+			return err
+		}
+	}
+	if parts != nil {
+		// Rates start from here, after any counting pass.
+		parts.last = time.Now()
+	}
+	events.start(inputName)
+	res, err := s.Split(ctx, input)
+	if bar != nil {
+		bar.clear()
+	}
+	if err != nil {
+		events.failed(err)
+		if markDone {
+			if ferr := writeFailedMarker(*doneFile, err); ferr != nil {
+				logWarn(fmt.Sprintf("Could not write %s marker: %v", failedMarker, ferr))
 			}
 		}
-
-		if !dryRun {
-			writer.Write(lineBytes)
+		var cancelErr *splitter.CancelError
+		switch {
+		case errors.As(err, &cancelErr):
+			logInterrupted(cancelErr)
+		case res.Truncated:
+			con.emit(slog.LevelWarn, fmt.Sprintf("Output is incomplete: %d parts written, input processed up to byte %d", len(res.Parts), res.BytesRead),
+				"output incomplete", "parts", len(res.Parts), "bytes_read", res.BytesRead)
 		}
-		lineCount++
-		written += int64(len(lineBytes))
+		return err
 	}
 
-	if !dryRun && writer != nil {
-		writer.Flush()
-		if out != nil {
-			out.Close()
+	if *countOnly {
+		printCounts(res, explicit["count-only"] && !explicit["count"])
+		return nil
+	}
+	if *dryJSON {
+		return printPlan(res)
+	}
+	logSummary(res, *lineStats, *dryRun)
+	if *shuffle > 0 || *sampleRate > 0 {
+		con.emit(slog.LevelInfo, fmt.Sprintf("🎲 Seed %d (pass -seed %d to reproduce this split)", res.Seed, res.Seed), "seed", "seed", res.Seed)
+	}
+
+	if *incremental && !*dryRun {
+		state.Offset = stateEnd
+		state.LastPart = firstPart + len(res.Parts) - 1
+		state.Updated = time.Now()
+		if err := saveState(*stateFile, state); err != nil {
+			return &splitter.OutputError{Part: *stateFile, Err: err}
 		}
 	}
 
-	if !quiet {
-		logSuccess("🎉 Done! All parts created.")
+	if *reportPath != "" && !*dryRun {
+		if err := writeReport(*reportPath, res.Parts, *lineStats); err != nil {
+			return &splitter.OutputError{Part: *reportPath, Err: err}
+		}
+		con.emit(slog.LevelInfo, "📊 Report written: "+*reportPath, "report written", "file", *reportPath)
+	}
+	if *sumsPath != "" && !*dryRun {
+		if err := writeSumsFile(*sumsPath, res.Parts); err != nil {
+			return &splitter.OutputError{Part: *sumsPath, Err: err}
+		}
+		con.emit(slog.LevelInfo, "🔐 Checksum file written: "+*sumsPath, "checksum file written", "file", *sumsPath)
+	}
+	if markDone {
+		if err := writeDoneFile(*doneFile, res); err != nil {
+			return &splitter.OutputError{Part: *doneFile, Err: err}
+		}
+		con.emit(slog.LevelInfo, "🏁 Done file written: "+*doneFile, "done file written", "file", *doneFile)
 	}
+	events.done(keptParts(res))
+	return nil
 }
 
-func parseSize(sizeStr string) (int64, error) {
-	if sizeStr == "" {
+// parseSize parses a size flag with splitter.ParseSize; an unset flag is 0.
+func parseSize(s string) (int64, error) {
+	if s == "" {
 		return 0, nil
 	}
-	sizeStr = strings.TrimSpace(strings.ToUpper(sizeStr))
-	re := regexp.MustCompile(`(?i)^(\d+(\.\d+)?)(KB|MB|GB|B)$`)
-	matches := re.FindStringSubmatch(sizeStr)
-	if len(matches) != 4 {
-		return 0, errors.New("invalid size format")
+	return splitter.ParseSize(s)
+}
+
+// compilePattern compiles -pattern, quoted first with -fixed and made
+// case-insensitive with -ignore-case.
+func compilePattern(expr string, fixed, ignoreCase bool) (*regexp.Regexp, error) {
+	if fixed {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// parseWindow parses a -time-window: a duration such as 1h or 15m, or a
+// number of days such as 1d.
+func parseWindow(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid window %q: expected a whole number of days such as 1d", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid window %q: expected a positive duration such as 1h or 1d", s)
+	}
+	return d, nil
+}
+
+// parseSchedule parses a -size-schedule: sizes separated by commas, with a
+// trailing "..." to hold the last size rather than start over.
+func parseSchedule(s string) (sizes []int64, hold bool, err error) {
+	s, hold = strings.CutSuffix(strings.TrimSpace(s), "...")
+	for _, entry := range strings.Split(s, ",") {
+		n, err := parseSize(entry)
+		if err != nil {
+			return nil, false, err
+		}
+		if n <= 0 {
+			return nil, false, fmt.Errorf("invalid size %q: every entry must be above zero", entry)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, hold, nil
+}
+
+// rereadable reports whether input is the regular file it was opened
+// from, which -reverse-index can rewind to read twice.
+func rereadable(file *os.File, input io.Reader) bool {
+	if file == nil || input != io.Reader(file) {
+		return false
 	}
+	stat, err := file.Stat()
+	return err == nil && stat.Mode().IsRegular()
+}
 
-	num, err := strconv.ParseFloat(matches[1], 64)
+// reverseIndexed counts the parts s would create from input with a quiet
+// first pass, rewinds file, and returns a Splitter for opts that numbers
+// that many parts from last to first. Sampling and shuffling keep the seed
+// of the first pass so the count holds.
+func reverseIndexed(ctx context.Context, s *splitter.Splitter, opts []splitter.Option, file *os.File, input io.Reader, seeded bool) (*splitter.Splitter, error) {
+	start, err := file.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return 0, err
-	}
-
-	switch matches[3] {
-	case "B":
-		return int64(num), nil
-	case "KB":
-		return int64(num * 1024), nil
-	case "MB":
-		return int64(num * 1024 * 1024), nil
-	case "GB":
-		return int64(num * 1024 * 1024 * 1024), nil
-	default:
-		return 0, errors.New("unknown size unit")
+		return nil, &splitter.InputError{Err: err}
+	}
+	res, err := s.Count(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return nil, &splitter.InputError{Err: err}
+	}
+	con.emit(slog.LevelInfo, fmt.Sprintf("🔢 Counted %d parts, numbering them from last to first", len(res.Parts)),
+		"parts counted", "parts", len(res.Parts))
+	opts = append(opts[:len(opts):len(opts)], splitter.WithReverseIndex(len(res.Parts)))
+	if !seeded && res.Seed != 0 {
+		opts = append(opts, splitter.WithSeed(res.Seed))
+	}
+	s, err = splitter.New(opts...)
+	if err != nil {
+		return nil, usageErrorf("invalid options:\n%v", err)
 	}
+	return s, nil
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// manifestEntry describes one part in the serve manifest and in
+// -inline-manifest.
+type manifestEntry struct {
+	Name      string `json:"name"`
+	Lines     int64  `json:"lines"`
+	Bytes     int64  `json:"bytes"`
+	StartLine int64  `json:"start_line"`
+	EndLine   int64  `json:"end_line"`
+	SHA256    string `json:"sha256,omitempty"`
+	Capture   string `json:"capture,omitempty"` // the -pattern group value behind the name
+	Pruned    bool   `json:"pruned,omitempty"`  // deleted by -prune-empty, whose number -no-renumber skipped
+}
+
+// manifestEntries lists the parts by base name, including those pruned
+// without renumbering.
+func manifestEntries(parts []splitter.PartStats) []manifestEntry {
+	entries := make([]manifestEntry, 0, len(parts))
+	for _, p := range parts {
+		entries = append(entries, manifestEntry{path.Base(p.Name), p.Lines, p.Bytes, p.StartLine, p.EndLine, p.Checksum, p.Capture, p.Pruned})
+	}
+	return entries
+}
+
+// manifestFile is the serve manifest.json: the version of filesplitter
+// that wrote it and the parts.
+type manifestFile struct {
+	Version string          `json:"version"`
+	Parts   []manifestEntry `json:"parts"`
+}
+
+// manifestTrailer returns a splitter.WithTrailer function that renders the
+// manifest as lines starting with prefix: a count of the parts kept and
+// their lines, then one JSON object per part.
+func manifestTrailer(prefix string) func([]splitter.PartStats) []byte {
+	return func(parts []splitter.PartStats) []byte {
+		entries := manifestEntries(parts)
+		var kept int
+		var lines int64
+		for _, e := range entries {
+			if !e.Pruned {
+				kept++
+				lines += e.Lines
+			}
+		}
+		out := fmt.Appendf(nil, "%s filesplitter %s manifest: %d parts, %d lines\n", prefix, version, kept, lines)
+		for _, e := range entries {
+			b, _ := json.Marshal(e)
+			out = fmt.Appendf(out, "%s %s\n", prefix, b)
+		}
+		return out
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// TestInlineManifestPruned checks that with -prune-empty -no-renumber the
+// inline manifest lists the pruned part, marked as such, but doesn't count
+// it.
+func TestInlineManifestPruned(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "input.txt")
+	// Without its line ending, the blank line leaves its part empty.
+	if err := os.WriteFile(in, []byte("a\n\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	res := runCLI(t, dir, nil, "split", "-in", in, "-lines", "1", "-strip-newline", "-outdir", out, "-prune-empty", "-no-renumber", "-inline-manifest")
+	if res.code != exitOK {
+		t.Fatalf("split exited %d: %s", res.code, res.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(out, "part002.txt")); !os.IsNotExist(err) {
+		t.Errorf("empty part002.txt was not pruned: %v", err)
+	}
+	last, err := os.ReadFile(filepath.Join(out, "part003.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, manifest, ok := strings.Cut(string(last), "# filesplitter ")
+	if !ok {
+		t.Fatalf("part003.txt has no manifest:\n%s", last)
+	}
+	lines := strings.Split(strings.TrimSuffix(manifest, "\n"), "\n")
+	if want := version + " manifest: 2 parts, 2 lines"; lines[0] != want {
+		t.Errorf("manifest header %q, want %q", lines[0], want)
+	}
+	var entries []manifestEntry
+	for _, line := range lines[1:] {
+		var e manifestEntry
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "# ")), &e); err != nil {
+			t.Fatalf("manifest line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	want := []struct {
+		name   string
+		pruned bool
+	}{{"part001.txt", false}, {"part002.txt", true}, {"part003.txt", false}}
+	if len(entries) != len(want) {
+		t.Fatalf("manifest lists %+v, want %d parts", entries, len(want))
+	}
+	for i, w := range want {
+		if entries[i].Name != w.name || entries[i].Pruned != w.pruned {
+			t.Errorf("manifest entry %d is %s, pruned %t, want %s, pruned %t", i, entries[i].Name, entries[i].Pruned, w.name, w.pruned)
+		}
+	}
+	if !strings.Contains(lines[2], `"pruned":true`) || strings.Contains(lines[1]+lines[3], "pruned") {
+		t.Errorf("pruned is not set on the pruned entry alone:\n%s", manifest)
+	}
+}
+
+// TestServeManifestPruned checks that the serve manifest marks pruned
+// parts too.
+func TestServeManifestPruned(t *testing.T) {
+	res := &splitter.Result{Parts: []splitter.PartStats{
+		{Name: "out/part001.txt", Pruned: true},
+		{Name: "out/part002.txt", Lines: 2, Bytes: 4, StartLine: 1, EndLine: 2},
+	}}
+	var file manifestFile
+	if err := json.Unmarshal(manifest(res), &file); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Parts) != 2 || !file.Parts[0].Pruned || file.Parts[1].Pruned {
+		t.Errorf("manifest lists %+v, want part001.txt pruned and part002.txt kept", file.Parts)
+	}
+}
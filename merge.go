@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// runMerge concatenates parts into one file: the PART arguments in order,
+// or else every <prefix>*.<ext> file in -outdir by part index.
+func runMerge(args []string) error {
+	fs := newFlagSet("merge")
+	cf := addCommonFlags(fs)
+	out := fs.String("out", "", "Merged output file, or - for stdout")
+	prefix := fs.String("prefix", "part", "Filename prefix of the parts to merge")
+	ext := fs.String("ext", "txt", "File extension of the parts to merge")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := cf.setup(func() error {
+		if *out == "" {
+			return usageErrorf("-out is required")
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if *out == "-" {
+		// Keep stdout for the merged data.
+		con.quiet = true
+	}
+	parts := fs.Args()
+	if len(parts) == 0 {
+		var err error
+		if parts, err = findParts(*cf.outputDir, *prefix, *ext); err != nil {
+			return &splitter.InputError{Err: err}
+		}
+		if len(parts) == 0 {
+			return &splitter.InputError{Err: fmt.Errorf("no %s*.%s parts in %s", *prefix, *ext, *cf.outputDir)}
+		}
+	}
+
+	var dst io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return &splitter.OutputError{Part: *out, Err: err}
+		}
+		defer f.Close()
+		dst = f
+	}
+	w := bufio.NewWriterSize(dst, 128*1024)
+	var total int64
+	for _, p := range parts {
+		n, err := appendFile(w, p)
+		total += n
+		if err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return &splitter.OutputError{Part: *out, Err: err}
+	}
+	if f, ok := dst.(*os.File); ok && f != os.Stdout {
+		if err := f.Close(); err != nil {
+			return &splitter.OutputError{Part: *out, Err: err}
+		}
+	}
+	con.emit(levelSuccess, fmt.Sprintf("🧩 Merged %d parts (%d bytes) into %s", len(parts), total, *out),
+		"merged", "parts", len(parts), "bytes", total, "file", *out)
+	return nil
+}
+
+// appendFile copies the file at path to w.
+func appendFile(w io.Writer, path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, &splitter.InputError{Err: err}
+	}
+	defer f.Close()
+	n, err := io.Copy(w, readerOnly{f})
+	if err != nil {
+		return n, &splitter.OutputError{Part: path, Err: err}
+	}
+	return n, nil
+}
+
+// readerOnly hides ReaderFrom/WriterTo so io.Copy reports read and write
+// errors through the plain interfaces.
+type readerOnly struct{ io.Reader }
+
+// findParts lists the <prefix>*.<ext> files in dir ordered by the part
+// index after the prefix, so unpadded names still merge in order.
+func findParts(dir, prefix, ext string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, globEscape(prefix)+"*."+globEscape(ext)))
+	if err != nil {
+		return nil, err
+	}
+	index := func(path string) int {
+		name := strings.TrimPrefix(filepath.Base(path), prefix)
+		end := strings.IndexFunc(name, func(r rune) bool { return r < '0' || r > '9' })
+		if end < 0 {
+			end = len(name)
+		}
+		n, err := strconv.Atoi(name[:end])
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := index(matches[i]), index(matches[j])
+		if a != b {
+			return a < b
+		}
+		return matches[i] < matches[j]
+	})
+	con.emit(slog.LevelInfo, fmt.Sprintf("📂 Found %d parts in %s", len(matches), dir), "parts found", "parts", len(matches), "dir", dir)
+	return matches, nil
+}
+
+// globEscape quotes the glob metacharacters in s.
+func globEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`)
+	return r.Replace(s)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// FuzzParseSize checks that size flags never panic and never parse to a
+// negative size. Zero is a valid result, as "0B" and an unset flag both
+// parse to 0; flags that need a positive size check for it themselves.
+func FuzzParseSize(f *testing.F) {
+	for _, s := range []string{"1MB", "0B", "", "1.5GB", "999ZB", "8388607TB", "8388608TB", "1.GB", ".5MB", "-1KB", " 2 gib "} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		n, err := parseSize(s)
+		if err == nil && n < 0 {
+			t.Fatalf("parseSize(%q) = %d, want a non-negative size", s, n)
+		}
+	})
+}
+
+// FuzzPattern checks that compiling any -pattern, with or without -fixed
+// and -ignore-case, and splitting by it never panics, and that -fixed
+// compiles any valid UTF-8.
+func FuzzPattern(f *testing.F) {
+	for _, s := range []string{"^START", "END$", "---END---", "a|b", "(?i)x", "[", `\`, "(?P<name>\\w+)", "\xff"} {
+		f.Add(s, false, false)
+	}
+	f.Add("a.b*", true, true)
+	f.Fuzz(func(t *testing.T, expr string, fixed, ignoreCase bool) {
+		re, err := compilePattern(expr, fixed, ignoreCase)
+		if err != nil {
+			if fixed && utf8.ValidString(expr) {
+				t.Fatalf("compilePattern(%q) with -fixed: %v", expr, err)
+			}
+			return
+		}
+		sp, err := splitter.New(splitter.WithPattern(re), splitter.WithDryRun())
+		if err != nil {
+			t.Fatal(err)
+		}
+		input := "START\n" + expr + "\nx " + expr + " y\r\n---END---\nend"
+		if _, err := sp.Split(context.Background(), strings.NewReader(input)); err != nil {
+			t.Fatalf("split by %q: %v", expr, err)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// startProfiles starts a CPU profile written to cpuPath and arranges for a
+// heap profile to be written to memPath, either of which may be empty.
+// The returned stop writes them out; call it on every way out of the
+// command so an early exit still leaves usable profiles.
+func startProfiles(cpuPath, memPath string) (stop func(), err error) {
+	var cpu *os.File
+	if cpuPath != "" {
+		if cpu, err = os.Create(cpuPath); err != nil {
+			return nil, &splitter.OutputError{Part: cpuPath, Err: err}
+		}
+		if err := pprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, &splitter.OutputError{Part: cpuPath, Err: err}
+		}
+	}
+	return func() {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				logWarn(fmt.Sprintf("Could not write CPU profile %s: %v", cpuPath, err))
+			}
+		}
+		if memPath != "" {
+			if err := writeHeapProfile(memPath); err != nil {
+				logWarn(fmt.Sprintf("Could not write memory profile %s: %v", memPath, err))
+			}
+		}
+	}, nil
+}
+
+// writeHeapProfile writes the heap profile as of the last garbage
+// collection, after forcing one so it is up to date.
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
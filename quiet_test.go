@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestQuiet checks that -q prints nothing on stdout, leaving warnings and
+// errors on stderr.
+func TestQuiet(t *testing.T) {
+	dir := t.TempDir()
+	input := writeInput(t, dir, 100)
+	empty := filepath.Join(dir, "empty.txt")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		stdin  string
+		args   []string
+		code   int
+		stderr string // expected on stderr; "" for nothing
+	}{
+		{"success", "", []string{"-in", input, "-lines", "30", "-outdir", filepath.Join(dir, "file")}, exitOK, ""},
+		{"stdin", "a\nb\nc\n", []string{"-in", "-", "-lines", "1", "-outdir", filepath.Join(dir, "stdin")}, exitOK, ""},
+		{"warning", "", []string{"-in", empty, "-lines", "30", "-outdir", filepath.Join(dir, "empty")}, exitOK, "Input is empty"},
+		{"error", "", []string{"-in", filepath.Join(dir, "missing.txt"), "-lines", "30"}, exitInput, "❌ input error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"split", "-q"}, tt.args...)
+			res := runCLI(t, dir, strings.NewReader(tt.stdin), args...)
+			if res.code != tt.code {
+				t.Fatalf("exit code %d, want %d\nstderr: %s", res.code, tt.code, res.stderr)
+			}
+			if res.stdout != "" {
+				t.Errorf("stdout is not empty:\n%s", res.stdout)
+			}
+			if tt.stderr == "" && res.stderr != "" {
+				t.Errorf("stderr is not empty:\n%s", res.stderr)
+			}
+			if !strings.Contains(res.stderr, tt.stderr) {
+				t.Errorf("stderr %q does not contain %q", res.stderr, tt.stderr)
+			}
+		})
+	}
+	names, _ := filepath.Glob(filepath.Join(dir, "file", "*"))
+	if len(names) != 4 {
+		t.Errorf("quiet split wrote %v, want 4 parts", names)
+	}
+}
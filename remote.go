@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// isRemote reports whether -outdir names an s3:// or sftp:// location
+// rather than a local directory.
+func isRemote(outdir string) bool {
+	return strings.HasPrefix(outdir, "s3://") || strings.HasPrefix(outdir, "sftp://")
+}
+
+// remoteDir returns where parts go on the store a remote -outdir names:
+// the key prefix of s3://bucket/prefix, or the path of
+// sftp://[user@]host[:port]/path, which is taken from the login directory
+// when it starts with /~/.
+func remoteDir(outdir string) (string, error) {
+	u, err := url.Parse(outdir)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%s has no host or bucket", outdir)
+	}
+	if u.Scheme == "s3" {
+		return strings.Trim(u.Path, "/"), nil
+	}
+	if rest, ok := strings.CutPrefix(u.Path, "/~"); ok && (rest == "" || rest[0] == '/') {
+		return strings.TrimPrefix(rest, "/"), nil
+	}
+	return u.Path, nil
+}
+
+// openRemote connects to the store a remote -outdir names. S3 takes its
+// credentials, region and endpoint from the environment variables the AWS
+// CLI reads; SFTP runs ssh, which authenticates as it usually would.
+// close ends the connection.
+func openRemote(outdir string, mode os.FileMode, retry splitter.Retry) (b splitter.OutputBackend, close func(), err error) {
+	u, err := url.Parse(outdir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme == "s3" {
+		s := splitter.S3{
+			Bucket:          u.Host,
+			Region:          firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+			Endpoint:        firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			Retry:           retry,
+		}
+		if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+			return nil, nil, usageErrorf("set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY to write to S3")
+		}
+		// Servers other than AWS, such as MinIO, rarely have a host name
+		// per bucket.
+		s.PathStyle = s.Endpoint != ""
+		return s, func() {}, nil
+	}
+	dest := u.Hostname()
+	if u.User != nil {
+		dest = u.User.Username() + "@" + dest
+	}
+	var args []string
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	s, err := splitter.DialSFTP(dest, args...)
+	if err != nil {
+		return nil, nil, &splitter.OutputError{Part: outdir, Err: err}
+	}
+	s.Mode = mode
+	return s, func() { s.Close() }, nil
+}
+
+// makeOutputDir creates dir, the directory parts go in, and its parents,
+// locally when backend is nil and otherwise if backend has directories.
+func makeOutputDir(backend splitter.OutputBackend, dir string, perm os.FileMode) error {
+	if backend == nil {
+		return os.MkdirAll(dir, dirMode(perm))
+	}
+	if m, ok := backend.(interface{ MkdirAll(string) error }); ok {
+		return m.MkdirAll(dir)
+	}
+	return nil
+}
+
+// firstEnv returns the first of the environment variables names that is
+// set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
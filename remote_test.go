@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRemoteDir(t *testing.T) {
+	tests := []struct {
+		outdir, want string
+	}{
+		{"s3://bucket", ""},
+		{"s3://bucket/logs/2024/", "logs/2024"},
+		{"sftp://host/srv/parts", "/srv/parts"},
+		{"sftp://user@host:2222/~/parts", "parts"},
+		{"sftp://host/~", ""},
+		{"sftp://host/~user/parts", "/~user/parts"},
+	}
+	for _, tt := range tests {
+		if got, err := remoteDir(tt.outdir); err != nil || got != tt.want {
+			t.Errorf("remoteDir(%q) = %q, %v, want %q", tt.outdir, got, err, tt.want)
+		}
+	}
+	if _, err := remoteDir("s3:///parts"); err == nil {
+		t.Error("remoteDir accepted an s3 URL without a bucket")
+	}
+}
+
+// s3Bucket is a bucket served over the S3 API's single-request calls,
+// enough for parts smaller than a multipart upload part.
+type s3Bucket struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (b *s3Bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch r.Method {
+	case http.MethodPut:
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			b.objects[key] = b.objects[strings.TrimPrefix(src, "/bucket/")]
+			io.WriteString(w, "<CopyObjectResult></CopyObjectResult>")
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		b.objects[key] = string(body)
+	case http.MethodHead:
+		w.Header().Set("Content-Length", "0")
+	case http.MethodDelete:
+		delete(b.objects, key)
+	}
+}
+
+// TestS3Output splits to an s3:// -outdir served by a fake endpoint.
+func TestS3Output(t *testing.T) {
+	bucket := &s3Bucket{objects: map[string]string{}}
+	srv := httptest.NewServer(bucket)
+	defer srv.Close()
+	dir := t.TempDir()
+	in := writeInput(t, dir, 25)
+	cmd := cliCommand(dir, "split", "-in", in, "-lines", "10", "-outdir", "s3://bucket/parts/")
+	cmd.Env = append(cmd.Env, "AWS_ACCESS_KEY_ID=id", "AWS_SECRET_ACCESS_KEY=secret", "AWS_ENDPOINT_URL="+srv.URL)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("split failed: %v\n%s", err, out)
+	}
+	if len(bucket.objects) != 3 {
+		t.Errorf("bucket holds %v, want 3 parts", bucket.objects)
+	}
+	if got, want := bucket.objects["parts/part003.txt"], "line 21\nline 22\nline 23\nline 24\nline 25\n"; got != want {
+		t.Errorf("parts/part003.txt holds %q, want %q", got, want)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("split wrote locally: %v", entries)
+	}
+}
+
+// TestRemoteOutputErrors checks the usage errors of a remote -outdir, and
+// that a failed ssh connection is an output error with ssh's message.
+func TestRemoteOutputErrors(t *testing.T) {
+	dir := t.TempDir()
+	in := writeInput(t, dir, 5)
+	tests := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{"-outdir", "s3://bucket"}, exitUsage, "AWS_ACCESS_KEY_ID"},
+		{[]string{"-outdir", "s3://bucket", "-fsync"}, exitUsage, "need a local -outdir"},
+		{[]string{"-outdir", "sftp://host/parts", "-append"}, exitUsage, "need a local -outdir"},
+		{[]string{"-outdir", "sftp:///parts"}, exitUsage, "no host"},
+		{[]string{"-outdir", "sftp://nobody@host:2222/parts"}, exitOutput, "Permission denied (publickey)"},
+	}
+	if runtime.GOOS == "windows" {
+		tests = tests[:len(tests)-1] // the fake ssh is a shell script
+	}
+	// An ssh that refuses every connection.
+	bin := filepath.Join(dir, "bin")
+	os.Mkdir(bin, 0o755)
+	script := "#!/bin/sh\necho 'nobody@host: Permission denied (publickey).' >&2\nexit 255\n"
+	if err := os.WriteFile(filepath.Join(bin, "ssh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		cmd := cliCommand(dir, append([]string{"split", "-in", in, "-lines", "2"}, tt.args...)...)
+		cmd.Env = append(cmd.Env, "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+		out, _ := cmd.CombinedOutput()
+		if code := cmd.ProcessState.ExitCode(); code != tt.code || !strings.Contains(string(out), tt.want) {
+			t.Errorf("%v exited %d with\n%s\nwant %d and %q", tt.args, code, out, tt.code, tt.want)
+		}
+	}
+}
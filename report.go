@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// writeReport writes one row per part to path as CSV, or as TSV when the
+// file has a .tsv extension. Line-length columns are added when lineStats
+// is set, and a pruned column when any part was pruned but kept its number.
+func writeReport(path string, parts []splitter.PartStats, lineStats bool) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		w.Comma = '\t'
+	}
+	header := []string{"filename", "lines", "bytes", "start_line", "end_line", "sha256"}
+	if lineStats {
+		header = append(header, "min_line_len", "max_line_len", "mean_line_len", "stddev_line_len")
+	}
+	pruned := false
+	for _, p := range parts {
+		pruned = pruned || p.Pruned
+	}
+	if pruned {
+		header = append(header, "pruned")
+	}
+	w.Write(header)
+	for _, p := range parts {
+		row := []string{
+			p.Name,
+			strconv.FormatInt(p.Lines, 10),
+			strconv.FormatInt(p.Bytes, 10),
+			strconv.FormatInt(p.StartLine, 10),
+			strconv.FormatInt(p.EndLine, 10),
+			p.Checksum,
+		}
+		if lineStats {
+			row = append(row,
+				strconv.Itoa(p.MinLineLen),
+				strconv.Itoa(p.MaxLineLen),
+				strconv.FormatFloat(p.MeanLineLen, 'f', 2, 64),
+				strconv.FormatFloat(p.StddevLineLen, 'f', 2, 64),
+			)
+		}
+		if pruned {
+			row = append(row, strconv.FormatBool(p.Pruned))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// serve runs the HTTP service mode. POST /split streams the request body
+// through the splitter and answers with a tar of the parts followed by a
+// manifest.json entry. Split criteria come from query parameters named
+// after the CLI flags: lines, size, bytes, pattern, slug, prefix, ext and pad.
+// Request bodies over maxBody bytes fail, unless it is 0.
+func serve(addr string, maxBody int64) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/split", splitHandler(maxBody))
+	logInfo("🌐 Serving on " + addr)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return srv.ListenAndServe()
+}
+
+// splitHandler returns handleSplit with request bodies limited to maxBody
+// bytes, unless it is 0. A body declared larger is refused up front; one
+// of unknown length fails the split once it passes the limit.
+func splitHandler(maxBody int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if maxBody > 0 {
+			if r.ContentLength > maxBody {
+				http.Error(w, fmt.Sprintf("request body is over the %d-byte limit", maxBody), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+		}
+		handleSplit(w, r)
+	}
+}
+
+func handleSplit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	opts, err := queryOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Parts are spooled to disk until they are complete, as a tar header
+	// needs the entry's size.
+	dir, err := os.MkdirTemp("", "filesplitter-serve-")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+	sink := &tarSink{tw: tar.NewWriter(w), dir: dir}
+	opts = append(opts, splitter.WithSink(sink), splitter.WithChecksum(), splitter.WithLogger(cliLogger{}))
+	s, err := splitter.New(opts...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Trailer", "X-Split-Error")
+	res, err := s.Split(r.Context(), r.Body)
+	if err == nil {
+		err = sink.add("manifest.json", manifest(res))
+	}
+	if err == nil {
+		err = sink.tw.Close()
+	}
+	if err != nil {
+		// The status is already sent, so the failure goes in a trailer.
+		w.Header().Set("X-Split-Error", err.Error())
+		logWarn(fmt.Sprintf("Split for %s failed: %v", r.RemoteAddr, err))
+	}
+}
+
+// queryOptions builds split options from the request's query parameters.
+func queryOptions(r *http.Request) ([]splitter.Option, error) {
+	q := r.URL.Query()
+	var opts []splitter.Option
+	if v := q.Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lines %q", v)
+		}
+		opts = append(opts, splitter.WithMaxLines(n))
+	}
+	for _, key := range []string{"size", "bytes"} {
+		v := q.Get(key)
+		if v == "" {
+			continue
+		}
+		n, err := parseSize(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", key, err)
+		}
+		if key == "size" {
+			opts = append(opts, splitter.WithMaxSize(n))
+		} else {
+			opts = append(opts, splitter.WithByteChunks(n))
+		}
+	}
+	if v := q.Get("pattern"); v != "" {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %v", err)
+		}
+		opts = append(opts, splitter.WithPattern(re))
+	}
+	if v := q.Get("slug"); v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slug %q", v)
+		}
+		if on {
+			opts = append(opts, splitter.WithSlug())
+		}
+	}
+	pad := 3
+	if v := q.Get("pad"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pad %q", v)
+		}
+		pad = n
+	}
+	prefix, ext := q.Get("prefix"), q.Get("ext")
+	if prefix == "" {
+		prefix = "part"
+	}
+	if ext == "" {
+		ext = "txt"
+	}
+	if q.Get("lines")+q.Get("size")+q.Get("bytes")+q.Get("pattern") == "" {
+		return nil, errors.New("one of lines, size, bytes or pattern is required")
+	}
+	return append(opts, splitter.WithNaming(prefix, ext, pad)), nil
+}
+
+// manifest renders the per-part stats of res as JSON.
+func manifest(res *splitter.Result) []byte {
+	b, _ := json.MarshalIndent(manifestFile{version, manifestEntries(res.Parts)}, "", "  ")
+	return append(b, '\n')
+}
+
+// tarSink writes each part into a tar stream. A tar header needs the
+// entry's size, so every part is written to a file in dir first and copied
+// into the stream once it is complete.
+type tarSink struct {
+	tw  *tar.Writer
+	dir string
+}
+
+func (s *tarSink) NewPart(meta splitter.PartInfo) (io.WriteCloser, error) {
+	f, err := os.CreateTemp(s.dir, "part-")
+	if err != nil {
+		return nil, err
+	}
+	return &tarPart{f: f, sink: s, name: path.Base(meta.Name)}, nil
+}
+
+func (s *tarSink) add(name string, data []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := s.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := s.tw.Write(data)
+	return err
+}
+
+type tarPart struct {
+	f    *os.File
+	sink *tarSink
+	name string
+}
+
+func (p *tarPart) Write(b []byte) (int, error) { return p.f.Write(b) }
+
+// ClosePartial drops a part the split could not complete, keeping it out
+// of the archive.
+func (p *tarPart) ClosePartial() error {
+	defer os.Remove(p.f.Name())
+	return p.f.Close()
+}
+
+func (p *tarPart) Close() error {
+	defer os.Remove(p.f.Name())
+	defer p.f.Close()
+	size, err := p.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := p.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: p.name, Mode: 0o644, Size: size, ModTime: time.Now()}
+	if err := p.sink.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(p.sink.tw, p.f)
+	return err
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// serveSplit posts body to the split handler with query and returns the
+// response and the tar entries it holds, by name.
+func serveSplit(t *testing.T, query, body string) (*http.Response, map[string]string) {
+	t.Helper()
+	return serveSplitLimited(t, 0, query, body)
+}
+
+// serveSplitLimited is serveSplit with request bodies limited to maxBody
+// bytes. A negative maxBody sends the body without its length.
+func serveSplitLimited(t *testing.T, maxBody int64, query, body string) (*http.Response, map[string]string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/split?"+query, strings.NewReader(body))
+	if maxBody < 0 {
+		maxBody, req.ContentLength = -maxBody, -1
+	}
+	rec := httptest.NewRecorder()
+	splitHandler(maxBody)(rec, req)
+	resp := rec.Result()
+	entries := map[string]string{}
+	if resp.StatusCode != http.StatusOK {
+		return resp, entries
+	}
+	tr := tar.NewReader(resp.Body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading tar: %v", err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("reading %s: %v", hdr.Name, err)
+		}
+		entries[hdr.Name] = string(b)
+	}
+	return resp, entries
+}
+
+func TestServeSlugManifest(t *testing.T) {
+	body := "pre\n== Chapter 1: The Start ==\na\n== Appendix ==\nb\n"
+	resp, entries := serveSplit(t, url.Values{"pattern": {`^==+(?P<title>.+)==$`}, "slug": {"true"}}.Encode(), body)
+	if resp.StatusCode != http.StatusOK || resp.Trailer.Get("X-Split-Error") != "" {
+		t.Fatalf("status %d, error %q", resp.StatusCode, resp.Trailer.Get("X-Split-Error"))
+	}
+	var file manifestFile
+	if err := json.Unmarshal([]byte(entries["manifest.json"]), &file); err != nil {
+		t.Fatalf("manifest.json: %v", err)
+	}
+	if file.Version != version {
+		t.Errorf("manifest version %q, want %q", file.Version, version)
+	}
+	manifest := file.Parts
+	want := map[string]string{
+		"part001.txt":                  "",
+		"part_chapter-1-the-start.txt": " Chapter 1: The Start ",
+		"part_appendix.txt":            " Appendix ",
+	}
+	if len(manifest) != len(want) {
+		t.Fatalf("manifest lists %d parts, want %d: %+v", len(manifest), len(want), manifest)
+	}
+	for _, e := range manifest {
+		capture, ok := want[e.Name]
+		if !ok || e.Capture != capture {
+			t.Errorf("manifest entry %s has capture %q, want %q", e.Name, e.Capture, capture)
+		}
+		if _, ok := entries[e.Name]; !ok {
+			t.Errorf("%s is in the manifest but not in the archive", e.Name)
+		}
+	}
+}
+
+func TestServeBadQuery(t *testing.T) {
+	for _, query := range []string{"", "slug=true", "lines=x", "pattern=[", "lines=3&slug=maybe", "lines=3&slug=true"} {
+		resp, _ := serveSplit(t, query, "a\n")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want %d", query, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+// TestServeSpoolsParts checks that parts larger than any buffer arrive
+// whole, and that the files they were spooled to are removed.
+func TestServeSpoolsParts(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	line := strings.Repeat("x", 1023) + "\n"
+	body := strings.Repeat(line, 5<<10) // 5 MB
+	resp, entries := serveSplit(t, "size=2MB", body)
+	if resp.StatusCode != http.StatusOK || resp.Trailer.Get("X-Split-Error") != "" {
+		t.Fatalf("status %d, error %q", resp.StatusCode, resp.Trailer.Get("X-Split-Error"))
+	}
+	want := []string{strings.Repeat(line, 2<<10), strings.Repeat(line, 2<<10), strings.Repeat(line, 1<<10)}
+	for i, w := range want {
+		name := []string{"part001.txt", "part002.txt", "part003.txt"}[i]
+		if entries[name] != w {
+			t.Errorf("%s holds %d bytes, want %d", name, len(entries[name]), len(w))
+		}
+	}
+	if left, _ := os.ReadDir(tmp); len(left) > 0 {
+		t.Errorf("left in the temporary directory: %v", left)
+	}
+}
+
+func TestServeMaxBody(t *testing.T) {
+	body := strings.Repeat("line\n", 100)
+	resp, _ := serveSplitLimited(t, 100, "lines=1000", body)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+	resp, entries := serveSplitLimited(t, int64(len(body)), "lines=1000", body)
+	if resp.StatusCode != http.StatusOK || entries["part001.txt"] != body {
+		t.Errorf("at the limit: status %d, error %q", resp.StatusCode, resp.Trailer.Get("X-Split-Error"))
+	}
+	// Without a length, the failure can only go in the trailer.
+	resp, entries = serveSplitLimited(t, -100, "lines=5", body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Trailer.Get("X-Split-Error"), "request body too large") {
+		t.Errorf("unknown length: status %d, error %q", resp.StatusCode, resp.Trailer.Get("X-Split-Error"))
+	}
+	if _, ok := entries["manifest.json"]; ok {
+		t.Error("a failed split has a manifest")
+	}
+}
@@ -0,0 +1,150 @@
+package splitter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// OutputBackend is a storage system parts can be written to. Backends for
+// object stores or remote filesystems only need to implement these two
+// calls to be used through BackendSink. LocalFS, S3 and SFTP are provided.
+type OutputBackend interface {
+	Create(path string) (io.WriteCloser, error)
+	Rename(oldPath, newPath string) error
+}
+
+// LocalFS is the OutputBackend for the local filesystem.
+type LocalFS struct {
+	// Mode is the permission of created files. When zero, files are
+	// created with 0666 before umask, as os.Create does.
+	Mode os.FileMode
+	// Sync fsyncs each file before Close returns, and the directory after
+	// each Rename, trading throughput for durability.
+	Sync bool
+	// BufferSize is the size of each file's write buffer; zero means 128KB.
+	BufferSize int
+	// Preallocate reserves this many bytes for each file when it is
+	// created, where the filesystem supports it, and trims the file to
+	// what was written on Close. Running out of space then fails at
+	// Create rather than partway through the file.
+	Preallocate int64
+	// Retry retries creating and writing files that fail transiently.
+	Retry Retry
+}
+
+// Create creates path and buffers writes to it.
+func (l LocalFS) Create(path string) (io.WriteCloser, error) {
+	mode := l.Mode
+	if mode == 0 {
+		mode = 0o666
+	}
+	var f *os.File
+	err := l.Retry.do("create", path, func() (err error) {
+		f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// OpenFile leaves the mode of an existing file alone, so set it
+	// explicitly when one was asked for.
+	if l.Mode != 0 {
+		if err := f.Chmod(l.Mode); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if l.Preallocate > 0 {
+		if err := preallocate(f, l.Preallocate); err != nil {
+			f.Close()
+			os.Remove(path)
+			return nil, err
+		}
+	}
+	size := l.BufferSize
+	if size <= 0 {
+		size = bufSize
+	}
+	return &bufferedFile{Writer: bufio.NewWriterSize(fileWriter(f, l.Retry), size), f: f, sync: l.Sync, trim: l.Preallocate > 0}, nil
+}
+
+// Rename moves oldPath to newPath.
+func (l LocalFS) Rename(oldPath, newPath string) error {
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	if l.Sync {
+		return syncDir(filepath.Dir(newPath))
+	}
+	return nil
+}
+
+// Remove deletes path.
+func (LocalFS) Remove(path string) error {
+	return os.Remove(path)
+}
+
+// partialSuffix marks a part that is still being written.
+const partialSuffix = ".partial"
+
+// BackendSink returns a PartSink that writes each part to b under a
+// temporary ".partial" name and renames it into place once the part is
+// closed, so readers never see an incomplete part under its final name.
+// Incomplete parts are removed on abort, and completed parts can be
+// removed, if b has a Remove(path) error method.
+func BackendSink(b OutputBackend) PartSink {
+	return backendSink{b: b}
+}
+
+type backendSink struct {
+	b OutputBackend
+}
+
+func (s backendSink) NewPart(meta PartInfo) (io.WriteCloser, error) {
+	w, err := s.b.Create(meta.Name + partialSuffix)
+	if err != nil {
+		return nil, err
+	}
+	return &renameOnClose{WriteCloser: w, b: s.b, name: meta.Name}, nil
+}
+
+func (s backendSink) Abort(meta PartInfo) error {
+	if r, ok := s.b.(interface{ Remove(string) error }); ok {
+		return r.Remove(meta.Name + partialSuffix)
+	}
+	return nil
+}
+
+// MarkPartial leaves the part where it was written, under its partial
+// name.
+func (s backendSink) MarkPartial(meta PartInfo) (string, error) {
+	return meta.Name + partialSuffix, nil
+}
+
+func (s backendSink) Remove(meta PartInfo) error {
+	if r, ok := s.b.(interface{ Remove(string) error }); ok {
+		return r.Remove(meta.Name)
+	}
+	return fmt.Errorf("cannot remove %s: the output backend has no Remove method", meta.Name)
+}
+
+// renameOnClose moves a finished part from its temporary to its final name.
+type renameOnClose struct {
+	io.WriteCloser
+	b    OutputBackend
+	name string
+}
+
+func (r *renameOnClose) Close() error {
+	if err := r.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return r.b.Rename(r.name+partialSuffix, r.name)
+}
+
+func (r *renameOnClose) ClosePartial() error {
+	return r.WriteCloser.Close()
+}
@@ -0,0 +1,142 @@
+package splitter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ColumnSelector projects delimited lines down to a subset of columns.
+type ColumnSelector struct {
+	cols   []int // zero-based, in output order
+	inSep  rune
+	outSep rune
+}
+
+// NewColumnSelector builds a selector from a 1-based column list such as
+// "1,3,5" and single-character input and output separators. An empty
+// outSep reuses inSep.
+func NewColumnSelector(spec, inSep, outSep string) (*ColumnSelector, error) {
+	in, out, err := parseSeps(inSep, outSep)
+	if err != nil {
+		return nil, err
+	}
+
+	var cols []int
+	for _, f := range strings.Split(spec, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid column %q: columns are 1-based integers", f)
+		}
+		cols = append(cols, n-1)
+	}
+	return &ColumnSelector{cols: cols, inSep: in, outSep: out}, nil
+}
+
+// NewColumnGroups builds one selector per comma-separated group of a spec
+// such as "1-3,5", for use WithVertical. Each group is a 1-based column or
+// an inclusive range of columns.
+func NewColumnGroups(spec, inSep, outSep string) ([]*ColumnSelector, error) {
+	in, out, err := parseSeps(inSep, outSep)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*ColumnSelector
+	for _, g := range strings.Split(spec, ",") {
+		g = strings.TrimSpace(g)
+		first, last, isRange := strings.Cut(g, "-")
+		lo, err := strconv.Atoi(first)
+		hi := lo
+		if err == nil && isRange {
+			hi, err = strconv.Atoi(last)
+		}
+		if err != nil || lo < 1 || hi < lo {
+			return nil, fmt.Errorf("invalid column group %q: use a 1-based column or range such as 2-4", g)
+		}
+		sel := &ColumnSelector{inSep: in, outSep: out}
+		for n := lo; n <= hi; n++ {
+			sel.cols = append(sel.cols, n-1)
+		}
+		groups = append(groups, sel)
+	}
+	return groups, nil
+}
+
+func parseSeps(inSep, outSep string) (in, out rune, err error) {
+	if in, err = parseSep(inSep); err != nil {
+		return 0, 0, fmt.Errorf("invalid field separator: %w", err)
+	}
+	out = in
+	if outSep != "" {
+		if out, err = parseSep(outSep); err != nil {
+			return 0, 0, fmt.Errorf("invalid output field separator: %w", err)
+		}
+	}
+	return in, out, nil
+}
+
+func parseSep(s string) (rune, error) {
+	if s == `\t` {
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if s == "" || size != len(s) {
+		return 0, errors.New("separator must be a single character")
+	}
+	return r, nil
+}
+
+// apply returns line with only the selected columns, keeping its line ending.
+// Columns past the end of the record are written as empty fields.
+func (c *ColumnSelector) apply(line []byte) ([]byte, error) {
+	body := trimEOL(line)
+	fields, err := c.fields(body)
+	if err != nil {
+		return nil, err
+	}
+	return append(c.project(fields), line[len(body):]...), nil
+}
+
+// fields parses a line body, without its ending, into a record.
+func (c *ColumnSelector) fields(body []byte) ([]string, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.Comma = c.inSep
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	fields, err := r.Read()
+	if err != nil && len(body) > 0 {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// project formats the selected columns of a record, without a line ending.
+func (c *ColumnSelector) project(fields []string) []byte {
+	selected := make([]string, len(c.cols))
+	for i, col := range c.cols {
+		if col < len(fields) {
+			selected[i] = fields[col]
+		}
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = c.outSep
+	w.Write(selected)
+	w.Flush()
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// maxColumn returns the highest selected 1-based column.
+func (c *ColumnSelector) maxColumn() int {
+	n := 0
+	for _, col := range c.cols {
+		n = max(n, col+1)
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+package splitter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ctxReader makes reads from a pipe or terminal, which may block for as
+// long as the writer is idle, return once ctx is cancelled. Each read runs
+// in a goroutine into a buffer of its own, so one left blocked by a
+// cancellation never touches the caller's buffer.
+type ctxReader struct {
+	ctx     context.Context
+	r       io.Reader
+	idle    time.Duration // return errIdle after waiting this long, if set
+	results chan readResult
+	waiting bool   // a read is in flight
+	rest    []byte // data of the last read not yet returned
+	err     error  // error that ended the input, returned after rest
+}
+
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// errIdle is returned by a ctxReader with an idle limit when no data
+// arrived in time. The read stays in flight, so reading again resumes it.
+var errIdle = errors.New("input idle")
+
+func newCtxReader(ctx context.Context, r io.Reader) *ctxReader {
+	return &ctxReader{ctx: ctx, r: r, results: make(chan readResult, 1)}
+}
+
+// newIdleReader is newCtxReader whose reads also give up with errIdle
+// after waiting idle for data.
+func newIdleReader(ctx context.Context, r io.Reader, idle time.Duration) *ctxReader {
+	c := newCtxReader(ctx, r)
+	c.idle = idle
+	return c
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if len(c.rest) == 0 && c.err != nil {
+		return 0, c.err
+	}
+	if len(c.rest) == 0 {
+		if !c.waiting {
+			c.waiting = true
+			go func(n int) {
+				buf := make([]byte, n)
+				n, err := c.r.Read(buf)
+				c.results <- readResult{buf[:n], err}
+			}(len(p))
+		}
+		var timeout <-chan time.Time
+		if c.idle > 0 {
+			t := time.NewTimer(c.idle)
+			defer t.Stop()
+			timeout = t.C
+		}
+		select {
+		case res := <-c.results:
+			c.waiting = false
+			c.rest, c.err = res.data, res.err
+		case <-c.ctx.Done():
+			return 0, c.ctx.Err()
+		case <-timeout:
+			return 0, errIdle
+		}
+	}
+	n := copy(p, c.rest)
+	c.rest = c.rest[n:]
+	if len(c.rest) == 0 {
+		return n, c.err
+	}
+	return n, nil
+}
@@ -0,0 +1,44 @@
+package splitter
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// decodingReader wraps r so that it yields the decoded form of its base64
+// or hex content. Line breaks in the encoded input are ignored.
+func decodingReader(r io.Reader, encoding string) (io.Reader, error) {
+	r = newlineStripper{r: r}
+	switch encoding {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r), nil
+	case "hex":
+		return hex.NewDecoder(r), nil
+	default:
+		return nil, fmt.Errorf("unknown decoding %q: expected base64 or hex", encoding)
+	}
+}
+
+// newlineStripper drops '\r' and '\n' bytes from the underlying reader.
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (s newlineStripper) Read(p []byte) (int, error) {
+	for {
+		n, err := s.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			if b != '\r' && b != '\n' {
+				p[kept] = b
+				kept++
+			}
+		}
+		// Don't report an empty read while more data may follow.
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package splitter
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// DetectSampleSize is how much of the input Detect is meant to look at.
+const DetectSampleSize = 8192
+
+// Detection holds the input format guessed by Detect.
+type Detection struct {
+	Delimiter string // "," or "\t", empty when the sample isn't delimited
+	Encoding  string // "ascii", "utf-8", "utf-16le" or "unknown"
+	CRLF      bool   // lines end with \r\n rather than \n
+}
+
+// Detect guesses the delimiter, encoding and line ending of the input from
+// a sample of its first bytes.
+func Detect(sample []byte) Detection {
+	d := Detection{Encoding: detectEncoding(sample)}
+	if d.Encoding == "utf-16le" {
+		// The byte-level heuristics below assume an ASCII-compatible encoding.
+		return d
+	}
+	if i := bytes.IndexByte(sample, '\n'); i > 0 {
+		d.CRLF = sample[i-1] == '\r'
+	}
+	d.Delimiter = detectDelimiter(sample)
+	return d
+}
+
+func detectEncoding(sample []byte) string {
+	if bytes.HasPrefix(sample, []byte{0xff, 0xfe}) {
+		return "utf-16le"
+	}
+	// ASCII text in UTF-16LE has a zero in nearly every odd byte.
+	if len(sample) >= 4 {
+		zeros := 0
+		for i := 1; i < len(sample); i += 2 {
+			if sample[i] == 0 {
+				zeros++
+			}
+		}
+		if zeros*10 >= len(sample)/2*9 {
+			return "utf-16le"
+		}
+	}
+	ascii := true
+	for _, b := range sample {
+		if b >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return "ascii"
+	}
+	// The sample may end in the middle of a rune.
+	for i := 1; i < utf8.UTFMax && i <= len(sample); i++ {
+		if utf8.RuneStart(sample[len(sample)-i]) {
+			if !utf8.FullRune(sample[len(sample)-i:]) {
+				sample = sample[:len(sample)-i]
+			}
+			break
+		}
+	}
+	if utf8.Valid(sample) {
+		return "utf-8"
+	}
+	return "unknown"
+}
+
+// detectDelimiter picks the separator that appears the same non-zero number
+// of times on every complete line of the sample. Tabs win ties since they
+// rarely occur in free text.
+func detectDelimiter(sample []byte) string {
+	lines := bytes.Split(sample, []byte{'\n'})
+	if len(lines) > 1 {
+		lines = lines[:len(lines)-1] // the last line may be cut short
+	}
+	for _, sep := range []byte{'\t', ','} {
+		want := -1
+		for _, line := range lines {
+			n := bytes.Count(line, []byte{sep})
+			if want == -1 {
+				want = n
+			}
+			if n == 0 || n != want {
+				want = 0
+				break
+			}
+		}
+		if want > 0 {
+			return string(sep)
+		}
+	}
+	return ""
+}
+
+// Formats reported by DetectFormat.
+const (
+	FormatCSV    = "csv"
+	FormatJSONL  = "jsonl"
+	FormatXML    = "xml"
+	FormatSQL    = "sql"
+	FormatText   = "text"
+	FormatBinary = "binary"
+)
+
+// sqlPrefixes start the lines of a typical SQL dump.
+var sqlPrefixes = []string{"-- ", "CREATE ", "INSERT INTO ", "DROP ", "SET ", "ALTER ", "LOCK TABLES ", "/*!"}
+
+// DetectFormat guesses the kind of file from its name and a sample of its
+// first bytes. Content wins over the extension when the sample is binary;
+// otherwise a known extension decides.
+func DetectFormat(name string, sample []byte) string {
+	if isBinary(sample) {
+		return FormatBinary
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".tsv":
+		return FormatCSV
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	case ".xml":
+		return FormatXML
+	case ".sql":
+		return FormatSQL
+	}
+
+	body := bytes.TrimLeft(sample, " \t\r\n\ufeff")
+	first, _, _ := bytes.Cut(body, []byte{'\n'})
+	first = bytes.TrimSpace(first)
+	switch {
+	case bytes.HasPrefix(body, []byte("<")):
+		return FormatXML
+	case bytes.HasPrefix(first, []byte("{")) && bytes.HasSuffix(first, []byte("}")):
+		return FormatJSONL
+	}
+	for _, p := range sqlPrefixes {
+		if bytes.HasPrefix(bytes.ToUpper(first), []byte(p)) {
+			return FormatSQL
+		}
+	}
+	if detectDelimiter(sample) != "" {
+		return FormatCSV
+	}
+	return FormatText
+}
+
+// isBinary reports whether sample looks like binary data: it holds NUL
+// bytes without being UTF-16, or more than a tenth control characters.
+func isBinary(sample []byte) bool {
+	if detectEncoding(sample) == "utf-16le" {
+		return false
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	control := 0
+	for _, b := range sample {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' {
+			control++
+		}
+	}
+	return control*10 > len(sample)
+}
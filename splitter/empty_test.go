@@ -0,0 +1,49 @@
+package splitter
+
+import (
+	"regexp"
+	"testing"
+)
+
+// TestEmptyInput checks that empty input creates no parts and warns, in
+// every splitting mode, and that WithAllowEmpty creates one empty part.
+func TestEmptyInput(t *testing.T) {
+	modes := []struct {
+		name string
+		opts []Option
+	}{
+		{"lines", []Option{WithMaxLines(10)}},
+		{"size", []Option{WithMaxSize(1 << 10)}},
+		{"size align lines", []Option{WithMaxSize(1 << 10), WithSizeAlignLines()}},
+		{"pattern", []Option{WithPattern(regexp.MustCompile(`^---`))}},
+		{"bytes", []Option{WithByteChunks(1 << 10)}},
+		{"records", []Option{WithRecords(regexp.MustCompile(`^BEGIN`), regexp.MustCompile(`^END`))}},
+	}
+	for _, m := range modes {
+		t.Run(m.name, func(t *testing.T) {
+			log := &captureLogger{}
+			res, sink := splitString(t, "", append([]Option{WithLogger(log)}, m.opts...)...)
+			if len(res.Parts) != 0 || len(sink.parts) != 0 {
+				t.Errorf("got %d parts (%d opened), want none", len(res.Parts), len(sink.parts))
+			}
+			if len(log.matching("Input is empty, no parts created")) != 1 {
+				t.Errorf("no empty input warning in %v", log.events)
+			}
+
+			log = &captureLogger{}
+			res, sink = splitString(t, "", append([]Option{WithLogger(log), WithAllowEmpty()}, m.opts...)...)
+			if len(res.Parts) != 1 || len(sink.parts) != 1 {
+				t.Fatalf("with WithAllowEmpty got %d parts (%d opened), want 1", len(res.Parts), len(sink.parts))
+			}
+			if p := sink.parts[0]; p.buf.Len() != 0 || !p.closed {
+				t.Errorf("part holds %q, closed %v; want it empty and closed", p.buf.String(), p.closed)
+			}
+			if res.Parts[0].Bytes != 0 || res.Parts[0].Lines != 0 {
+				t.Errorf("part stats %d bytes, %d lines; want 0", res.Parts[0].Bytes, res.Parts[0].Lines)
+			}
+			if len(log.matching("Input is empty")) != 0 {
+				t.Error("warned about empty input with WithAllowEmpty")
+			}
+		})
+	}
+}
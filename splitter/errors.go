@@ -0,0 +1,77 @@
+package splitter
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// CancelError is returned when a split is stopped by its context. It
+// records how far the split got before it was interrupted.
+type CancelError struct {
+	BytesRead      int64
+	Lines          int64
+	PartsCompleted int
+	// Partial lists where incomplete parts were left, under the names
+	// WithMarkPartial gave them or their own; empty if they were removed.
+	Partial []string
+	Err     error
+}
+
+func (e *CancelError) Error() string {
+	return fmt.Sprintf("split cancelled after %d bytes, %d lines (%d parts completed): %v", e.BytesRead, e.Lines, e.PartsCompleted, e.Err)
+}
+
+func (e *CancelError) Unwrap() error { return e.Err }
+
+// PartCountError is returned when a split produced a different number of
+// parts than required WithExpectParts. The parts are still written.
+type PartCountError struct {
+	Expected int
+	Actual   int
+}
+
+func (e *PartCountError) Error() string {
+	return fmt.Sprintf("expected %d parts but produced %d", e.Expected, e.Actual)
+}
+
+// InputError is a failure to read or decode the input.
+type InputError struct {
+	Offset int64 // input bytes consumed before the failure
+	Err    error
+}
+
+func (e *InputError) Error() string {
+	if e.Offset == 0 {
+		return fmt.Sprintf("input error: %v", e.Err)
+	}
+	return fmt.Sprintf("input error at byte %d: %v", e.Offset, e.Err)
+}
+
+func (e *InputError) Unwrap() error { return e.Err }
+
+// OutputError is a failure to create, write or close a part.
+type OutputError struct {
+	Part   string // name of the part being written
+	Offset int64  // bytes given to the part before the failing write or close
+	Err    error
+}
+
+func (e *OutputError) Error() string {
+	where := e.Part
+	if e.Offset > 0 {
+		where = fmt.Sprintf("%s at byte %d", e.Part, e.Offset)
+	}
+	if e.DiskFull() {
+		return fmt.Sprintf("output error on %s: the disk is full or over its quota; free some space or write elsewhere (%v)", where, e.Err)
+	}
+	return fmt.Sprintf("output error on %s: %v", where, e.Err)
+}
+
+// DiskFull reports whether the part could not be written because its
+// filesystem ran out of space (ENOSPC) or the user's quota (EDQUOT).
+func (e *OutputError) DiskFull() bool {
+	return errors.Is(e.Err, syscall.ENOSPC) || errors.Is(e.Err, syscall.EDQUOT)
+}
+
+func (e *OutputError) Unwrap() error { return e.Err }
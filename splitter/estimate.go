@@ -0,0 +1,68 @@
+package splitter
+
+import "bytes"
+
+// EstimateParts guesses how many parts splitting an input of size bytes
+// would create, without reading it. sample, the start of the input, gives
+// the average line length for line limits. It returns 0 when no guess can
+// be made: an unknown size, a split by pattern, time window or interval alone, or a
+// transform that changes the size.
+func (s *Splitter) EstimateParts(size int64, sample []byte) int64 {
+	cfg := &s.cfg
+	switch {
+	case size <= 0 || len(cfg.transform) > 0:
+		return 0
+	case cfg.byteChunk > 0:
+		return ceilDiv(size, cfg.byteChunk)
+	case cfg.vertical != nil:
+		return int64(len(cfg.vertical))
+	case cfg.shuffleParts > 0:
+		return int64(cfg.shuffleParts)
+	case cfg.syslog:
+		return int64(len(SyslogLevels))
+	}
+	if cfg.sampleRate > 0 {
+		size = int64(float64(size) * cfg.sampleRate)
+	}
+	var parts int64
+	switch {
+	case len(cfg.sizeSchedule) > 0:
+		parts = cfg.scheduleParts(size)
+	case cfg.maxSize > 0:
+		parts = ceilDiv(size, cfg.maxSize)
+	}
+	if cfg.maxLines > 0 && len(sample) > 0 {
+		lines := size * int64(max(bytes.Count(sample, []byte{'\n'}), 1)) / int64(len(sample))
+		parts = max(parts, ceilDiv(lines, int64(cfg.maxLines)))
+	}
+	if cfg.maxSize == 0 && cfg.maxLines == 0 && cfg.pattern == nil && cfg.timeField == nil && cfg.rotateEvery == 0 {
+		// Nothing rotates: everything goes to one part.
+		return 1
+	}
+	return parts
+}
+
+// scheduleParts counts the parts the size schedule fills with size bytes.
+func (c *config) scheduleParts(size int64) int64 {
+	var cycle, parts int64
+	for _, n := range c.sizeSchedule {
+		cycle += n
+	}
+	if !c.holdSchedule && size > cycle {
+		full := (size - 1) / cycle
+		parts, size = full*int64(len(c.sizeSchedule)), size-full*cycle
+	}
+	for i := 0; size > 0; i++ {
+		n := c.partMaxSize(i)
+		if c.holdSchedule && i >= len(c.sizeSchedule)-1 {
+			return parts + ceilDiv(size, n)
+		}
+		size -= n
+		parts++
+	}
+	return parts
+}
+
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,319 @@
+package splitter
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"slices"
+)
+
+const (
+	// sortLineCost is what each line held in memory costs beyond its
+	// bytes: its start offset and slice header.
+	sortLineCost = 32
+	// sortFanIn is the most runs merged at once. More are first merged
+	// into bigger runs, so open files stay bounded.
+	sortFanIn = 64
+	// MinSortMemory is the least memory SortLines accepts.
+	MinSortMemory = 1 << 20
+)
+
+// SortedReader reads the lines of an input in byte order, as sort does
+// with LC_ALL=C. A final line without a newline gets one.
+type SortedReader struct {
+	dir   string
+	runs  []*os.File // spilled runs, removed on Close
+	mem   [][]byte   // the sorted lines, when they all fit in memory
+	merge *runMerger
+	lines int64
+	buf   []byte
+	err   error
+}
+
+// SortLines reads all of r and returns a reader of its lines in sorted
+// order. Lines are sorted in memory in runs of about memory bytes; when
+// the input is larger, each run is written to a temporary file in dir
+// (os.TempDir if empty) and reading merges them, so dir needs room for a
+// copy of the input. Close removes the files.
+func SortLines(ctx context.Context, r io.Reader, memory int64, dir string) (*SortedReader, error) {
+	if memory < MinSortMemory {
+		return nil, errors.New("sort memory must be at least 1MB")
+	}
+	s := &SortedReader{dir: dir}
+	br := bufio.NewReaderSize(r, bufSize)
+	var arena []byte
+	var starts []int
+	var read int64
+	start := 0
+	for {
+		chunk, err := br.ReadSlice('\n')
+		arena = append(arena, chunk...)
+		read += int64(len(chunk))
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if len(arena) > start {
+			if arena[len(arena)-1] != '\n' {
+				arena = append(arena, '\n')
+			}
+			starts = append(starts, start)
+			start = len(arena)
+			s.lines++
+			if s.lines%4096 == 0 && ctx.Err() != nil {
+				s.Close()
+				return nil, ctx.Err()
+			}
+			if int64(len(arena)+len(starts)*sortLineCost) >= memory {
+				if werr := s.spill(sortRun(arena, starts)); werr != nil {
+					s.Close()
+					return nil, werr
+				}
+				arena, starts, start = arena[:0], starts[:0], 0
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.Close()
+			return nil, &InputError{Offset: read, Err: err}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if len(s.runs) == 0 {
+		s.mem = sortRun(arena, starts)
+		return s, nil
+	}
+	if len(starts) > 0 {
+		if err := s.spill(sortRun(arena, starts)); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	for len(s.runs) > sortFanIn {
+		if err := s.mergeRuns(ctx, sortFanIn); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	m, err := newRunMerger(s.runs)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.merge = m
+	return s, nil
+}
+
+// sortRun returns the lines of arena, which start at starts, sorted.
+func sortRun(arena []byte, starts []int) [][]byte {
+	lines := make([][]byte, len(starts))
+	for i, st := range starts {
+		end := len(arena)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		lines[i] = arena[st:end]
+	}
+	slices.SortFunc(lines, bytes.Compare)
+	return lines
+}
+
+// spill writes sorted lines to a new temporary run.
+func (s *SortedReader) spill(lines [][]byte) error {
+	f, err := s.newRun()
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(f, bufSize)
+	for _, line := range lines {
+		if _, err := w.Write(line); err != nil {
+			return &OutputError{Part: f.Name(), Err: err}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return &OutputError{Part: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// newRun creates a temporary file for a run and adds it to s.runs.
+func (s *SortedReader) newRun() (*os.File, error) {
+	f, err := os.CreateTemp(s.dir, "filesplitter-sort-*")
+	if err != nil {
+		return nil, &OutputError{Part: "sort run", Err: err}
+	}
+	s.runs = append(s.runs, f)
+	return f, nil
+}
+
+// mergeRuns merges the first n runs into one new run.
+func (s *SortedReader) mergeRuns(ctx context.Context, n int) error {
+	merged := s.runs[:n:n]
+	m, err := newRunMerger(merged)
+	if err != nil {
+		return err
+	}
+	s.runs = s.runs[n:]
+	f, err := s.newRun()
+	if err == nil {
+		err = m.writeTo(ctx, f)
+	}
+	removeRuns(merged)
+	return err
+}
+
+// Lines returns the number of lines sorted.
+func (s *SortedReader) Lines() int64 { return s.lines }
+
+// Spilled reports whether the input was too large to sort in memory and
+// went through temporary files.
+func (s *SortedReader) Spilled() bool { return s.merge != nil }
+
+func (s *SortedReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(s.buf) == 0 {
+			if s.err != nil {
+				break
+			}
+			s.buf, s.err = s.next()
+			continue
+		}
+		c := copy(p[n:], s.buf)
+		s.buf = s.buf[c:]
+		n += c
+	}
+	if n == 0 {
+		return 0, s.err
+	}
+	return n, nil
+}
+
+func (s *SortedReader) next() ([]byte, error) {
+	if s.merge != nil {
+		return s.merge.next()
+	}
+	if len(s.mem) == 0 {
+		return nil, io.EOF
+	}
+	line := s.mem[0]
+	s.mem = s.mem[1:]
+	return line, nil
+}
+
+// Close removes the temporary runs.
+func (s *SortedReader) Close() error {
+	s.mem, s.merge = nil, nil
+	removeRuns(s.runs)
+	s.runs = nil
+	return nil
+}
+
+func removeRuns(runs []*os.File) {
+	for _, f := range runs {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+// runMerger merges sorted runs, yielding their lines in order.
+type runMerger struct {
+	readers []*bufio.Reader
+	names   []string
+	heads   runHeap
+}
+
+func newRunMerger(runs []*os.File) (*runMerger, error) {
+	m := &runMerger{}
+	for i, f := range runs {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, &OutputError{Part: f.Name(), Err: err}
+		}
+		m.readers = append(m.readers, bufio.NewReaderSize(f, bufSize))
+		m.names = append(m.names, f.Name())
+		if err := m.pull(i); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// pull queues the next line of run i, if it has one.
+func (m *runMerger) pull(i int) error {
+	line, err := m.readers[i].ReadBytes('\n')
+	if len(line) > 0 {
+		heap.Push(&m.heads, runHead{line: line, run: i})
+	}
+	if err != nil && err != io.EOF {
+		return &InputError{Err: &os.PathError{Op: "read", Path: m.names[i], Err: err}}
+	}
+	return nil
+}
+
+func (m *runMerger) next() ([]byte, error) {
+	if len(m.heads) == 0 {
+		return nil, io.EOF
+	}
+	h := heap.Pop(&m.heads).(runHead)
+	if err := m.pull(h.run); err != nil {
+		return nil, err
+	}
+	return h.line, nil
+}
+
+// writeTo writes every merged line to f.
+func (m *runMerger) writeTo(ctx context.Context, f *os.File) error {
+	w := bufio.NewWriterSize(f, bufSize)
+	for n := 0; ; n++ {
+		if n%4096 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line, err := m.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(line); err != nil {
+			return &OutputError{Part: f.Name(), Err: err}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return &OutputError{Part: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// runHead is the next line of one run in a merge.
+type runHead struct {
+	line []byte
+	run  int
+}
+
+// runHeap orders lines by their bytes, then by run.
+type runHeap []runHead
+
+func (h runHeap) Len() int { return len(h) }
+func (h runHeap) Less(i, j int) bool {
+	if c := bytes.Compare(h[i].line, h[j].line); c != 0 {
+		return c < 0
+	}
+	return h[i].run < h[j].run
+}
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)   { *h = append(*h, x.(runHead)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,50 @@
+package splitter
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Fault forces an error while producing a given part. It exists so callers
+// can exercise their error handling deterministically.
+type Fault struct {
+	Op   string // "create" or "write"
+	Part int    // 1-based part index
+}
+
+// errInjected marks failures caused by a Fault.
+var errInjected = errors.New("injected failure")
+
+func (f *Fault) createErr(part int) error {
+	if f == nil || f.Op != "create" || f.Part != part {
+		return nil
+	}
+	return fmt.Errorf("create part %d: %w", part, errInjected)
+}
+
+func (f *Fault) failsWrite(part int) bool {
+	return f != nil && f.Op == "write" && f.Part == part
+}
+
+// failingWriter rejects every write, standing in for a part whose storage
+// has gone bad.
+type failingWriter struct {
+	part int
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, fmt.Errorf("write part %d: %w", w.part, errInjected)
+}
+
+func (f *Fault) validate() error {
+	if f == nil {
+		return nil
+	}
+	if f.Op != "create" && f.Op != "write" {
+		return fmt.Errorf("fault op must be create or write, got %q", f.Op)
+	}
+	if f.Part < 1 {
+		return fmt.Errorf("fault part must be at least 1, got %d", f.Part)
+	}
+	return nil
+}
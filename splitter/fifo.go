@@ -0,0 +1,60 @@
+package splitter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FIFOSink writes each part to a named pipe instead of a file, so a
+// downstream reader can consume it without it touching disk. A missing
+// pipe is created; an existing one is reused, and any other file in the
+// way is an error. Opening a part blocks until a reader opens its pipe,
+// and writes block while the reader falls behind. Pipes are left in place
+// for the next run. FIFOSink is only supported on Unix.
+//
+// Nothing written to a pipe can be taken back, so FIFOSink implements
+// none of PartAborter, PartRemover and PartMarker.
+type FIFOSink struct {
+	// Mode is the permission of created pipes; zero means 0666 before umask.
+	Mode os.FileMode
+	// BufferSize is the size of each part's write buffer; zero means 128KB.
+	BufferSize int
+}
+
+// NewPart creates the part's pipe if needed and opens it for writing,
+// waiting for a reader.
+func (s FIFOSink) NewPart(meta PartInfo) (io.WriteCloser, error) {
+	st, err := os.Stat(meta.Name)
+	switch {
+	case os.IsNotExist(err):
+		mode := s.Mode
+		if mode == 0 {
+			mode = 0o666
+		}
+		if err := mkfifo(meta.Name, mode); err != nil {
+			return nil, err
+		}
+		// mkfifo applies the umask; set the mode explicitly when one was
+		// asked for.
+		if s.Mode != 0 {
+			if err := os.Chmod(meta.Name, s.Mode); err != nil {
+				return nil, err
+			}
+		}
+	case err != nil:
+		return nil, err
+	case st.Mode()&os.ModeNamedPipe == 0:
+		return nil, fmt.Errorf("%s exists and is not a named pipe", meta.Name)
+	}
+	f, err := os.OpenFile(meta.Name, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	size := s.BufferSize
+	if size <= 0 {
+		size = bufSize
+	}
+	return &bufferedFile{Writer: bufio.NewWriterSize(f, size), f: f}, nil
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package splitter
+
+import (
+	"errors"
+	"os"
+)
+
+func mkfifo(path string, mode os.FileMode) error {
+	return &os.PathError{Op: "mkfifo", Path: path, Err: errors.ErrUnsupported}
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package splitter
+
+import (
+	"os"
+	"syscall"
+)
+
+func mkfifo(path string, mode os.FileMode) error {
+	if err := syscall.Mkfifo(path, uint32(mode.Perm())); err != nil {
+		return &os.PathError{Op: "mkfifo", Path: path, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package splitter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const (
+	// hashBatch is how much of a part is handed to its hasher at a time.
+	hashBatch = 64 << 10
+	// hashBatches is how many batches a hasher may fall behind by before
+	// writes to its part wait for it.
+	hashBatches = 4
+)
+
+// hashPool hashes parts on their own goroutines WithParallelHash, so that
+// SHA-256 runs alongside the split loop rather than in it and a closed
+// part's hash can finish while the next part is written. At most n parts
+// are hashed at once; opening another waits for one of them to finish.
+type hashPool struct {
+	slots   chan struct{}
+	pending []pendingHash // closed parts whose hashes are awaited, in order
+}
+
+// pendingHash is a closed part whose hash may still be running.
+type pendingHash struct {
+	info  PartInfo
+	stats int // index of the part in Result.Parts
+	h     *asyncHash
+}
+
+func newHashPool(n int) *hashPool {
+	return &hashPool{slots: make(chan struct{}, n)}
+}
+
+// start returns a hasher for a new part, once fewer than n are running.
+func (p *hashPool) start() *asyncHash {
+	p.slots <- struct{}{}
+	h := &asyncHash{
+		jobs: make(chan hashJob, hashBatches),
+		free: make(chan []byte, hashBatches),
+		done: make(chan struct{}),
+	}
+	for range hashBatches {
+		h.free <- make([]byte, 0, hashBatch)
+	}
+	go h.run(p.slots)
+	return h
+}
+
+// asyncHash is an io.Writer that SHA-256 hashes what is written to it on
+// its own goroutine. Data is copied in batches, so it may be a buffer the
+// caller reuses.
+type asyncHash struct {
+	batch []byte
+	jobs  chan hashJob
+	free  chan []byte // empty batches
+	done  chan struct{}
+	sum   string // set once done is closed
+}
+
+// hashJob is a batch to hash, or a request for the hash so far.
+type hashJob struct {
+	data  []byte
+	reply chan string
+}
+
+func (h *asyncHash) run(slots chan struct{}) {
+	d := sha256.New()
+	for j := range h.jobs {
+		if j.reply != nil {
+			j.reply <- hex.EncodeToString(d.Sum(nil))
+			continue
+		}
+		d.Write(j.data)
+		h.free <- j.data[:0]
+	}
+	h.sum = hex.EncodeToString(d.Sum(nil))
+	<-slots
+	close(h.done)
+}
+
+func (h *asyncHash) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if h.batch == nil {
+			h.batch = <-h.free
+		}
+		c := min(len(p), cap(h.batch)-len(h.batch))
+		h.batch = append(h.batch, p[:c]...)
+		p = p[c:]
+		if len(h.batch) == cap(h.batch) {
+			h.flush()
+		}
+	}
+	return n, nil
+}
+
+// flush hands the current batch to the hasher.
+func (h *asyncHash) flush() {
+	if len(h.batch) > 0 {
+		h.jobs <- hashJob{data: h.batch}
+		h.batch = nil
+	}
+}
+
+// sumSoFar returns the hex hash of what has been written so far, waiting
+// for the hasher to catch up.
+func (h *asyncHash) sumSoFar() string {
+	h.flush()
+	reply := make(chan string)
+	h.jobs <- hashJob{reply: reply}
+	return <-reply
+}
+
+// close ends the part; the hasher finishes on its own.
+func (h *asyncHash) close() {
+	h.flush()
+	close(h.jobs)
+}
+
+// finished reports whether the hash of a closed part is done.
+func (h *asyncHash) finished() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait returns the hex hash of a closed part once it is done.
+func (h *asyncHash) wait() string {
+	<-h.done
+	return h.sum
+}
@@ -0,0 +1,238 @@
+package splitter
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"io"
+	"regexp"
+	"time"
+)
+
+// InterleavedReader merges the lines of several inputs into one stream.
+// Each input is read by its own goroutine; Close stops them.
+type InterleavedReader struct {
+	inputs []chan lineMsg
+	done   chan struct{}
+	buf    []byte
+	err    error
+	next   func() ([]byte, error)
+
+	// Round-robin state: the inputs not yet exhausted and whose turn it is.
+	live []int
+	turn int
+
+	// Time-merge state: the next line of every input, earliest first.
+	heads   lineHeap
+	started bool
+	last    []time.Time
+}
+
+// lineMsg is one line, or the error that ended an input.
+type lineMsg struct {
+	line []byte
+	err  error
+}
+
+// Interleave returns a reader of the lines of rs in round-robin order: one
+// line from each input in turn, dropping inputs as they run out. The order
+// depends only on the inputs. A final line without a newline gets one so
+// it doesn't run into the next input's line.
+func Interleave(rs ...io.Reader) *InterleavedReader {
+	m := newInterleaved(rs)
+	for i := range rs {
+		m.live = append(m.live, i)
+	}
+	m.next = m.nextRoundRobin
+	return m
+}
+
+// MergeByTime returns a reader of the lines of rs ordered by the first
+// ISO 8601 timestamp on each line (e.g., 2024-05-01T12:00:00Z or
+// 2024-05-01 12:00:00.123), like merging already sorted logs. Lines
+// without a timestamp, such as stack traces, keep the time of the line
+// before them in the same input. Ties go to the earlier input.
+func MergeByTime(rs ...io.Reader) *InterleavedReader {
+	m := newInterleaved(rs)
+	m.last = make([]time.Time, len(rs))
+	m.next = m.nextByTime
+	return m
+}
+
+func newInterleaved(rs []io.Reader) *InterleavedReader {
+	m := &InterleavedReader{done: make(chan struct{})}
+	for _, r := range rs {
+		ch := make(chan lineMsg, 64)
+		m.inputs = append(m.inputs, ch)
+		go feedLines(r, ch, m.done)
+	}
+	return m
+}
+
+// feedLines sends the lines of r to ch until r is exhausted or done is
+// closed.
+func feedLines(r io.Reader, ch chan<- lineMsg, done <-chan struct{}) {
+	defer close(ch)
+	br := bufio.NewReaderSize(r, bufSize)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if line[len(line)-1] != '\n' {
+				line = append(line, '\n')
+			}
+			select {
+			case ch <- lineMsg{line: line}:
+			case <-done:
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				select {
+				case ch <- lineMsg{err: err}:
+				case <-done:
+				}
+			}
+			return
+		}
+	}
+}
+
+func (m *InterleavedReader) Read(p []byte) (int, error) {
+	for len(m.buf) == 0 {
+		if m.err != nil {
+			return 0, m.err
+		}
+		m.buf, m.err = m.next()
+	}
+	n := copy(p, m.buf)
+	m.buf = m.buf[n:]
+	return n, nil
+}
+
+// Close stops reading the inputs. It does not close them.
+func (m *InterleavedReader) Close() error {
+	select {
+	case <-m.done:
+	default:
+		close(m.done)
+	}
+	return nil
+}
+
+func (m *InterleavedReader) nextRoundRobin() ([]byte, error) {
+	for len(m.live) > 0 {
+		i := m.turn % len(m.live)
+		msg, ok := <-m.inputs[m.live[i]]
+		if !ok {
+			m.live = append(m.live[:i], m.live[i+1:]...)
+			m.turn = i
+			continue
+		}
+		if msg.err != nil {
+			return nil, msg.err
+		}
+		m.turn = i + 1
+		return msg.line, nil
+	}
+	return nil, io.EOF
+}
+
+func (m *InterleavedReader) nextByTime() ([]byte, error) {
+	if !m.started {
+		m.started = true
+		for i := range m.inputs {
+			if err := m.pull(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(m.heads) == 0 {
+		return nil, io.EOF
+	}
+	h := heap.Pop(&m.heads).(lineHead)
+	if err := m.pull(h.input); err != nil {
+		return nil, err
+	}
+	return h.line, nil
+}
+
+// pull queues the next line of input i, if it has one.
+func (m *InterleavedReader) pull(i int) error {
+	msg, ok := <-m.inputs[i]
+	if !ok {
+		return nil
+	}
+	if msg.err != nil {
+		return msg.err
+	}
+	if t, ok := lineTime(msg.line); ok {
+		m.last[i] = t
+	}
+	heap.Push(&m.heads, lineHead{line: msg.line, t: m.last[i], input: i})
+	return nil
+}
+
+// timestampRe finds an ISO 8601 date and time, with optional fraction and
+// zone.
+var timestampRe = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`)
+
+// lineTime returns the first timestamp on line. Times without a zone are
+// taken as UTC.
+func lineTime(line []byte) (time.Time, bool) {
+	loc := timestampRe.Find(line)
+	if loc == nil {
+		return time.Time{}, false
+	}
+	return parseTimestamp(loc)
+}
+
+// timestampLayouts are the ISO 8601 forms parseTimestamp accepts, from a
+// full timestamp down to a date. Fractional seconds are accepted by the
+// layouts with seconds.
+var timestampLayouts = []string{
+	time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05",
+	"2006-01-02T15:04", "2006-01-02T15", "2006-01-02",
+}
+
+// parseTimestamp parses an ISO 8601 timestamp that may use a space for
+// the T and a comma before its fraction. Times without a zone are taken as
+// UTC.
+func parseTimestamp(b []byte) (time.Time, bool) {
+	s := string(bytes.Replace(b, []byte(","), []byte("."), 1))
+	if len(s) > 10 && s[10] == ' ' {
+		s = s[:10] + "T" + s[11:]
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// lineHead is the next line of one input in a time merge.
+type lineHead struct {
+	line  []byte
+	t     time.Time
+	input int
+}
+
+// lineHeap orders lines by time, then by input.
+type lineHeap []lineHead
+
+func (h lineHeap) Len() int { return len(h) }
+func (h lineHeap) Less(i, j int) bool {
+	if !h[i].t.Equal(h[j].t) {
+		return h[i].t.Before(h[j].t)
+	}
+	return h[i].input < h[j].input
+}
+func (h lineHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *lineHeap) Push(x any)   { *h = append(*h, x.(lineHead)) }
+func (h *lineHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
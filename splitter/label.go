@@ -0,0 +1,95 @@
+package splitter
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// maxLabelLen caps the length of a part name taken from a pattern match.
+const maxLabelLen = 64
+
+// namedGroup returns the index of the first named capture group of re, or
+// 0 if it has none.
+func namedGroup(re *regexp.Regexp) int {
+	if re == nil {
+		return 0
+	}
+	for i, name := range re.SubexpNames() {
+		if i > 0 && name != "" {
+			return i
+		}
+	}
+	return 0
+}
+
+// matchLabel matches line against the pattern and returns the value of
+// its naming group, which is empty if the group took no part.
+func (rn *run) matchLabel(line []byte) (string, bool) {
+	m := rn.cfg.pattern.FindSubmatch(trimEOL(line))
+	if m == nil {
+		return "", false
+	}
+	return string(m[rn.nameGroup]), true
+}
+
+// partLabel returns the filename form of a captured value: its slug
+// WithSlug, sanitized otherwise.
+func (c *config) partLabel(capture string) string {
+	if c.slug {
+		return slugLabel(capture)
+	}
+	return sanitizeLabel([]byte(capture))
+}
+
+// sanitizeLabel makes a captured value safe in a filename: every byte
+// other than an ASCII letter or digit becomes "_", and it is cut to
+// maxLabelLen bytes.
+func sanitizeLabel(b []byte) string {
+	out := make([]byte, 0, min(len(b), maxLabelLen))
+	for _, c := range b[:min(len(b), maxLabelLen)] {
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			out = append(out, c)
+		} else {
+			out = append(out, '_')
+		}
+	}
+	return string(out)
+}
+
+// slugLabel lowercases the ASCII letters of s and replaces every run of
+// other bytes with a single "-", trimming it from both ends. The slug is
+// cut to maxLabelLen bytes.
+func slugLabel(s string) string {
+	out := make([]byte, 0, min(len(s), maxLabelLen))
+	dash := false
+	for i := 0; i < len(s) && len(out) < maxLabelLen; i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z':
+			c += 'a' - 'A'
+		case ('a' <= c && c <= 'z') || ('0' <= c && c <= '9'):
+		default:
+			dash = len(out) > 0
+			continue
+		}
+		if dash {
+			if len(out)+1 == maxLabelLen {
+				break
+			}
+			out = append(out, '-')
+			dash = false
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+// labelSuffix returns the filename suffix of the next part named label,
+// numbering its later parts from 2.
+func (rn *run) labelSuffix(label string) string {
+	rn.labelParts[label]++
+	if n := rn.labelParts[label]; n > 1 {
+		return fmt.Sprintf("_%s_%d", label, n)
+	}
+	return "_" + label
+}
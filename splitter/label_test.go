@@ -0,0 +1,83 @@
+package splitter
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSlugLabel(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Chapter 1", "chapter-1"},
+		{"Chapter 1: The Start", "chapter-1-the-start"},
+		{"  --Hello,  World!--  ", "hello-world"},
+		{"a/b\\c", "a-b-c"},
+		{"Ünïcödé Name", "n-c-d-name"},
+		{"already-a-slug", "already-a-slug"},
+		{"UPPER_case", "upper-case"},
+		{"!!!", ""},
+		{"", ""},
+		{strings.Repeat("ab ", 40), strings.Repeat("ab-", 21) + "a"},
+		{strings.Repeat("x", 63) + " y", strings.Repeat("x", 63)},
+	}
+	for _, tt := range tests {
+		got := slugLabel(tt.in)
+		if got != tt.want {
+			t.Errorf("slugLabel(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if len(got) > maxLabelLen {
+			t.Errorf("slugLabel(%q) is %d bytes, over %d", tt.in, len(got), maxLabelLen)
+		}
+	}
+}
+
+// TestSlugNames checks the part names WithSlug gives and that each part
+// keeps the value it was named after.
+func TestSlugNames(t *testing.T) {
+	input := "pre\n== Chapter 1: The Start ==\na\n== chapter 1 the start ==\nb\n== !!! ==\nc\n"
+	re := regexp.MustCompile(`^== (?P<title>.+) ==$`)
+	tests := []struct {
+		name     string
+		opts     []Option
+		names    []string
+		captures []string
+	}{{
+		name:     "slug",
+		opts:     []Option{WithPattern(re), WithSlug()},
+		names:    []string{"part001.txt", "part_chapter-1-the-start.txt", "part_chapter-1-the-start_2.txt", "part004.txt"},
+		captures: []string{"", "Chapter 1: The Start", "chapter 1 the start", "!!!"},
+	}, {
+		name:     "sanitized",
+		opts:     []Option{WithPattern(re)},
+		names:    []string{"part001.txt", "part_Chapter_1__The_Start.txt", "part_chapter_1_the_start.txt", "part____.txt"},
+		captures: []string{"", "Chapter 1: The Start", "chapter 1 the start", "!!!"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, _ := splitString(t, input, tt.opts...)
+			var names, captures []string
+			for _, p := range res.Parts {
+				names = append(names, p.Name)
+				captures = append(captures, p.Capture)
+			}
+			if !slices.Equal(names, tt.names) {
+				t.Errorf("names %q, want %q", names, tt.names)
+			}
+			if !slices.Equal(captures, tt.captures) {
+				t.Errorf("captures %q, want %q", captures, tt.captures)
+			}
+		})
+	}
+}
+
+func TestSlugNeedsNamedGroup(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithSlug(), WithMaxLines(3)},
+		{WithSlug(), WithPattern(regexp.MustCompile(`^(x)`))},
+	} {
+		if _, err := New(opts...); err == nil || !strings.Contains(err.Error(), "slugs require a pattern with a named group") {
+			t.Errorf("got %v, want the named group error", err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package splitter
+
+import "math"
+
+// lineStats accumulates line-length statistics with Welford's online
+// algorithm, so no individual lengths need to be kept.
+type lineStats struct {
+	n        int64
+	min, max int
+	mean, m2 float64
+}
+
+func (s *lineStats) add(length int) {
+	s.n++
+	if s.n == 1 || length < s.min {
+		s.min = length
+	}
+	if length > s.max {
+		s.max = length
+	}
+	delta := float64(length) - s.mean
+	s.mean += delta / float64(s.n)
+	s.m2 += delta * (float64(length) - s.mean)
+}
+
+func (s *lineStats) stddev() float64 {
+	if s.n == 0 {
+		return 0
+	}
+	return math.Sqrt(s.m2 / float64(s.n))
+}
+
+// LineStatsSummary is the line-length distribution across several parts.
+type LineStatsSummary struct {
+	Lines  int64
+	Min    int
+	Max    int
+	Mean   float64
+	Stddev float64
+}
+
+// SummarizeLineStats combines the per-part line-length statistics of parts
+// produced WithLineStats into a single distribution.
+func SummarizeLineStats(parts []PartStats) LineStatsSummary {
+	var total lineStats
+	for _, p := range parts {
+		if p.Lines == 0 {
+			continue
+		}
+		n := float64(p.Lines)
+		m2 := p.StddevLineLen * p.StddevLineLen * n
+		if total.n == 0 {
+			total = lineStats{n: p.Lines, min: p.MinLineLen, max: p.MaxLineLen, mean: p.MeanLineLen, m2: m2}
+			continue
+		}
+		// Chan et al.'s pairwise update for merging two partial results.
+		combined := float64(total.n) + n
+		delta := p.MeanLineLen - total.mean
+		total.m2 += m2 + delta*delta*float64(total.n)*n/combined
+		total.mean += delta * n / combined
+		total.n += p.Lines
+		total.min = min(total.min, p.MinLineLen)
+		total.max = max(total.max, p.MaxLineLen)
+	}
+	return LineStatsSummary{Lines: total.n, Min: total.min, Max: total.max, Mean: total.mean, Stddev: total.stddev()}
+}
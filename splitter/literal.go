@@ -0,0 +1,61 @@
+package splitter
+
+import (
+	"bytes"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// patternMatcher returns a function reporting whether a line matches re.
+// A pattern that is a plain string, optionally anchored with ^ or $, is
+// matched with bytes.Contains, HasPrefix or HasSuffix instead of the regex
+// engine; the result is the same either way.
+func patternMatcher(re *regexp.Regexp) func([]byte) bool {
+	lit, anchor, ok := literalPattern(re.String())
+	if !ok {
+		return re.Match
+	}
+	needle := []byte(lit)
+	switch anchor {
+	case syntax.OpBeginText:
+		return func(line []byte) bool { return bytes.HasPrefix(line, needle) }
+	case syntax.OpEndText:
+		return func(line []byte) bool { return bytes.HasSuffix(line, needle) }
+	}
+	return func(line []byte) bool { return bytes.Contains(line, needle) }
+}
+
+// literalPattern reports whether expr matches exactly one string, with at
+// most a leading ^ or a trailing $ (the anchor, or OpNoMatch if none).
+// Literals that fold case or contain U+FFFD, which the regex engine also
+// matches against invalid UTF-8, don't count.
+func literalPattern(expr string) (string, syntax.Op, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", 0, false
+	}
+	re = re.Simplify()
+	anchor := syntax.OpNoMatch
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	if len(subs) == 2 {
+		switch {
+		case subs[0].Op == syntax.OpBeginText:
+			anchor, subs = syntax.OpBeginText, subs[1:]
+		case subs[1].Op == syntax.OpEndText:
+			anchor, subs = syntax.OpEndText, subs[:1]
+		}
+	}
+	if len(subs) != 1 || subs[0].Op != syntax.OpLiteral || subs[0].Flags&syntax.FoldCase != 0 {
+		return "", 0, false
+	}
+	lit := string(subs[0].Rune)
+	if lit == "" || strings.ContainsRune(lit, utf8.RuneError) {
+		return "", 0, false
+	}
+	return lit, anchor, true
+}
@@ -0,0 +1,125 @@
+package splitter
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+)
+
+func TestLiteralPattern(t *testing.T) {
+	tests := []struct {
+		expr   string
+		lit    string
+		anchor syntax.Op
+		ok     bool
+	}{
+		{"ERROR", "ERROR", syntax.OpNoMatch, true},
+		{"^START", "START", syntax.OpBeginText, true},
+		{"END$", "END", syntax.OpEndText, true},
+		{`\[END\]`, "[END]", syntax.OpNoMatch, true},
+		{`^=== `, "=== ", syntax.OpBeginText, true},
+		{"héllo", "héllo", syntax.OpNoMatch, true},
+		{"^x$", "", 0, false},
+		{"a|b", "", 0, false},
+		{"a.c", "", 0, false},
+		{"ab*", "", 0, false},
+		{"(?i)error", "", 0, false},
+		{"(?P<n>x)", "", 0, false},
+		{"", "", 0, false},
+		{"^", "", 0, false},
+		{"�", "", 0, false},
+		{"(?m)^x", "", 0, false},
+		{"[", "", 0, false},
+	}
+	for _, tt := range tests {
+		lit, anchor, ok := literalPattern(tt.expr)
+		if ok != tt.ok || (ok && (lit != tt.lit || anchor != tt.anchor)) {
+			t.Errorf("literalPattern(%q) = %q, %v, %v; want %q, %v, %v", tt.expr, lit, anchor, ok, tt.lit, tt.anchor, tt.ok)
+		}
+	}
+}
+
+// patternPieces build the random patterns of the differential test:
+// literal text, text the regex engine treats specially, and anchors.
+var patternPieces = []string{"a", "b", "ab", "é", "\\.", ".", "^", "$", "*", "\\[", "x|y", "(?i)A", "\\d", " ", "\\xff"}
+
+// linePieces build the random lines, including invalid UTF-8.
+var linePieces = []string{"a", "b", "ab", "é", ".", "[", "x", "A", "1", " ", "\xff", "\xc3", "\r"}
+
+func randomFrom(r *rand.Rand, pieces []string, n int) string {
+	var b bytes.Buffer
+	for i := r.Intn(n + 1); i > 0; i-- {
+		b.WriteString(pieces[r.Intn(len(pieces))])
+	}
+	return b.String()
+}
+
+// TestPatternMatcherMatchesRegexp checks that patternMatcher agrees with
+// the regex engine, whether or not it takes the literal fast path.
+func TestPatternMatcherMatchesRegexp(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	literals := 0
+	for i := 0; i < 2000; i++ {
+		expr := randomFrom(r, patternPieces, 4)
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			continue
+		}
+		if _, _, ok := literalPattern(expr); ok {
+			literals++
+		}
+		match := patternMatcher(re)
+		for j := 0; j < 50; j++ {
+			line := []byte(randomFrom(r, linePieces, 8))
+			if got, want := match(line), re.Match(line); got != want {
+				t.Fatalf("pattern %q on %q: matcher says %v, regexp %v", expr, line, got, want)
+			}
+		}
+	}
+	if literals < 200 {
+		t.Errorf("only %d patterns took the literal path", literals)
+	}
+}
+
+func FuzzPatternMatcher(f *testing.F) {
+	f.Add("^START", []byte("START here"))
+	f.Add("END$", []byte("the END"))
+	f.Add("ab", []byte("\xffab\xc3"))
+	f.Add("é", []byte("\xc3\xa9"))
+	f.Fuzz(func(t *testing.T, expr string, line []byte) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return
+		}
+		if got, want := patternMatcher(re)(line), re.Match(line); got != want {
+			t.Errorf("pattern %q on %q: matcher says %v, regexp %v", expr, line, got, want)
+		}
+	})
+}
+
+// BenchmarkPatternMatcher compares the literal fast path with the regex
+// engine on lines that mostly don't match.
+func BenchmarkPatternMatcher(b *testing.B) {
+	var lines [][]byte
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, []byte(fmt.Sprintf("2024-01-02 12:00:%02d INFO request %d served in %dms", i%60, i, i%97)))
+	}
+	for _, expr := range []string{"ERROR", "^START", "served in 5ms$"} {
+		re := regexp.MustCompile(expr)
+		for _, m := range []struct {
+			name  string
+			match func([]byte) bool
+		}{{"regexp", re.Match}, {"literal", patternMatcher(re)}} {
+			b.Run(expr+"/"+m.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					for _, line := range lines {
+						m.match(line)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package splitter
+
+// Logger receives the splitter's log events. Extra args are alternating
+// key/value pairs, so a *slog.Logger satisfies this interface.
+type Logger interface {
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
@@ -0,0 +1,142 @@
+package splitter
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// MinMaxMemory is the least budget WithMaxMemory accepts; the Go
+	// runtime alone takes a few MB.
+	MinMaxMemory = 16 << 20
+	// fingerprintCost is what each remembered fuzzy dedupe fingerprint
+	// costs beyond its table entries: the fingerprint and its sequence
+	// number.
+	fingerprintCost = 24
+	// entryCost is what each table entry of a fingerprint costs: its
+	// place in a bucket and a share of the bucket map.
+	entryCost = 64
+	// tailLineCost is what each line held WithOverlap costs beyond its
+	// bytes.
+	tailLineCost = 48
+	// memoryPoll is how often actual memory use is sampled.
+	memoryPoll = time.Second
+)
+
+// memoryPlan is how a run's buffers, fuzzy dedupe and overlap fit into
+// cfg.maxMemory. Half the budget goes to buffers, a quarter each to the
+// read and write sides, and the other half to fuzzy dedupe fingerprints
+// and overlap lines, shared when both are used.
+type memoryPlan struct {
+	bufSize       int   // read buffer size
+	pipelineDepth int   // read-ahead buffers
+	fingerprints  int   // fuzzy dedupe fingerprints kept, evicting the least recently seen; 0 for no limit
+	tailBytes     int64 // bytes of overlap lines held; 0 for no limit
+}
+
+// planMemory fits the run's read buffers, fuzzy dedupe and overlap into
+// cfg.maxMemory, logging whatever had to be reduced. mapped says the
+// input is read through a memory mapping, which needs no read-ahead.
+func (rn *run) planMemory(mapped bool) memoryPlan {
+	cfg := rn.cfg
+	plan := memoryPlan{bufSize: cfg.bufSize, pipelineDepth: cfg.pipelineDepth}
+	if cfg.maxMemory <= 0 {
+		return plan
+	}
+	quarter := cfg.maxMemory / 4
+	if !mapped {
+		// The bufio.Reader, the buffer being read into and the read-ahead
+		// queue.
+		bufs := int64(plan.pipelineDepth + 2)
+		if int64(plan.bufSize)*bufs > quarter {
+			size := max(int(quarter/bufs), minBufSize)
+			if int64(size)*bufs > quarter {
+				plan.pipelineDepth = max(int(quarter/minBufSize)-2, 0)
+				rn.log.Warn(fmt.Sprintf("Read-ahead reduced from %d to %d buffers to stay within the memory limit", cfg.pipelineDepth, plan.pipelineDepth),
+					"from", cfg.pipelineDepth, "to", plan.pipelineDepth)
+			}
+			rn.log.Warn(fmt.Sprintf("Read buffer reduced from %s to %s to stay within the memory limit", SizeString(int64(plan.bufSize)), SizeString(int64(size))),
+				"from", plan.bufSize, "to", size)
+			plan.bufSize = size
+		}
+	}
+	share := cfg.maxMemory / 2
+	if cfg.similarity > 0 && cfg.overlap > 0 {
+		share /= 2
+	}
+	if cfg.similarity > 0 {
+		entries := int64(newFuzzyDedupe(cfg.similarity, 0).entries())
+		n := int(max(share/(fingerprintCost+entries*entryCost), 1))
+		if cfg.dedupeLimit == 0 || n < cfg.dedupeLimit {
+			plan.fingerprints = n
+		}
+	}
+	if cfg.overlap > 0 {
+		plan.tailBytes = share
+	}
+	return plan
+}
+
+// WriteBufferSize returns the write buffer each of parts open parts can
+// have so that together they take no more than a quarter of maxMemory,
+// as WithMaxMemory plans for: size, or less but at least 4KB. It is for
+// sizing the buffers of a PartSink, which the splitter doesn't control.
+func WriteBufferSize(maxMemory int64, size, parts int) int {
+	if maxMemory <= 0 || parts < 1 {
+		return size
+	}
+	return max(min(size, int(maxMemory/4/int64(parts))), minBufSize)
+}
+
+// memoryWatch samples the memory the process holds from the OS while a
+// split runs, and keeps the peak.
+type memoryWatch struct {
+	peak atomic.Uint64
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func watchMemory() *memoryWatch {
+	w := &memoryWatch{stop: make(chan struct{})}
+	w.sample()
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		t := time.NewTicker(memoryPoll)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				w.sample()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	return w
+}
+
+// sample records the memory in use now: what the runtime has obtained
+// from the OS less what it has given back.
+func (w *memoryWatch) sample() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	used := m.Sys - m.HeapReleased
+	for {
+		peak := w.peak.Load()
+		if used <= peak || w.peak.CompareAndSwap(peak, used) {
+			return
+		}
+	}
+}
+
+// close stops sampling, takes a last sample and returns the peak.
+func (w *memoryWatch) close() uint64 {
+	close(w.stop)
+	w.wg.Wait()
+	w.sample()
+	return w.peak.Load()
+}
@@ -0,0 +1,195 @@
+package splitter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"runtime/debug"
+)
+
+// mapWindow is how much of the input a mappedReader maps at a time, small
+// enough for a 32-bit address space.
+const mapWindow = 1 << 30
+
+// lineReader is what the read loops read the input through: a
+// bufio.Reader, or a mappedReader for WithMmap.
+type lineReader interface {
+	io.Reader
+	ReadSlice(delim byte) ([]byte, error)
+	Peek(n int) ([]byte, error)
+	Discard(n int) (int, error)
+	Buffered() int
+	Size() int
+}
+
+// mappedReader reads a regular file through a memory mapping, returning
+// slices of the mapping instead of copying into a buffer. It behaves as a
+// bufio.Reader of the same size reading the file would, so lines longer
+// than the buffer come back in the same pieces and splits are identical.
+// The file is mapped a window at a time.
+type mappedReader struct {
+	f      *os.File
+	end    int64     // file size when mapping started
+	pos    int64     // offset of the next unread byte
+	win    []byte    // mapped window
+	winOff int64     // offset of win in the file
+	size   int       // buffer size emulated
+	hash   hash.Hash // fed every byte read, when set
+}
+
+// newMappedReader returns a mappedReader reading f from its current
+// offset, or an error if f can't be mapped.
+func newMappedReader(f *os.File, size int) (*mappedReader, error) {
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	m := &mappedReader{f: f, end: st.Size(), pos: pos, winOff: pos, size: size}
+	// Map the first window now, so a platform or filesystem that can't map
+	// is found out before reading starts.
+	if err := m.ensure(min(int64(size), m.end-pos)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ensure maps a window holding the n bytes from m.pos.
+func (m *mappedReader) ensure(n int64) error {
+	if n <= 0 || (m.pos >= m.winOff && m.pos+n <= m.winOff+int64(len(m.win))) {
+		return nil
+	}
+	if m.win != nil {
+		if err := munmap(m.win); err != nil {
+			return err
+		}
+		m.win = nil
+	}
+	page := int64(os.Getpagesize())
+	off := m.pos - m.pos%page
+	length := min(max(mapWindow, m.pos+n-off), m.end-off)
+	win, err := mmap(m.f, off, int(length))
+	if err != nil {
+		return &os.PathError{Op: "mmap", Path: m.f.Name(), Err: err}
+	}
+	m.win, m.winOff = win, off
+	return nil
+}
+
+// next returns the n bytes from m.pos, mapping them if needed.
+func (m *mappedReader) next(n int64) ([]byte, error) {
+	if err := m.ensure(n); err != nil {
+		return nil, err
+	}
+	start := m.pos - m.winOff
+	return m.win[start : start+n], nil
+}
+
+// advance consumes b, the bytes from m.pos.
+func (m *mappedReader) advance(b []byte) {
+	if m.hash != nil {
+		m.hash.Write(b)
+	}
+	m.pos += int64(len(b))
+}
+
+func (m *mappedReader) ReadSlice(delim byte) ([]byte, error) {
+	n := min(int64(m.size), m.end-m.pos)
+	buf, err := m.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if i := bytes.IndexByte(buf, delim); i >= 0 {
+		buf = buf[:i+1]
+		m.advance(buf)
+		return buf, nil
+	}
+	m.advance(buf)
+	if n == int64(m.size) {
+		return buf, bufio.ErrBufferFull
+	}
+	return buf, io.EOF
+}
+
+func (m *mappedReader) Peek(n int) ([]byte, error) {
+	if n > m.size {
+		b, _ := m.next(min(int64(m.size), m.end-m.pos))
+		return b, bufio.ErrBufferFull
+	}
+	k := min(int64(n), m.end-m.pos)
+	buf, err := m.next(k)
+	if err != nil {
+		return nil, err
+	}
+	if k < int64(n) {
+		return buf, io.EOF
+	}
+	return buf, nil
+}
+
+func (m *mappedReader) Discard(n int) (int, error) {
+	k := min(int64(n), m.end-m.pos)
+	buf, err := m.next(k)
+	if err != nil {
+		return 0, err
+	}
+	m.advance(buf)
+	if k < int64(n) {
+		return int(k), io.EOF
+	}
+	return n, nil
+}
+
+func (m *mappedReader) Read(p []byte) (int, error) {
+	if m.pos >= m.end {
+		return 0, io.EOF
+	}
+	buf, err := m.next(min(int64(len(p)), m.end-m.pos, mapWindow))
+	if err != nil {
+		return 0, err
+	}
+	m.advance(buf)
+	return copy(p, buf), nil
+}
+
+func (m *mappedReader) Buffered() int { return int(min(int64(m.size), m.end-m.pos)) }
+
+func (m *mappedReader) Size() int { return m.size }
+
+// Close unmaps the file and leaves its offset after the bytes read, as
+// reading it would have.
+func (m *mappedReader) Close() error {
+	if m.win != nil {
+		munmap(m.win)
+		m.win = nil
+	}
+	_, err := m.f.Seek(m.pos, io.SeekStart)
+	return err
+}
+
+// recoverFault turns a fault reading the mapping, as when the file is
+// truncated while it is read, into an input error instead of a crash. It
+// must be deferred with faults set to panic, as split does.
+func (rn *run) recoverFault(res **Result, err *error) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	if _, ok := p.(interface{ Addr() uintptr }); !ok {
+		panic(p)
+	}
+	*res, *err = rn.fail(rn.inputErr(errors.New("input file shrank while it was being read")))
+}
+
+// panicOnFault makes faults on the current goroutine panic until the
+// returned func restores the old setting.
+func panicOnFault() func() {
+	old := debug.SetPanicOnFault(true)
+	return func() { debug.SetPanicOnFault(old) }
+}
@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package splitter
+
+import (
+	"errors"
+	"os"
+)
+
+func mmap(f *os.File, off int64, length int) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func munmap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,194 @@
+package splitter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// splitFile splits the file at path into a memSink with opts.
+func splitFile(t testing.TB, path string, opts ...Option) (*Result, *memSink, *captureLogger, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sink, log := &memSink{}, &captureLogger{}
+	sp, err := New(append([]Option{WithSink(sink), WithLogger(log)}, opts...)...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := sp.Split(context.Background(), f)
+	return res, sink, log, err
+}
+
+// TestMmapMatchesBuffered checks that WithMmap gives byte-identical parts
+// and the same stats as the buffered reader, in every kind of split.
+func TestMmapMatchesBuffered(t *testing.T) {
+	dir := t.TempDir()
+	r := rand.New(rand.NewSource(5))
+	inputs := []struct {
+		name string
+		data string
+	}{
+		{"short lines", randomLines(r, 2000, 40, false)},
+		{"no final newline", randomLines(r, 2000, 40, true)},
+		{"lines longer than the buffer", randomLines(r, 200, 3*minBufSize, false)},
+		{"long lines, no final newline", randomLines(r, 200, 3*minBufSize, true)},
+		{"one unterminated line", string(bytes.Repeat([]byte("x"), 5*minBufSize+7))},
+	}
+	modes := []struct {
+		name string
+		opts []Option
+	}{
+		{"lines", []Option{WithMaxLines(37)}},
+		{"size", []Option{WithMaxSize(5000)}},
+		{"size line loop", []Option{WithMaxSize(5000), WithMaxLines(1 << 30)}},
+		{"size align lines", []Option{WithMaxSize(5000), WithSizeAlignLines()}},
+		{"pattern", []Option{WithPattern(regexp.MustCompile(`^[aeiou]`))}},
+		{"named pattern", []Option{WithPattern(regexp.MustCompile(`^(?P<letter>[aeiou])`))}},
+		{"bytes", []Option{WithByteChunks(3000)}},
+		{"checksum", []Option{WithMaxLines(100), WithChecksum()}},
+	}
+	// Platforms without mmap fall back to the buffered reader.
+	_, err := mmap(nil, 0, 0)
+	canMap := !errors.Is(err, errors.ErrUnsupported)
+	for i, in := range inputs {
+		path := filepath.Join(dir, fmt.Sprintf("input%d.txt", i))
+		if err := os.WriteFile(path, []byte(in.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range modes {
+			t.Run(in.name+"/"+m.name, func(t *testing.T) {
+				opts := append([]Option{WithBufferSize(minBufSize)}, m.opts...)
+				bufRes, bufSink, _, err := splitFile(t, path, opts...)
+				if err != nil {
+					t.Fatalf("buffered split: %v", err)
+				}
+				mapRes, mapSink, log, err := splitFile(t, path, append(opts, WithMmap())...)
+				if err != nil {
+					t.Fatalf("mapped split: %v", err)
+				}
+				if ev := log.matching("Could not map"); len(ev) > 0 && canMap {
+					t.Fatalf("input was not mapped: %s", ev[0].msg)
+				}
+				if got, want := mapSink.contents(), bufSink.contents(); !slices.Equal(got, want) {
+					t.Errorf("mapped split gives %d parts, buffered %d, or their contents differ", len(got), len(want))
+				}
+				if got, want := summarize(mapRes, mapSink), summarize(bufRes, bufSink); !slices.Equal(got, want) {
+					t.Errorf("mapped part stats differ from buffered")
+				}
+				if mapRes.Lines != bufRes.Lines || mapRes.BytesRead != bufRes.BytesRead || mapRes.LongestLine != bufRes.LongestLine || mapRes.InputChecksum != bufRes.InputChecksum {
+					t.Errorf("mapped split read %d lines, %d bytes, longest %d, checksum %q; buffered %d, %d, %d, %q",
+						mapRes.Lines, mapRes.BytesRead, mapRes.LongestLine, mapRes.InputChecksum,
+						bufRes.Lines, bufRes.BytesRead, bufRes.LongestLine, bufRes.InputChecksum)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkMmap compares reading a 64 MB file through WithMmap with the
+// buffered reader, scanning every line for a pattern.
+func BenchmarkMmap(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "input.txt")
+	input := randomLines(rand.New(rand.NewSource(3)), 800_000, 160, false)
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	re := regexp.MustCompile(`^zzzz+\r?$`)
+	modes := []struct {
+		name string
+		opts []Option
+	}{
+		{"buffered", nil},
+		{"mmap", []Option{WithMmap()}},
+	}
+	for _, split := range []struct {
+		name string
+		opts []Option
+	}{
+		{"pattern", []Option{WithPattern(re)}},
+		{"size", []Option{WithMaxSize(1 << 20)}},
+	} {
+		for _, m := range modes {
+			b.Run(split.name+"/"+m.name, func(b *testing.B) {
+				sp, err := New(append(append([]Option{WithSink(discardSink{})}, split.opts...), m.opts...)...)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(input)))
+				for i := 0; i < b.N; i++ {
+					f, err := os.Open(path)
+					if err != nil {
+						b.Fatal(err)
+					}
+					_, err = sp.Split(context.Background(), f)
+					f.Close()
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// shrinkSink truncates the file at path when part 2 is opened, as a
+// writer rotating a log out from under the split would.
+type shrinkSink struct {
+	memSink
+	path string
+}
+
+func (s *shrinkSink) NewPart(info PartInfo) (io.WriteCloser, error) {
+	if len(s.parts) == 1 {
+		if err := os.Truncate(s.path, 0); err != nil {
+			return nil, err
+		}
+	}
+	return s.memSink.NewPart(info)
+}
+
+// TestMmapTruncatedInput checks that a mapped input truncated during the
+// split fails it with an input error rather than crashing on the fault.
+func TestMmapTruncatedInput(t *testing.T) {
+	if _, err := mmap(nil, 0, 0); errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("no mmap on this platform")
+	}
+	path := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(path, []byte(numberedLines(200_000)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sink := &shrinkSink{path: path}
+	sp, err := New(WithSink(sink), WithMaxLines(1000), WithMmap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := sp.Split(context.Background(), f)
+	var inErr *InputError
+	if !errors.As(err, &inErr) || !strings.Contains(err.Error(), "shrank while it was being read") {
+		t.Fatalf("Split error = %v, want an input error for the shrunk file", err)
+	}
+	if !res.Truncated {
+		t.Error("result not marked Truncated")
+	}
+	if len(sink.parts) < 2 || !sink.parts[0].closed {
+		t.Errorf("got %d parts, want the first complete and the split stopped after it", len(sink.parts))
+	}
+}
@@ -0,0 +1,909 @@
+package splitter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config holds everything a Splitter needs. Zero values are replaced by the
+// defaults in defaultConfig, which match the CLI's flag defaults.
+type config struct {
+	maxLines       int
+	alignLines     int
+	maxLineLen     int64
+	warnLineLen    int64
+	truncate       int
+	softWrap       int
+	maxSize        int64
+	sizeSchedule   []int64
+	holdSchedule   bool
+	sizeAlign      bool
+	pattern        *regexp.Regexp
+	matchesPerPart int
+	slug           bool
+	recordBegin    *regexp.Regexp
+	recordEnd      *regexp.Regexp
+	paragraphs     bool
+	prefix         string
+	ext            string
+	padWidth       int
+	firstPart      int
+	reverseTotal   int
+	timestamp      bool
+	tsFormat       string
+	tsLocation     *time.Location
+	appendTo       int
+	appendLines    int64
+	appendBytes    int64
+	bufSize        int
+	pipelineDepth  int
+	maxMemory      int64
+	mmap           bool
+	outputDir      string
+	dryRun         bool
+	removePartial  bool
+	markPartial    bool
+	checksum       bool
+	parallelHash   int
+	lineStats      bool
+	columns        *ColumnSelector
+	vertical       []*ColumnSelector
+	header         bool
+	allowEmpty     bool
+	pruneEmpty     bool
+	shuffleParts   int
+	syslog         bool
+	timeField      *regexp.Regexp
+	timeWindow     time.Duration
+	rotateEvery    time.Duration
+	maxIdle        time.Duration
+	overlap        int
+	sampleRate     float64
+	seed           uint64
+	seeded         bool
+	keepNumbering  bool
+	rewriteEOL     bool
+	similarity     float64
+	dedupeLimit    int
+	recordSep      []byte
+	onProgress     func(Progress)
+	onPartDone     func(PartInfo, PartStats)
+	trailer        func([]PartStats) []byte
+	progressEvery  time.Duration
+	inputSize      int64
+	logger         Logger
+	fault          *Fault
+	sink           PartSink
+	byteChunk      int64
+	decode         string
+	transform      []string
+	expectParts    int
+	rateLimit      int64
+	rateBurst      int64
+}
+
+func defaultConfig() config {
+	return config{
+		matchesPerPart: 1,
+		prefix:         "part",
+		ext:            "txt",
+		padWidth:       3,
+		firstPart:      1,
+		outputDir:      ".",
+		progressEvery:  time.Second,
+		logger:         nopLogger{},
+		sink:           FileSink{},
+		bufSize:        bufSize,
+	}
+}
+
+// Options is a plain struct form of the split criteria, for callers that
+// don't need the full set of functional options.
+// Zero fields keep their defaults.
+type Options struct {
+	MaxLines       int            // rotate after this many lines
+	MaxSize        int64          // rotate before a part would exceed this many bytes
+	Pattern        *regexp.Regexp // rotate on lines matching this pattern
+	MatchesPerPart int            // with Pattern, rotate on every nth match (default 1)
+	OutputDir      string         // directory for part files (default ".")
+	Backend        OutputBackend  // storage for part files (default: plain local files)
+}
+
+// WithOptions applies the fields of o, so the struct form can be mixed
+// with functional options.
+func WithOptions(o Options) Option {
+	return func(c *config) {
+		for _, opt := range o.options() {
+			opt(c)
+		}
+	}
+}
+
+func (o Options) options() []Option {
+	opts := []Option{WithMaxLines(o.MaxLines), WithMaxSize(o.MaxSize), WithPattern(o.Pattern)}
+	if o.MatchesPerPart > 0 {
+		opts = append(opts, WithMatchesPerPart(o.MatchesPerPart))
+	}
+	if o.OutputDir != "" {
+		opts = append(opts, WithOutputDir(o.OutputDir))
+	}
+	if o.Backend != nil {
+		opts = append(opts, WithOutputBackend(o.Backend))
+	}
+	return opts
+}
+
+// Option configures a Splitter.
+type Option func(*config)
+
+// WithMaxLines rotates to a new part after n lines.
+func WithMaxLines(n int) Option {
+	return func(c *config) { c.maxLines = n }
+}
+
+// WithAlignLines makes line-limit rotations fall on multiples of n input
+// lines, so part boundaries stay on that grid even after a rotation for
+// size or pattern. Parts still never exceed the line limit, so n must not
+// be larger than it.
+func WithAlignLines(n int) Option {
+	return func(c *config) { c.alignLines = n }
+}
+
+// WithMaxLineLength fails the split with an *InputError when a line,
+// including its line ending, is longer than n bytes. It bounds the memory
+// used to hold a long line whole, as column selection and dedupe do.
+func WithMaxLineLength(n int64) Option {
+	return func(c *config) { c.maxLineLen = n }
+}
+
+// WithLongLineWarning logs a warning with the line number, part and length
+// for every line, including its line ending, longer than n bytes, and
+// counts them in Result.LongLines. Unlike WithMaxLineLength the split goes on.
+func WithLongLineWarning(n int64) Option {
+	return func(c *config) { c.warnLineLen = n }
+}
+
+// WithTruncate cuts every line longer than n bytes, not counting its line
+// ending, down to n bytes ending in "...". The overflow is discarded, and
+// size limits and stats count the truncated line.
+func WithTruncate(n int) Option {
+	return func(c *config) { c.truncate = n }
+}
+
+// WithSoftWrap breaks every line longer than n characters, not counting
+// its line ending, into lines of at most n characters within the same
+// part. Characters are UTF-8 runes, which are never split. Nothing is
+// discarded; size limits count the inserted line breaks, while line limits
+// and stats count each input line once.
+func WithSoftWrap(n int) Option {
+	return func(c *config) { c.softWrap = n }
+}
+
+// WithMaxSize rotates to a new part before it would exceed n bytes.
+func WithMaxSize(n int64) Option {
+	return func(c *config) { c.maxSize = n }
+}
+
+// WithSizeAlignLines changes a split by size alone to copy the size limit
+// into each part as is and then continue to the end of the line the copy
+// stopped in, so parts hold at least the limit rather than at most. Lines
+// are never split; the input is only looked at to count them, which makes
+// this the fastest split by size. Result.LongestLine is not tracked.
+func WithSizeAlignLines() Option {
+	return func(c *config) { c.sizeAlign = true }
+}
+
+// WithSizeSchedule gives each part its own size limit, in place of
+// WithMaxSize: the first part gets sizes[0], the second sizes[1] and so on.
+// Past the end of the list the schedule starts over from sizes[0], or with
+// hold every later part gets the last size.
+func WithSizeSchedule(sizes []int64, hold bool) Option {
+	return func(c *config) { c.sizeSchedule, c.holdSchedule = sizes, hold }
+}
+
+// WithMaxBytes is another name for WithMaxSize.
+func WithMaxBytes(n int64) Option {
+	return WithMaxSize(n)
+}
+
+// WithPattern rotates to a new part whenever a line matches re. The line is
+// matched without its trailing "\n" or "\r\n", so `$` anchors at the end
+// of the text. If re has a named capture group, parts started by a match
+// are named after the first such group's value on the first matching line,
+// as <prefix>_<value>.<ext> with characters other than ASCII letters and
+// digits replaced by "_" (see WithSlug) and at most 64 of them kept. A
+// name that comes up again, or a part started by another limit, continues
+// in <prefix>_<value>_2.<ext> and so on; parts before the first match keep
+// numbered names.
+func WithPattern(re *regexp.Regexp) Option {
+	return func(c *config) { c.pattern = re }
+}
+
+// WithRecords treats the lines from one matching begin up to the next
+// line matching end as a single record that is never split across parts.
+// end is looked for from the line after begin, so the two may be the same
+// pattern. A record is held in memory until it is complete, then the line
+// and size limits are checked against the whole record: it starts a new
+// part unless it fits in the current one, and a record over the limits
+// fills a part of its own. Lines outside records are split as usual.
+func WithRecords(begin, end *regexp.Regexp) Option {
+	return func(c *config) { c.recordBegin, c.recordEnd = begin, end }
+}
+
+// WithParagraphs treats each paragraph, a run of non-blank lines and the
+// blank lines after it, as a record WithRecords, so that parts only end
+// between paragraphs. Lines holding only spaces and tabs are blank. A
+// paragraph larger than the part size limit fills a part of its own, with
+// a warning.
+func WithParagraphs() Option {
+	return func(c *config) { c.paragraphs = true }
+}
+
+// WithByteChunks splits into parts of exactly n bytes, ignoring line
+// boundaries. It suits binary data and cannot be combined with line-based
+// options.
+func WithByteChunks(n int64) Option {
+	return func(c *config) { c.byteChunk = n }
+}
+
+// WithDecoding decodes base64 or hex input before splitting it. Line
+// breaks in the encoded input are ignored.
+func WithDecoding(encoding string) Option {
+	return func(c *config) { c.decode = encoding }
+}
+
+// WithTransform pipes the input through the command argv and splits the
+// command's output instead. A single process handles the whole input, and
+// a non-zero exit fails the split.
+func WithTransform(argv ...string) Option {
+	return func(c *config) { c.transform = argv }
+}
+
+// WithSlug names parts after a pattern's named group with a slug of its
+// value instead: ASCII letters lowercased, every run of other bytes
+// replaced by a single "-", and no "-" at either end. A value with no
+// letters or digits leaves the part numbered.
+func WithSlug() Option {
+	return func(c *config) { c.slug = true }
+}
+
+// WithMatchesPerPart makes pattern splits rotate on every nth match instead
+// of every match.
+func WithMatchesPerPart(n int) Option {
+	return func(c *config) { c.matchesPerPart = n }
+}
+
+// WithExpectParts makes Split fail with a *PartCountError unless exactly n
+// parts are produced.
+func WithExpectParts(n int) Option {
+	return func(c *config) { c.expectParts = n }
+}
+
+// WithNaming sets the part filename prefix, extension and index padding.
+func WithNaming(prefix, ext string, pad int) Option {
+	return func(c *config) {
+		c.prefix = prefix
+		c.ext = ext
+		c.padWidth = pad
+	}
+}
+
+// WithFirstPart numbers parts from n instead of 1, to continue a series
+// of parts written by an earlier split.
+func WithFirstPart(n int) Option {
+	return func(c *config) { c.firstPart = n }
+}
+
+// WithReverseIndex numbers the parts downward, so that with total parts
+// the first one written is numbered total and the last one 1 (counting
+// from WithFirstPart). total must be the exact number of parts the split
+// creates, as counted by a dry run over the same input; a split that
+// needs more parts fails.
+func WithReverseIndex(total int) Option {
+	return func(c *config) { c.reverseTotal = total }
+}
+
+// DefaultTimestampFormat is the layout WithTimestamp uses for part
+// filenames.
+const DefaultTimestampFormat = "20060102_150405"
+
+// TimestampUnix is the WithTimestampFormat layout for Unix seconds.
+const TimestampUnix = "unix"
+
+// WithTimestamp appends the creation time to part filenames, formatted
+// with DefaultTimestampFormat.
+func WithTimestamp() Option {
+	return func(c *config) { c.timestamp = true }
+}
+
+// WithTimestampFormat appends the creation time to part filenames,
+// formatted with layout as by time.Time.Format, or as Unix seconds for
+// TimestampUnix. The layout must not contain a path separator.
+func WithTimestampFormat(layout string) Option {
+	return func(c *config) { c.timestamp, c.tsFormat = true, layout }
+}
+
+// WithBufferSize sets the size of the read buffer, 128KB by default and
+// at least 4KB. A line longer than the buffer is read in pieces, which is
+// slower, and in a split by size alone it starts a part of its own. The
+// sink's write buffers are set on the sink, as in FileSink.BufferSize.
+func WithBufferSize(n int) Option {
+	return func(c *config) { c.bufSize = n }
+}
+
+// WithPipelineDepth reads the input on a separate goroutine, up to n
+// buffers ahead of the split loop, so that reading and writing overlap
+// when the input and the parts are on different devices. Zero reads in
+// the split loop itself. The parts are the same either way.
+func WithPipelineDepth(n int) Option {
+	return func(c *config) { c.pipelineDepth = n }
+}
+
+// WithMaxMemory keeps the split's own memory use within about n bytes,
+// at least MinMaxMemory. The read buffer and read-ahead are scaled down to
+// fit a quarter of n. Fuzzy dedupe fingerprints and overlap lines get
+// half, shared when both are used: past it, the least recently seen
+// fingerprints are forgotten and the overlap holds fewer lines. Sink write
+// buffers are up to the caller; WriteBufferSize gives the size that fits
+// the remaining quarter. Result.PeakMemory reports what the process used.
+func WithMaxMemory(n int64) Option {
+	return func(c *config) { c.maxMemory = n }
+}
+
+// WithMmap reads an input that is a regular *os.File through a memory
+// mapping, a window of up to 1GB at a time, rather than copying it into
+// the read buffer. Other inputs, inputs that are decoded or transformed,
+// WithMaxIdle, and platforms where mapping fails fall back to reading
+// normally; WithPipelineDepth doesn't apply to a mapped input. The parts
+// are the same either way. A file truncated while it is mapped fails the
+// split with an InputError.
+func WithMmap() Option {
+	return func(c *config) { c.mmap = true }
+}
+
+// WithTimestampLocation appends the creation time to part filenames, as
+// the time in loc rather than the local time zone.
+func WithTimestampLocation(loc *time.Location) Option {
+	return func(c *config) { c.timestamp, c.tsLocation = true, loc }
+}
+
+// WithAppend continues part index, which an earlier run left holding
+// lines lines and size bytes, before starting new parts after it. Its
+// limits count what it already holds, and so do its stats, though line
+// stats and the line range cover only the lines added. Parts are numbered
+// from index, and the sink must implement PartAppender. The appended part
+// is never removed or marked partial, since it held data before.
+func WithAppend(index int, lines, size int64) Option {
+	return func(c *config) {
+		c.appendTo, c.appendLines, c.appendBytes = index, lines, size
+		c.firstPart = index
+	}
+}
+
+// WithOutputDir sets the directory parts are written to.
+func WithOutputDir(dir string) Option {
+	return func(c *config) { c.outputDir = dir }
+}
+
+// WithDryRun previews the split without creating any files.
+func WithDryRun() Option {
+	return func(c *config) { c.dryRun = true }
+}
+
+// WithRemovePartial deletes the incomplete part when a split is cancelled.
+func WithRemovePartial() Option {
+	return func(c *config) { c.removePartial = true }
+}
+
+// WithMarkPartial renames the incomplete part to its name plus ".partial"
+// when a split is cancelled, so that only complete parts keep their names.
+// A part that could not be written, which is otherwise removed, is marked
+// the same way. Sinks must implement PartMarker.
+func WithMarkPartial() Option {
+	return func(c *config) { c.markPartial = true }
+}
+
+// WithChecksum computes a SHA-256 checksum of every part.
+func WithChecksum() Option {
+	return func(c *config) { c.checksum = true }
+}
+
+// WithParallelHash computes checksums WithChecksum on background
+// goroutines, hashing up to n parts at once, so that a closed part's hash
+// finishes while the next part is written. A part's checksum, and its
+// report WithPartDone, follow once its hash is done; every hash is waited
+// for before the split returns. Opening a part waits while n are still
+// being hashed. 0, the default, hashes in the split loop. Vertical,
+// shuffled and syslog splits always do.
+func WithParallelHash(n int) Option {
+	return func(c *config) { c.parallelHash = n }
+}
+
+// WithLineStats records min, max, mean and standard deviation of line
+// lengths for every part.
+func WithLineStats() Option {
+	return func(c *config) { c.lineStats = true }
+}
+
+// WithColumns writes only the columns chosen by sel.
+func WithColumns(sel *ColumnSelector) Option {
+	return func(c *config) { c.columns = sel }
+}
+
+// WithVertical splits the input by columns instead of rows: every group
+// gets its own part, and each row is written to all of them projected to
+// that group's columns. All parts stay open for the whole split.
+func WithVertical(groups ...*ColumnSelector) Option {
+	return func(c *config) { c.vertical = groups }
+}
+
+// WithHeader treats the first input line as a header row. With WithVertical
+// the header must contain every selected column.
+func WithHeader() Option {
+	return func(c *config) { c.header = true }
+}
+
+// WithAllowEmpty writes a single empty part for empty input. By default
+// empty input produces no parts and a warning.
+func WithAllowEmpty() Option {
+	return func(c *config) { c.allowEmpty = true }
+}
+
+// WithShuffle writes each line to one of n parts chosen at random instead
+// of splitting sequentially. All n parts stay open for the whole split.
+func WithShuffle(n int) Option {
+	return func(c *config) { c.shuffleParts = n }
+}
+
+// WithSyslogSplit routes each line to a part per syslog severity, named
+// <prefix>_<level>.<ext> for each of SyslogLevels, by the <PRI> field that
+// starts RFC 3164 and RFC 5424 lines. Lines without one go to the
+// "unparsed" part. Parts are created on their first line and all stay
+// open for the whole split.
+func WithSyslogSplit() Option {
+	return func(c *config) { c.syslog = true }
+}
+
+// WithTimeWindow starts a new part whenever a line's timestamp falls in a
+// different window of the given length, such as an hour or a day. The
+// timestamp is the first capture group of field, an ISO 8601 date with as
+// much of the time as the window needs (e.g., 2024-01-15T03 for hourly
+// windows). Parts are named <prefix>_<window start>.<ext>, such as
+// part_2024-01-15T03.log; lines without a timestamp stay in the current
+// window, or go to <prefix>_untimed.<ext> before the first timestamp. A
+// window that comes round again, or is split further by other limits,
+// continues in part_2024-01-15T03_2.log and so on.
+func WithTimeWindow(field *regexp.Regexp, window time.Duration) Option {
+	return func(c *config) { c.timeField, c.timeWindow = field, window }
+}
+
+// WithRotateEvery starts a new part once the current one has been open for
+// d of wall-clock time, for streams read over a long period. The clock
+// restarts with every new part, whatever started it. The check is made as
+// each line arrives, so an idle stream leaves no empty parts behind.
+func WithRotateEvery(d time.Duration) Option {
+	return func(c *config) { c.rotateEvery = d }
+}
+
+// WithMaxIdle closes the current part once no input has arrived for d,
+// so a slow stream's lines reach a consumer without waiting for a limit to
+// fill the part. The next line starts a new part. A line half read when
+// the input goes idle keeps the part open until it is complete.
+func WithMaxIdle(d time.Duration) Option {
+	return func(c *config) { c.maxIdle = d }
+}
+
+// WithOverlap starts every part after the first with the last n lines
+// of the part before it, for sliding-window processing. The repeated
+// lines count toward the part's stats but not toward its line or size
+// limit, so every part still takes at least one new line.
+func WithOverlap(n int) Option {
+	return func(c *config) { c.overlap = n }
+}
+
+// WithSample writes each line with probability rate and skips the rest.
+func WithSample(rate float64) Option {
+	return func(c *config) { c.sampleRate = rate }
+}
+
+// WithSeed seeds the random choices of WithShuffle and WithSample so a
+// split can be reproduced. Without it a random seed is used, which is
+// reported in Result.Seed.
+func WithSeed(seed uint64) Option {
+	return func(c *config) {
+		c.seed = seed
+		c.seeded = true
+	}
+}
+
+// WithPruneEmpty deletes each part that is empty once closed, such as the
+// part before a pattern's first match when the first line matches. With
+// renumber, the next part takes the deleted part's index so there are no
+// gaps; otherwise the index is skipped and the part stays in the Result
+// with Pruned set. The sink must implement PartRemover.
+func WithPruneEmpty(renumber bool) Option {
+	return func(c *config) {
+		c.pruneEmpty = true
+		c.keepNumbering = !renumber
+	}
+}
+
+// WithRecordSeparator replaces each line ending (\n or \r\n) with sep
+// before it is written. An empty sep strips line endings. Size limits and
+// stats count the rewritten bytes.
+func WithRecordSeparator(sep string) Option {
+	return func(c *config) {
+		c.rewriteEOL = true
+		c.recordSep = []byte(sep)
+	}
+}
+
+// WithFuzzyDedupe skips lines whose 128-bit SimHash is within
+// floor((1-similarity)*128) bits of an earlier line's. At most limit
+// fingerprints are remembered; 0 means no limit.
+func WithFuzzyDedupe(similarity float64, limit int) Option {
+	return func(c *config) {
+		c.similarity = similarity
+		c.dedupeLimit = limit
+	}
+}
+
+// WithPartDone calls fn with each part once it is complete and closed,
+// from the split loop; with WithParallelHash, once its checksum is also
+// done, which may be after later parts are opened. Parts that are pruned,
+// or left incomplete by an error or cancellation, are not reported, and
+// neither are a dry run's.
+func WithPartDone(fn func(PartInfo, PartStats)) Option {
+	return func(c *config) { c.onPartDone = fn }
+}
+
+// WithTrailer appends what fn returns to the last part once the input is
+// exhausted, after a newline if the part doesn't end with one. fn gets the
+// stats of every part; the last one describes the part before the trailer,
+// with its checksum WithChecksum. The trailer counts toward the part's
+// bytes but not its lines. Nothing is written in a dry run.
+func WithTrailer(fn func(parts []PartStats) []byte) Option {
+	return func(c *config) { c.trailer = fn }
+}
+
+// WithProgress calls fn with a progress snapshot at most once per interval,
+// and once more when the split finishes.
+func WithProgress(fn func(Progress), every time.Duration) Option {
+	return func(c *config) {
+		c.onProgress = fn
+		c.progressEvery = every
+	}
+}
+
+// WithInputSize gives the input's size in bytes for progress reporting
+// when the reader can't tell, such as an HTTP body with a Content-Length.
+func WithInputSize(n int64) Option {
+	return func(c *config) { c.inputSize = n }
+}
+
+// WithSink writes parts to sink instead of files on disk. Dry runs never
+// call the sink.
+func WithSink(sink PartSink) Option {
+	return func(c *config) { c.sink = sink }
+}
+
+// WithRateLimit throttles output to bytesPerSec, allowing bursts of up to
+// burst bytes. A burst of 0 allows one second's worth of output.
+func WithRateLimit(bytesPerSec, burst int64) Option {
+	return func(c *config) {
+		c.rateLimit = bytesPerSec
+		c.rateBurst = burst
+	}
+}
+
+// WithOutputBackend writes parts to b through BackendSink.
+func WithOutputBackend(b OutputBackend) Option {
+	return func(c *config) { c.sink = BackendSink(b) }
+}
+
+// WithLogger sends log events to l instead of discarding them.
+func WithLogger(l Logger) Option {
+	return func(c *config) { c.logger = l }
+}
+
+// WithFault makes the split fail as described by f. It is a testing hook
+// and should not be used in production.
+func WithFault(f Fault) Option {
+	return func(c *config) { c.fault = &f }
+}
+
+// validate reports every problem with c at once.
+func (c *config) validate() error {
+	var errs []error
+	if c.maxLines < 0 {
+		errs = append(errs, fmt.Errorf("max lines must not be negative, got %d", c.maxLines))
+	}
+	if c.alignLines < 0 {
+		errs = append(errs, fmt.Errorf("line alignment must not be negative, got %d", c.alignLines))
+	}
+	if c.alignLines > 0 && (c.maxLines == 0 || c.alignLines > c.maxLines) {
+		errs = append(errs, errors.New("line alignment requires max lines of at least the alignment"))
+	}
+	if c.maxLineLen < 0 {
+		errs = append(errs, fmt.Errorf("max line length must not be negative, got %d", c.maxLineLen))
+	}
+	if c.warnLineLen < 0 {
+		errs = append(errs, fmt.Errorf("long line warning threshold must not be negative, got %d", c.warnLineLen))
+	}
+	if c.truncate < 0 {
+		errs = append(errs, fmt.Errorf("truncate length must not be negative, got %d", c.truncate))
+	}
+	if c.truncate > 0 && (c.byteChunk > 0 || c.vertical != nil) {
+		errs = append(errs, errors.New("truncation cannot be combined with byte chunks or a vertical split"))
+	}
+	if c.softWrap < 0 {
+		errs = append(errs, fmt.Errorf("soft wrap width must not be negative, got %d", c.softWrap))
+	}
+	if c.softWrap > 0 && (c.byteChunk > 0 || c.vertical != nil) {
+		errs = append(errs, errors.New("soft wrapping cannot be combined with byte chunks or a vertical split"))
+	}
+	if (c.maxLineLen > 0 || c.warnLineLen > 0) && c.byteChunk > 0 {
+		errs = append(errs, errors.New("line length limits cannot be combined with byte chunks"))
+	}
+	if c.maxSize < 0 {
+		errs = append(errs, fmt.Errorf("max size must not be negative, got %d", c.maxSize))
+	}
+	if len(c.sizeSchedule) > 0 {
+		if c.maxSize > 0 {
+			errs = append(errs, errors.New("a size schedule cannot be combined with max size"))
+		}
+		for i, n := range c.sizeSchedule {
+			if n <= 0 {
+				errs = append(errs, fmt.Errorf("size schedule entry %d must be positive, got %d", i+1, n))
+			}
+		}
+		// The schedule is a size limit for the checks below.
+		c.maxSize = c.sizeSchedule[0]
+	}
+	if c.byteChunk < 0 {
+		errs = append(errs, fmt.Errorf("byte chunk size must not be negative, got %d", c.byteChunk))
+	}
+	if c.byteChunk > 0 {
+		if c.maxLines > 0 || c.maxSize > 0 || c.pattern != nil {
+			errs = append(errs, errors.New("byte chunks cannot be combined with max lines, max size or a pattern"))
+		}
+		if c.columns != nil || c.lineStats || c.rewriteEOL {
+			errs = append(errs, errors.New("byte chunks cannot be combined with column selection, line stats or a record separator"))
+		}
+	}
+	if c.vertical != nil {
+		if len(c.vertical) == 0 {
+			errs = append(errs, errors.New("vertical split needs at least one column group"))
+		}
+		if c.maxLines > 0 || c.maxSize > 0 || c.pattern != nil || c.byteChunk > 0 {
+			errs = append(errs, errors.New("vertical split cannot be combined with max lines, max size, a pattern or byte chunks"))
+		}
+		if c.columns != nil || c.lineStats {
+			errs = append(errs, errors.New("vertical split cannot be combined with column selection or line stats"))
+		}
+	}
+	if c.header && c.vertical == nil {
+		errs = append(errs, errors.New("a header row is only supported with a vertical split"))
+	}
+	if c.shuffleParts < 0 {
+		errs = append(errs, fmt.Errorf("shuffle part count must not be negative, got %d", c.shuffleParts))
+	}
+	if c.shuffleParts > 0 {
+		if c.maxLines > 0 || c.maxSize > 0 || c.pattern != nil || c.byteChunk > 0 || c.vertical != nil {
+			errs = append(errs, errors.New("shuffling cannot be combined with max lines, max size, a pattern, byte chunks or a vertical split"))
+		}
+		if c.lineStats || c.pruneEmpty || c.warnLineLen > 0 {
+			errs = append(errs, errors.New("shuffling cannot be combined with line stats, empty part pruning or long line warnings"))
+		}
+	}
+	if c.syslog {
+		if c.maxLines > 0 || c.maxSize > 0 || c.pattern != nil || c.byteChunk > 0 || c.vertical != nil || c.shuffleParts > 0 {
+			errs = append(errs, errors.New("a syslog split cannot be combined with max lines, max size, a pattern, byte chunks, a vertical split or shuffling"))
+		}
+		if c.lineStats || c.pruneEmpty || c.warnLineLen > 0 || c.timestamp {
+			errs = append(errs, errors.New("a syslog split cannot be combined with line stats, empty part pruning, long line warnings or timestamps"))
+		}
+	}
+	if c.timeField != nil {
+		if c.timeField.NumSubexp() < 1 {
+			errs = append(errs, fmt.Errorf("time field %q needs a capture group around the timestamp", c.timeField))
+		}
+		if c.timeWindow <= 0 {
+			errs = append(errs, fmt.Errorf("time window must be positive, got %s", c.timeWindow))
+		}
+		if c.byteChunk > 0 || c.vertical != nil || c.shuffleParts > 0 || c.syslog {
+			errs = append(errs, errors.New("time windows cannot be combined with byte chunks, a vertical split, shuffling or a syslog split"))
+		}
+	}
+	if c.markPartial && c.removePartial {
+		errs = append(errs, errors.New("an incomplete part cannot be both marked partial and removed"))
+	}
+	if _, ok := c.sink.(PartMarker); c.markPartial && !ok {
+		errs = append(errs, errors.New("marking partial parts needs a sink that implements PartMarker"))
+	}
+	if c.rotateEvery < 0 {
+		errs = append(errs, fmt.Errorf("rotation interval must not be negative, got %s", c.rotateEvery))
+	}
+	if c.rotateEvery > 0 && (c.byteChunk > 0 || c.vertical != nil || c.shuffleParts > 0 || c.syslog) {
+		errs = append(errs, errors.New("a rotation interval cannot be combined with byte chunks, a vertical split, shuffling or a syslog split"))
+	}
+	if c.reverseTotal < 0 {
+		errs = append(errs, fmt.Errorf("reverse index total must not be negative, got %d", c.reverseTotal))
+	}
+	if c.reverseTotal > 0 && (c.vertical != nil || c.syslog || c.pruneEmpty) {
+		errs = append(errs, errors.New("reverse indexing cannot be combined with a vertical split, a syslog split or empty part pruning"))
+	}
+	if (c.recordBegin == nil) != (c.recordEnd == nil) {
+		errs = append(errs, errors.New("records need both a begin and an end pattern"))
+	}
+	if c.paragraphs && c.recordBegin != nil {
+		errs = append(errs, errors.New("paragraphs cannot be combined with records"))
+	}
+	if c.records() {
+		if c.byteChunk > 0 || c.vertical != nil || c.shuffleParts > 0 || c.syslog || c.timeField != nil || c.pattern != nil {
+			errs = append(errs, errors.New("records cannot be combined with byte chunks, a vertical split, shuffling, a syslog split, time windows or a pattern"))
+		}
+		if c.alignLines > 0 || c.sampleRate > 0 || c.similarity > 0 {
+			errs = append(errs, errors.New("records cannot be combined with line alignment, sampling or fuzzy dedupe, which work on single lines"))
+		}
+	}
+	if c.appendTo < 0 || c.appendLines < 0 || c.appendBytes < 0 {
+		errs = append(errs, errors.New("the part to append to, and its lines and bytes, must not be negative"))
+	}
+	if c.appendTo > 0 {
+		if _, ok := c.sink.(PartAppender); !ok {
+			errs = append(errs, errors.New("appending needs a sink that implements PartAppender"))
+		}
+		if c.byteChunk > 0 || c.vertical != nil || c.shuffleParts > 0 || c.syslog || c.timeField != nil || c.reverseTotal > 0 || c.timestamp || (c.pattern != nil && namedGroup(c.pattern) > 0) {
+			errs = append(errs, errors.New("appending cannot be combined with byte chunks, a vertical split, shuffling, a syslog split, time windows, reverse indexing, timestamps or parts named by a pattern"))
+		}
+	}
+	if c.pipelineDepth < 0 {
+		errs = append(errs, fmt.Errorf("pipeline depth must not be negative, got %d", c.pipelineDepth))
+	}
+	if c.maxMemory != 0 && c.maxMemory < MinMaxMemory {
+		errs = append(errs, fmt.Errorf("memory limit must be at least %s, got %d bytes", SizeString(MinMaxMemory), c.maxMemory))
+	}
+	if c.bufSize < minBufSize {
+		errs = append(errs, fmt.Errorf("buffer size must be at least %d bytes, got %d", minBufSize, c.bufSize))
+	}
+	if c.tsFormat != "" && strings.ContainsAny(c.tsFormat, `/\`) {
+		errs = append(errs, fmt.Errorf("timestamp format %q must not contain a path separator", c.tsFormat))
+	}
+	if c.maxIdle < 0 {
+		errs = append(errs, fmt.Errorf("max idle time must not be negative, got %s", c.maxIdle))
+	}
+	if c.maxIdle > 0 && (c.byteChunk > 0 || c.vertical != nil || c.shuffleParts > 0 || c.syslog || c.records() || c.trailer != nil) {
+		errs = append(errs, errors.New("max idle time cannot be combined with byte chunks, a vertical split, shuffling, a syslog split, records or a trailer"))
+	}
+	if c.trailer != nil && (c.byteChunk > 0 || c.vertical != nil || c.shuffleParts > 0 || c.syslog) {
+		errs = append(errs, errors.New("a trailer cannot be combined with byte chunks, a vertical split, shuffling or a syslog split"))
+	}
+	if c.overlap < 0 {
+		errs = append(errs, fmt.Errorf("overlap must not be negative, got %d", c.overlap))
+	}
+	if c.overlap > 0 && (c.byteChunk > 0 || c.vertical != nil || c.shuffleParts > 0 || c.syslog || c.timeField != nil) {
+		errs = append(errs, errors.New("overlap cannot be combined with byte chunks, a vertical split, shuffling, a syslog split or time windows"))
+	}
+	if c.sampleRate < 0 || c.sampleRate > 1 {
+		errs = append(errs, fmt.Errorf("sample rate must be between 0 and 1, got %g", c.sampleRate))
+	}
+	if c.sampleRate > 0 && (c.byteChunk > 0 || c.vertical != nil) {
+		errs = append(errs, errors.New("sampling cannot be combined with byte chunks or a vertical split"))
+	}
+	if c.pruneEmpty && (c.allowEmpty || c.vertical != nil) {
+		errs = append(errs, errors.New("empty part pruning cannot be combined with allowing empty input or a vertical split"))
+	}
+	if _, ok := c.sink.(PartRemover); c.pruneEmpty && !ok {
+		errs = append(errs, errors.New("empty part pruning needs a sink that can remove parts"))
+	}
+	if c.similarity < 0 || c.similarity > 1 {
+		errs = append(errs, fmt.Errorf("similarity must be between 0 and 1, got %g", c.similarity))
+	}
+	if c.dedupeLimit < 0 {
+		errs = append(errs, fmt.Errorf("fuzzy dedupe limit must not be negative, got %d", c.dedupeLimit))
+	}
+	if c.similarity > 0 && (c.byteChunk > 0 || c.vertical != nil) {
+		errs = append(errs, errors.New("fuzzy dedupe cannot be combined with byte chunks or a vertical split"))
+	}
+	if c.decode != "" && c.decode != "base64" && c.decode != "hex" {
+		errs = append(errs, fmt.Errorf("decoding must be base64 or hex, got %q", c.decode))
+	}
+	if c.transform != nil && (len(c.transform) == 0 || c.transform[0] == "") {
+		errs = append(errs, errors.New("transform command must not be empty"))
+	}
+	if c.matchesPerPart < 1 {
+		errs = append(errs, fmt.Errorf("matches per part must be at least 1, got %d", c.matchesPerPart))
+	}
+	if c.matchesPerPart > 1 && c.pattern == nil {
+		errs = append(errs, errors.New("matches per part requires a pattern"))
+	}
+	if c.slug && namedGroup(c.pattern) == 0 {
+		errs = append(errs, errors.New("slugs require a pattern with a named group"))
+	}
+	if c.sizeAlign && (!c.fastSize() || c.maxLineLen > 0) {
+		errs = append(errs, errors.New("aligning sizes to lines needs a split by size alone, without line options"))
+	}
+	if c.expectParts < 0 {
+		errs = append(errs, fmt.Errorf("expected parts must not be negative, got %d", c.expectParts))
+	}
+	if c.firstPart < 1 {
+		errs = append(errs, fmt.Errorf("first part must be at least 1, got %d", c.firstPart))
+	}
+	if c.padWidth < 0 {
+		errs = append(errs, fmt.Errorf("pad width must not be negative, got %d", c.padWidth))
+	}
+	if c.prefix == "" && c.ext == "" && c.padWidth == 0 {
+		errs = append(errs, errors.New("naming needs a prefix, extension or padded index"))
+	}
+	if c.outputDir == "" {
+		errs = append(errs, errors.New("output directory must not be empty"))
+	}
+	if c.onProgress != nil && c.progressEvery <= 0 {
+		errs = append(errs, fmt.Errorf("progress interval must be positive, got %s", c.progressEvery))
+	}
+	if c.dryRun && c.lineStats {
+		errs = append(errs, errors.New("line stats cannot be computed in dry run mode"))
+	}
+	if c.dryRun && c.checksum {
+		errs = append(errs, errors.New("checksums cannot be computed in dry run mode"))
+	}
+	if c.parallelHash < 0 {
+		errs = append(errs, fmt.Errorf("parallel hash must not be negative, got %d", c.parallelHash))
+	}
+	if err := c.fault.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.rateLimit < 0 {
+		errs = append(errs, fmt.Errorf("rate limit must not be negative, got %d", c.rateLimit))
+	}
+	if c.rateBurst < 0 || (c.rateBurst > 0 && c.rateLimit == 0) {
+		errs = append(errs, errors.New("rate limit burst must be positive and requires a rate limit"))
+	}
+	if c.sink == nil {
+		errs = append(errs, errors.New("sink must not be nil"))
+	}
+	if c.logger == nil {
+		c.logger = nopLogger{}
+	}
+	return errors.Join(errs...)
+}
+
+// records reports whether lines are held and written in groups, WithRecords
+// or WithParagraphs.
+func (c *config) records() bool {
+	return c.recordBegin != nil || c.paragraphs
+}
+
+// partTime formats t for a part filename WithTimestamp.
+func (c *config) partTime(t time.Time) string {
+	if c.tsLocation != nil {
+		t = t.In(c.tsLocation)
+	}
+	switch c.tsFormat {
+	case "":
+		return t.Format(DefaultTimestampFormat)
+	case TimestampUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	return t.Format(c.tsFormat)
+}
+
+// partMaxSize returns the size limit of the part opened after n others.
+func (c *config) partMaxSize(n int) int64 {
+	sched := c.sizeSchedule
+	switch {
+	case len(sched) == 0:
+		return c.maxSize
+	case n < len(sched):
+		return sched[n]
+	case c.holdSchedule:
+		return sched[len(sched)-1]
+	default:
+		return sched[n%len(sched)]
+	}
+}
@@ -0,0 +1,109 @@
+package splitter
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNewModeConflicts checks that New rejects splitting modes that cannot
+// be combined.
+func TestNewModeConflicts(t *testing.T) {
+	cols, err := NewColumnSelector("1", ",", ",")
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`^---`)
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"bytes and lines", []Option{WithByteChunks(10), WithMaxLines(10)}, "byte chunks cannot be combined with max lines, max size or a pattern"},
+		{"bytes and size", []Option{WithByteChunks(10), WithMaxSize(10)}, "byte chunks cannot be combined with max lines, max size or a pattern"},
+		{"bytes and pattern", []Option{WithByteChunks(10), WithPattern(re)}, "byte chunks cannot be combined with max lines, max size or a pattern"},
+		{"size and schedule", []Option{WithMaxSize(10), WithSizeSchedule([]int64{10, 20}, false)}, "a size schedule cannot be combined with max size"},
+		{"vertical and lines", []Option{WithVertical(cols), WithMaxLines(10)}, "vertical split cannot be combined with max lines"},
+		{"vertical and bytes", []Option{WithVertical(cols), WithByteChunks(10)}, "vertical split cannot be combined with max lines"},
+		{"shuffle and pattern", []Option{WithShuffle(2), WithPattern(re)}, "shuffling cannot be combined with max lines"},
+		{"shuffle and vertical", []Option{WithShuffle(2), WithVertical(cols)}, "shuffling cannot be combined with max lines"},
+		{"syslog and size", []Option{WithSyslogSplit(), WithMaxSize(10)}, "a syslog split cannot be combined with max lines"},
+		{"syslog and shuffle", []Option{WithSyslogSplit(), WithShuffle(2)}, "a syslog split cannot be combined with max lines"},
+		{"time window and bytes", []Option{WithTimeWindow(regexp.MustCompile(`^(\d+)`), time.Hour), WithByteChunks(10)}, "time windows cannot be combined with byte chunks"},
+		{"records and pattern", []Option{WithRecords(regexp.MustCompile(`^BEGIN`), regexp.MustCompile(`^END`)), WithPattern(re)}, "records cannot be combined with"},
+		{"paragraphs and records", []Option{WithParagraphs(), WithRecords(regexp.MustCompile(`^BEGIN`), regexp.MustCompile(`^END`))}, "paragraphs cannot be combined with records"},
+		{"matches per part without pattern", []Option{WithMaxLines(10), WithMatchesPerPart(2)}, "matches per part requires a pattern"},
+		{"mark and remove partial", []Option{WithMarkPartial(), WithRemovePartial()}, "an incomplete part cannot be both marked partial and removed"},
+		{"dry run and checksums", []Option{WithDryRun(), WithChecksum()}, "checksums cannot be computed in dry run mode"},
+		{"size align and lines", []Option{WithMaxSize(10), WithSizeAlignLines(), WithMaxLines(10)}, "aligning sizes to lines needs a split by size alone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.opts...)
+			if err == nil {
+				t.Fatalf("New accepted the options, want an error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("New error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestNewCompatibleModes checks that New accepts the combinations that
+// are meant to work together.
+func TestNewCompatibleModes(t *testing.T) {
+	re := regexp.MustCompile(`^---`)
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"defaults", nil},
+		{"lines and size", []Option{WithMaxLines(10), WithMaxSize(100)}},
+		{"pattern and lines", []Option{WithPattern(re), WithMaxLines(10)}},
+		{"pattern and matches per part", []Option{WithPattern(re), WithMatchesPerPart(3)}},
+		{"records and size", []Option{WithRecords(regexp.MustCompile(`^BEGIN`), regexp.MustCompile(`^END`)), WithMaxSize(100)}},
+		{"schedule", []Option{WithSizeSchedule([]int64{10, 20}, true)}},
+		{"dry run", []Option{WithDryRun(), WithMaxLines(10)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(append([]Option{WithSink(&memSink{})}, tt.opts...)...); err != nil {
+				t.Errorf("New: %v", err)
+			}
+		})
+	}
+}
+
+// TestNewReportsAllProblems checks that New lists every problem at once
+// rather than stopping at the first.
+func TestNewReportsAllProblems(t *testing.T) {
+	_, err := New(
+		WithMaxLines(-1),
+		WithByteChunks(10), WithPattern(regexp.MustCompile(`x`)),
+		WithShuffle(2),
+		WithNaming("", "", 0),
+		WithOutputDir(""),
+	)
+	if err == nil {
+		t.Fatal("New accepted the options")
+	}
+	want := []string{
+		"max lines must not be negative, got -1",
+		"byte chunks cannot be combined with max lines, max size or a pattern",
+		"shuffling cannot be combined with max lines, max size, a pattern, byte chunks or a vertical split",
+		"naming needs a prefix, extension or padded index",
+		"output directory must not be empty",
+	}
+	lines := strings.Split(err.Error(), "\n")
+	for _, w := range want {
+		if !slices.Contains(lines, w) {
+			t.Errorf("error does not list %q:\n%v", w, err)
+		}
+	}
+	if len(lines) != len(want) {
+		t.Errorf("error lists %d problems, want %d:\n%v", len(lines), len(want), err)
+	}
+}
@@ -0,0 +1,57 @@
+package splitter
+
+// overlapTail keeps copies of the last lines written, with their input
+// line numbers, for WithOverlap. The zero value keeps nothing.
+type overlapTail struct {
+	buf      []tailLine
+	next     int   // slot the next line goes in
+	n        int   // lines held
+	bytes    int64 // memory the lines held take, by tailLineCost and their buffers' capacity
+	maxBytes int64 // limit of bytes, or 0
+}
+
+type tailLine struct {
+	text []byte
+	num  int64
+}
+
+// newOverlapTail keeps the last n lines, fewer when they would take more
+// than maxBytes, unless that is 0.
+func newOverlapTail(n int, maxBytes int64) overlapTail {
+	return overlapTail{buf: make([]tailLine, n), maxBytes: maxBytes}
+}
+
+// add keeps line, input line num, dropping the oldest line when full. The
+// slots' buffers are reused, so line may be a read buffer. It reports
+// whether older lines had to be dropped to stay within maxBytes.
+func (t *overlapTail) add(line []byte, num int64) (shrunk bool) {
+	if len(t.buf) == 0 {
+		return false
+	}
+	slot := &t.buf[t.next]
+	if t.n == len(t.buf) {
+		t.bytes -= int64(cap(slot.text)) + tailLineCost
+	}
+	slot.text = append(slot.text[:0], line...)
+	slot.num = num
+	t.bytes += int64(cap(slot.text)) + tailLineCost
+	t.next = (t.next + 1) % len(t.buf)
+	t.n = min(t.n+1, len(t.buf))
+	for t.maxBytes > 0 && t.bytes > t.maxBytes && t.n > 1 {
+		oldest := &t.buf[(t.next-t.n+len(t.buf))%len(t.buf)]
+		t.bytes -= int64(cap(oldest.text)) + tailLineCost
+		oldest.text = nil
+		t.n--
+		shrunk = true
+	}
+	return shrunk
+}
+
+// lines returns the lines held, oldest first.
+func (t *overlapTail) lines() []tailLine {
+	out := make([]tailLine, 0, t.n)
+	for i := t.n; i > 0; i-- {
+		out = append(out, t.buf[(t.next-i+len(t.buf))%len(t.buf)])
+	}
+	return out
+}
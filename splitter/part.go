@@ -0,0 +1,85 @@
+package splitter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"time"
+)
+
+// Part is one part of a split produced by SplitReader. Reading it yields
+// the part's content; once it has been read to the end, Metadata reports
+// what it contained.
+type Part struct {
+	Number         int   // 1-based part index
+	EstimatedLines int64 // expected line count from the split criteria, 0 if unknown
+	EstimatedBytes int64 // expected maximum size from the split criteria, 0 if unknown
+	StartLine      int64 // input line number of the part's first line
+
+	r       *io.PipeReader
+	started time.Time
+	hash    hash.Hash
+	lines   int64
+	bytes   int64
+	last    byte
+	meta    *PartMetadata
+}
+
+// PartMetadata describes the content of a Part that has been read in full.
+type PartMetadata struct {
+	Lines    int64
+	Bytes    int64
+	Checksum string        // hex SHA-256 of the content
+	Duration time.Duration // from the part's creation until it was fully read
+}
+
+func newPart(info PartInfo, opts Options, r *io.PipeReader) *Part {
+	p := &Part{
+		Number:    info.Index,
+		StartLine: info.StartLine,
+		r:         r,
+		started:   time.Now(),
+		hash:      sha256.New(),
+	}
+	if opts.MaxLines > 0 {
+		p.EstimatedLines = int64(opts.MaxLines)
+	}
+	if opts.MaxSize > 0 {
+		p.EstimatedBytes = opts.MaxSize
+	}
+	return p
+}
+
+func (p *Part) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.hash.Write(b[:n])
+		p.lines += int64(bytes.Count(b[:n], []byte{'\n'}))
+		p.bytes += int64(n)
+		p.last = b[n-1]
+	}
+	if err == io.EOF && p.meta == nil {
+		lines := p.lines
+		if p.bytes > 0 && p.last != '\n' {
+			lines++
+		}
+		p.meta = &PartMetadata{
+			Lines:    lines,
+			Bytes:    p.bytes,
+			Checksum: hex.EncodeToString(p.hash.Sum(nil)),
+			Duration: time.Since(p.started),
+		}
+	}
+	return n, err
+}
+
+// Metadata returns the part's metadata. ok is false until the part has
+// been read to the end.
+func (p *Part) Metadata() (meta PartMetadata, ok bool) {
+	if p.meta == nil {
+		return PartMetadata{}, false
+	}
+	return *p.meta, true
+}
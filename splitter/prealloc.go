@@ -0,0 +1,16 @@
+package splitter
+
+import (
+	"io"
+	"os"
+)
+
+// trimToOffset truncates f to its current offset, dropping whatever
+// preallocated space past the written data is left.
+func trimToOffset(f *os.File) error {
+	off, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	return f.Truncate(off)
+}
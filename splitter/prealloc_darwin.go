@@ -0,0 +1,32 @@
+package splitter
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate reserves size bytes for f with F_PREALLOCATE, contiguously
+// if the filesystem can, and then moves its end of file to match, since
+// the reservation alone leaves the size unchanged. Filesystems that can't
+// preallocate are left to allocate as data is written.
+func preallocate(f *os.File, size int64) error {
+	store := unix.Fstore_t{
+		Flags:   unix.F_ALLOCATECONTIG | unix.F_ALLOCATEALL,
+		Posmode: unix.F_PEOFPOSMODE,
+		Length:  size,
+	}
+	err := unix.FcntlFstore(f.Fd(), unix.F_PREALLOCATE, &store)
+	if err != nil && !errors.Is(err, unix.ENOTSUP) {
+		store.Flags = unix.F_ALLOCATEALL
+		err = unix.FcntlFstore(f.Fd(), unix.F_PREALLOCATE, &store)
+	}
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EINTR) {
+		return nil
+	}
+	if err != nil {
+		return &os.PathError{Op: "fcntl F_PREALLOCATE", Path: f.Name(), Err: err}
+	}
+	return f.Truncate(size)
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !windows && !darwin
+
+package splitter
+
+import "os"
+
+// preallocate does nothing on systems without a preallocation call this
+// package uses, so files are allocated as data is written.
+func preallocate(f *os.File, size int64) error {
+	return nil
+}
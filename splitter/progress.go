@@ -0,0 +1,47 @@
+package splitter
+
+import "time"
+
+// Progress is a snapshot of a running split.
+type Progress struct {
+	BytesRead  int64 // input bytes consumed so far
+	TotalBytes int64 // input size, or -1 when unknown
+	Lines      int64 // input lines processed so far
+	Part       int   // index of the part currently being written
+	PartBytes  int64 // bytes written to the current part
+}
+
+// progressReporter throttles progress callbacks to at most one per interval.
+// It is only ever driven from the split loop, so the callback is never
+// invoked concurrently with itself.
+type progressReporter struct {
+	fn       func(Progress)
+	interval time.Duration
+	last     time.Time
+}
+
+func newProgressReporter(fn func(Progress), interval time.Duration) *progressReporter {
+	if fn == nil {
+		return nil
+	}
+	return &progressReporter{fn: fn, interval: interval, last: time.Now()}
+}
+
+// maybeReport fires the callback when the interval has elapsed since the
+// last report. snapshot is only called when a report is due.
+func (p *progressReporter) maybeReport(snapshot func() Progress) {
+	if p == nil || time.Since(p.last) < p.interval {
+		return
+	}
+	p.last = time.Now()
+	p.fn(snapshot())
+}
+
+// report fires the callback unconditionally, e.g. once the split is done.
+func (p *progressReporter) report(snapshot func() Progress) {
+	if p == nil {
+		return
+	}
+	p.last = time.Now()
+	p.fn(snapshot())
+}
@@ -0,0 +1,73 @@
+package splitter
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// rateLimiter is a token bucket holding up to burst bytes and refilled at
+// rate bytes per second.
+type rateLimiter struct {
+	rate   float64
+	burst  int64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate, burst int64) *rateLimiter {
+	if burst <= 0 {
+		burst = rate
+	}
+	return &rateLimiter{rate: float64(rate), burst: burst, tokens: float64(burst), last: time.Now()}
+}
+
+// wait blocks until n bytes (at most burst) may be written or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, n int64) error {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return nil
+	}
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedWriter throttles writes to w through a shared limiter.
+type rateLimitedWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *rateLimiter
+}
+
+func (rw *rateLimitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if int64(len(chunk)) > rw.limiter.burst {
+			chunk = chunk[:rw.limiter.burst]
+		}
+		if err := rw.limiter.wait(rw.ctx, int64(len(chunk))); err != nil {
+			return written, err
+		}
+		n, err := rw.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
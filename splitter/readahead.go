@@ -0,0 +1,81 @@
+package splitter
+
+import (
+	"context"
+	"io"
+)
+
+// readAhead reads its input on a goroutine of its own, up to depth
+// buffers ahead of the split loop, so reading the input overlaps with
+// writing the parts. The split loop itself is unchanged, so the parts are
+// the same as without it.
+type readAhead struct {
+	ctx    context.Context
+	chunks chan readResult // filled buffers, in input order
+	free   chan []byte     // buffers handed back for reuse
+	done   chan struct{}
+	cur    []byte // the buffer being returned from
+	rest   []byte // unread part of cur
+	err    error
+}
+
+func newReadAhead(ctx context.Context, r io.Reader, depth, size int) *readAhead {
+	ra := &readAhead{
+		ctx:    ctx,
+		chunks: make(chan readResult, depth),
+		free:   make(chan []byte, depth+1),
+		done:   make(chan struct{}),
+	}
+	for range depth + 1 {
+		ra.free <- make([]byte, size)
+	}
+	go ra.fill(r)
+	return ra
+}
+
+// fill reads r into free buffers until it fails or the reader is closed.
+func (ra *readAhead) fill(r io.Reader) {
+	for {
+		var buf []byte
+		select {
+		case buf = <-ra.free:
+		case <-ra.done:
+			return
+		}
+		n, err := r.Read(buf)
+		select {
+		case ra.chunks <- readResult{buf[:n], err}:
+		case <-ra.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (ra *readAhead) Read(p []byte) (int, error) {
+	for len(ra.rest) == 0 {
+		if ra.err != nil {
+			return 0, ra.err
+		}
+		if ra.cur != nil {
+			ra.free <- ra.cur[:cap(ra.cur)]
+			ra.cur = nil
+		}
+		select {
+		case res := <-ra.chunks:
+			ra.cur, ra.rest, ra.err = res.data, res.data, res.err
+		case <-ra.ctx.Done():
+			return 0, ra.ctx.Err()
+		}
+	}
+	n := copy(p, ra.rest)
+	ra.rest = ra.rest[n:]
+	return n, nil
+}
+
+// Close stops the reading goroutine once its current read returns.
+func (ra *readAhead) Close() {
+	close(ra.done)
+}
@@ -0,0 +1,78 @@
+package splitter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// TestPipelineDepthKeepsBoundaries checks that reading ahead gives the
+// same parts as reading in the split loop, for each read loop and for
+// readers that return short reads.
+func TestPipelineDepthKeepsBoundaries(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	modes := []struct {
+		name string
+		opts []Option
+	}{
+		{"lines", []Option{WithMaxLines(7)}},
+		{"size fast path", []Option{WithMaxSize(3000)}},
+		{"size line loop", []Option{WithMaxSize(3000), WithMaxLines(1 << 30)}},
+		{"size align lines", []Option{WithMaxSize(3000), WithSizeAlignLines()}},
+		{"pattern", []Option{WithPattern(regexp.MustCompile("^c"))}},
+		{"byte chunks", []Option{WithByteChunks(1000)}},
+		{"overlap", []Option{WithMaxLines(10), WithOverlap(2)}},
+	}
+	readers := []struct {
+		name string
+		wrap func(io.Reader) io.Reader
+	}{
+		{"whole", func(r io.Reader) io.Reader { return r }},
+		{"half", iotest.HalfReader},
+		{"one byte", iotest.OneByteReader},
+	}
+	inputs := []string{
+		"",
+		"no line end",
+		randomLines(r, 200, 40, false),
+		randomLines(r, 8, 3*minBufSize, true),
+	}
+	split := func(t *testing.T, input string, wrap func(io.Reader) io.Reader, opts []Option) []string {
+		t.Helper()
+		sink := &memSink{}
+		sp, err := New(append([]Option{WithSink(sink), WithBufferSize(minBufSize)}, opts...)...)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		res, err := sp.Split(context.Background(), wrap(strings.NewReader(input)))
+		if err != nil {
+			t.Fatalf("Split: %v", err)
+		}
+		out := sink.contents()
+		for _, p := range res.Parts {
+			out = append(out, fmt.Sprintf("%s: %d lines %d-%d", p.Name, p.Lines, p.StartLine, p.EndLine))
+		}
+		return out
+	}
+	for _, m := range modes {
+		for _, rd := range readers {
+			t.Run(m.name+"/"+rd.name, func(t *testing.T) {
+				for i, input := range inputs {
+					want := split(t, input, rd.wrap, append(slices.Clone(m.opts), WithPipelineDepth(0)))
+					for _, depth := range []int{1, 2, 8} {
+						got := split(t, input, rd.wrap, append(slices.Clone(m.opts), WithPipelineDepth(depth)))
+						if !slices.Equal(got, want) {
+							t.Errorf("input %d, depth %d: %d parts, want %d as with depth 0", i, depth, len(got), len(want))
+						}
+					}
+				}
+			})
+		}
+	}
+}
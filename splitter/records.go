@@ -0,0 +1,141 @@
+package splitter
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// recordBuf holds the lines of a record, WithRecords, until its end line
+// is read, or of a paragraph, WithParagraphs, until the next one starts.
+type recordBuf struct {
+	open      bool
+	startLine int64
+	text      bool // a paragraph has a non-blank line
+	ended     bool // a paragraph has a blank line after its text
+	data      []byte
+	ends      []int   // end offset in data of each line
+	readLens  []int64 // bytes read for each line, for long line warnings
+}
+
+// add appends a line of the record. The line may be a read buffer.
+func (b *recordBuf) add(line []byte, readLen int64) {
+	b.data = append(b.data, line...)
+	b.ends = append(b.ends, len(b.data))
+	b.readLens = append(b.readLens, readLen)
+}
+
+func (b *recordBuf) reset() {
+	b.open, b.text, b.ended = false, false, false
+	b.data, b.ends, b.readLens = b.data[:0], b.ends[:0], b.readLens[:0]
+}
+
+// bufferRecord starts a record with line, if it matches the begin pattern,
+// or adds line to the open record, and writes the record out once line
+// matches the end pattern. It reports whether line was taken. body is the
+// line as read, which the patterns are matched against.
+func (rn *run) bufferRecord(body, line []byte) (bool, error) {
+	rec := &rn.rec
+	if rn.cfg.paragraphs {
+		return true, rn.bufferParagraph(body, line)
+	}
+	if !rec.open {
+		if !rn.cfg.recordBegin.Match(body) {
+			return false, nil
+		}
+		rec.open, rec.startLine = true, rn.res.Lines+1
+		rn.res.Lines++
+		rec.add(line, rn.lastLineLen)
+		return true, nil
+	}
+	// Lines are counted as read so that errors name the right line; the
+	// count is rewound and replayed when the record is written.
+	rn.res.Lines++
+	rec.add(line, rn.lastLineLen)
+	if !rn.cfg.recordEnd.Match(body) {
+		return true, nil
+	}
+	return true, rn.writeRecord()
+}
+
+// bufferParagraph adds line to the paragraph being read, first writing
+// out the one before if line is the first non-blank line after it. Blank
+// lines stay with the paragraph they follow, and those before the first
+// paragraph with it.
+func (rn *run) bufferParagraph(body, line []byte) error {
+	rec := &rn.rec
+	blank := len(bytes.Trim(body, " \t")) == 0
+	if rec.ended && !blank {
+		if err := rn.writeRecord(); err != nil {
+			return err
+		}
+	}
+	if !rec.open {
+		rec.open, rec.startLine = true, rn.res.Lines+1
+	}
+	rn.res.Lines++
+	rec.add(line, rn.lastLineLen)
+	rec.ended = rec.ended || (blank && rec.text)
+	rec.text = rec.text || !blank
+	return nil
+}
+
+// writeRecord writes the buffered record to the current part, starting a
+// new one first unless the whole record fits.
+func (rn *run) writeRecord() error {
+	rec := &rn.rec
+	cfg := rn.cfg
+	lines, size := len(rec.ends), int64(len(rec.data))
+	var reason RotateReason
+	rotate := rn.lineCount > 0
+	switch {
+	case !rotate:
+	case cfg.maxLines > 0 && rn.lineCount+lines > cfg.maxLines:
+		reason = ReasonLines
+	case cfg.rotateEvery > 0 && time.Since(rn.partOpened) >= cfg.rotateEvery:
+		reason = ReasonInterval
+	case rn.maxSize > 0 && rn.written+size > rn.maxSize:
+		reason = ReasonSize
+	default:
+		rotate = false
+	}
+	if rotate {
+		if err := rn.newPart(reason); err != nil {
+			return err
+		}
+	}
+	if cfg.paragraphs && rn.maxSize > 0 && size > rn.maxSize {
+		rn.log.Warn(fmt.Sprintf("Paragraph at line %d is %s, over the part size limit; it gets a part of its own", rec.startLine, SizeString(size)),
+			"line", rec.startLine, "bytes", size, "file", rn.outInfo.Name)
+	}
+	if err := rn.write(rec.data); err != nil {
+		return err
+	}
+	rn.res.Lines = rec.startLine - 1
+	start := 0
+	for i, end := range rec.ends {
+		line := rec.data[start:end]
+		rn.record(line)
+		rn.lastLineLen = rec.readLens[i]
+		rn.warnLongLine()
+		rn.addTail(line)
+		start = end
+	}
+	rn.lineCount += lines
+	rn.written += size
+	rec.reset()
+	return nil
+}
+
+// endRecords writes a record the input ended in the middle of.
+func (rn *run) endRecords() error {
+	if !rn.rec.open {
+		return nil
+	}
+	if rn.cfg.paragraphs {
+		return rn.writeRecord()
+	}
+	rn.log.Warn(fmt.Sprintf("Input ended inside the record starting at line %d, which has no end line", rn.rec.startLine),
+		"line", rn.rec.startLine)
+	return rn.writeRecord()
+}
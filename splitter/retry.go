@@ -0,0 +1,85 @@
+package splitter
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Retry retries file operations that fail with an error that may clear up
+// on its own, such as EIO or ESTALE on a flaky network mount. Each retry
+// waits twice as long as the one before.
+type Retry struct {
+	// Attempts is the most retries of one operation; zero retries nothing.
+	Attempts int
+	// Wait is the wait before the first retry; zero means 100ms.
+	Wait time.Duration
+	// MaxWait caps the wait; zero means 10s.
+	MaxWait time.Duration
+	// OnRetry, if set, is called before each retry with the operation
+	// ("create", "open" or "write"), the file, the retry number and the error.
+	OnRetry func(op, path string, attempt int, err error)
+}
+
+// transientErrors are the errors Retry retries.
+var transientErrors = []error{syscall.EIO, syscall.ESTALE, syscall.EAGAIN, syscall.EINTR, syscall.ETIMEDOUT, syscall.EBUSY}
+
+// do runs fn until it succeeds, fails with an error that isn't transient,
+// or has been retried r.Attempts times.
+func (r Retry) do(op, path string, fn func() error) error {
+	wait, maxWait := r.Wait, r.MaxWait
+	if wait <= 0 {
+		wait = 100 * time.Millisecond
+	}
+	if maxWait <= 0 {
+		maxWait = 10 * time.Second
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > r.Attempts || !transient(err) {
+			return err
+		}
+		if r.OnRetry != nil {
+			r.OnRetry(op, path, attempt, err)
+		}
+		time.Sleep(wait)
+		wait = min(wait*2, maxWait)
+	}
+}
+
+func transient(err error) bool {
+	for _, t := range transientErrors {
+		if errors.Is(err, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryWriter retries failed writes to f, continuing after whatever part
+// of the data was written.
+type retryWriter struct {
+	f     *os.File
+	retry Retry
+}
+
+func (w *retryWriter) Write(p []byte) (int, error) {
+	written := 0
+	err := w.retry.do("write", w.f.Name(), func() error {
+		n, err := w.f.Write(p[written:])
+		written += n
+		return err
+	})
+	return written, err
+}
+
+// fileWriter returns what a part's buffer writes f through: f itself, or
+// a retryWriter when r retries anything.
+func fileWriter(f *os.File, r Retry) io.Writer {
+	if r.Attempts > 0 {
+		return &retryWriter{f: f, retry: r}
+	}
+	return f
+}
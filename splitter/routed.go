@@ -0,0 +1,115 @@
+package splitter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// splitRouted writes each line to one of n parts, the one route picks from
+// the line as read. Parts are opened in slot order up front when eager is
+// set, and otherwise on their first line. A slot's part is named by name,
+// or by the usual part numbering when name is nil. All opened parts stay
+// open for the whole split.
+func (rn *run) splitRouted(n int, eager bool, route func(line []byte) int, name func(slot int) string) (*Result, error) {
+	cfg := rn.cfg
+	cols := make([]column, 0, n)
+	pos := make([]int, n) // index into cols of each slot's part, or -1
+	for i := range pos {
+		pos[i] = -1
+	}
+	open := func(slot int) error {
+		var err error
+		if name == nil {
+			err = rn.newPart(ReasonStart)
+		} else {
+			err = rn.openPart(ReasonStart, name(slot), rn.part)
+		}
+		if err != nil {
+			return err
+		}
+		cols = append(cols, column{out: rn.out, info: rn.outInfo, writer: rn.writer, hasher: rn.hasher, stats: len(rn.res.Parts) - 1})
+		rn.out, rn.dryOpen = nil, false
+		pos[slot] = len(cols) - 1
+		return nil
+	}
+	if eager {
+		for slot := range n {
+			if err := open(slot); err != nil {
+				rn.closeColumns(cols)
+				return rn.fail(err)
+			}
+		}
+	}
+
+	var pending []byte
+	for iter := 0; ; iter++ {
+		if iter%ctxCheckInterval == 0 {
+			if rn.ctx.Err() != nil {
+				return rn.abortColumns(cols)
+			}
+			rn.progress.maybeReport(rn.snapshot)
+		}
+
+		line, err := rn.reader.ReadSlice('\n')
+		rn.res.BytesRead += int64(len(line))
+		if lerr := rn.checkLineLen(len(line), err); lerr != nil {
+			rn.closeColumns(cols)
+			return rn.fail(lerr)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			pending = append(pending, line...)
+			continue
+		}
+		if pending != nil {
+			line = append(pending, line...)
+			pending = nil
+		}
+		if err != nil && err != io.EOF {
+			if rn.ctx.Err() != nil {
+				return rn.abortColumns(cols)
+			}
+			rn.closeColumns(cols)
+			return rn.fail(rn.inputErr(fmt.Errorf("read line %d: %w", rn.res.Lines+1, err)))
+		}
+		if len(line) == 0 {
+			break
+		}
+
+		// The slot is picked before any line is skipped so that a shuffle
+		// seed assigns the same lines to the same parts whatever the
+		// sample rate.
+		slot := route(line)
+		if cfg.columns != nil {
+			var cerr error
+			if line, cerr = cfg.columns.apply(line); cerr != nil {
+				rn.closeColumns(cols)
+				return rn.fail(rn.inputErr(fmt.Errorf("select columns on line %d: %w", rn.res.Lines+1, cerr)))
+			}
+		}
+		line = rn.endLine(rn.softWrap(rn.truncate(line)))
+		if !rn.duplicate(line) && !rn.sampledOut() {
+			if pos[slot] < 0 {
+				if err := open(slot); err != nil {
+					rn.closeColumns(cols)
+					return rn.fail(err)
+				}
+			}
+			rn.res.Lines++
+			if err := rn.writeColumn(&cols[pos[slot]], line); err != nil {
+				rn.closeColumns(cols)
+				return rn.fail(err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if err := rn.closeColumns(cols); err != nil {
+		return rn.fail(err)
+	}
+	rn.columnsDone(cols)
+	return rn.finish()
+}
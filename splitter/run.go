@@ -0,0 +1,1178 @@
+package splitter
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+// run holds the state of a single Split call.
+type run struct {
+	ctx   context.Context
+	cfg   *config
+	log   Logger
+	res   *Result
+	start time.Time
+
+	reader     lineReader
+	mapped     *mappedReader // WithMmap, when the input could be mapped
+	transform  *transformReader
+	readAhead  *readAhead   // WithPipelineDepth
+	bufSize    int          // read buffer size, cfg.bufSize unless reduced WithMaxMemory
+	memory     *memoryWatch // WithMaxMemory
+	inputHash  hash.Hash
+	totalBytes int64 // input size, or -1 when unknown
+	limiter    *rateLimiter
+	dedupe     *fuzzyDedupe
+	rng        *rand.Rand
+	progress   *progressReporter
+
+	part    int // index of the next part to create
+	out     io.WriteCloser
+	outInfo PartInfo
+	writer  io.Writer
+	hasher  hash.Hash
+	async   *asyncHash // hashes the current part WithParallelHash, instead of hasher
+	hashes  *hashPool  // WithParallelHash
+	lengths lineStats
+
+	lineCount     int       // lines in the current part
+	lineLen       int64     // bytes of the line being read, for line length limits and LongestLine
+	lastLineLen   int64     // bytes of the last complete line
+	written       int64     // bytes in the current part
+	maxSize       int64     // size limit of the current part
+	partOpened    time.Time // when the current part was opened, for WithRotateEvery
+	dryOpen       bool      // a dry run is accounting a part that isn't written
+	opened        int       // parts opened so far, pruned ones included
+	failed        bool      // the split is stopping early; parts are incomplete
+	matchesInPart int
+	tail          overlapTail // last lines written, WithOverlap
+	tailShrunk    bool        // the overlap has held fewer lines to stay within WithMaxMemory
+	rec           recordBuf   // the record being read, WithRecords or WithParagraphs
+	endsLine      bool        // the last write ended with a newline
+	idleClosed    bool        // the part was closed on idle input, WithMaxIdle
+	streaming     bool        // the line being read is longer than the read buffer and is written as it is read
+
+	window     string            // time window of the current part, WithTimeWindow
+	match      func([]byte) bool // matches cfg.pattern against a line, WithPattern
+	nameGroup  int               // pattern group naming parts, or 0
+	label      string            // name of the current part from its pattern match
+	labelParts map[string]int    // parts created so far per time window or label
+}
+
+func newRun(ctx context.Context, cfg *config, r io.Reader) (*run, error) {
+	rn := &run{
+		ctx:        ctx,
+		cfg:        cfg,
+		log:        cfg.logger,
+		res:        &Result{},
+		start:      time.Now(),
+		totalBytes: -1,
+		part:       cfg.firstPart,
+		progress:   newProgressReporter(cfg.onProgress, cfg.progressEvery),
+	}
+	regular := false
+	if st, ok := r.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if stat, err := st.Stat(); err == nil && stat.Mode().IsRegular() {
+			rn.totalBytes, regular = stat.Size(), true
+		}
+	}
+	if cfg.inputSize > 0 {
+		rn.totalBytes = cfg.inputSize
+	}
+	if f, ok := r.(*os.File); ok && regular && cfg.mmap && cfg.decode == "" && len(cfg.transform) == 0 && cfg.maxIdle == 0 {
+		m, err := newMappedReader(f, cfg.bufSize)
+		if err != nil {
+			rn.log.Info(fmt.Sprintf("Could not map the input, reading it normally: %v", err))
+		} else {
+			rn.mapped = m
+		}
+	}
+	if !regular {
+		// Reads from a pipe or the network can block indefinitely; keep
+		// cancellation prompt.
+		r = newCtxReader(ctx, r)
+	}
+	if cfg.checksum {
+		rn.inputHash = sha256.New()
+		if rn.mapped != nil {
+			rn.mapped.hash = rn.inputHash
+		} else {
+			r = io.TeeReader(r, rn.inputHash)
+		}
+	}
+	if cfg.decode != "" {
+		// Progress counts decoded bytes, which don't relate to the input size.
+		rn.totalBytes = -1
+		r, _ = decodingReader(r, cfg.decode)
+	}
+	if len(cfg.transform) > 0 {
+		rn.totalBytes = -1
+		t, err := startTransform(ctx, r, cfg.transform)
+		if err != nil {
+			return rn, rn.inputErr(err)
+		}
+		rn.transform = t
+		r = t
+	}
+	if cfg.timeField != nil || cfg.pattern != nil {
+		rn.labelParts = map[string]int{}
+		rn.nameGroup = namedGroup(cfg.pattern)
+	}
+	if cfg.pattern != nil {
+		rn.match = patternMatcher(cfg.pattern)
+	}
+	plan := rn.planMemory(rn.mapped != nil)
+	rn.bufSize = plan.bufSize
+	if cfg.similarity > 0 {
+		rn.dedupe = newFuzzyDedupe(cfg.similarity, cfg.dedupeLimit)
+		if plan.fingerprints > 0 {
+			rn.dedupe.evictAt(plan.fingerprints)
+		}
+	}
+	if cfg.shuffleParts > 0 || cfg.sampleRate > 0 {
+		seed := cfg.seed
+		if !cfg.seeded {
+			seed = rand.Uint64()
+		}
+		rn.res.Seed = seed
+		rn.rng = newRand(seed)
+	}
+	if cfg.overlap > 0 {
+		rn.tail = newOverlapTail(cfg.overlap, plan.tailBytes)
+	}
+	if cfg.checksum && cfg.parallelHash > 0 && cfg.vertical == nil && cfg.shuffleParts == 0 && !cfg.syslog {
+		// Vertical, shuffled and syslog splits keep every part open, so
+		// they hash inline rather than wait on hashers that can't finish.
+		rn.hashes = newHashPool(cfg.parallelHash)
+	}
+	if cfg.rateLimit > 0 {
+		rn.limiter = newRateLimiter(cfg.rateLimit, cfg.rateBurst)
+	}
+	if rn.mapped != nil {
+		rn.reader = rn.mapped
+		return rn, nil
+	}
+	if plan.pipelineDepth > 0 {
+		rn.readAhead = newReadAhead(ctx, r, plan.pipelineDepth, rn.bufSize)
+		r = rn.readAhead
+	}
+	if cfg.maxIdle > 0 {
+		r = newIdleReader(ctx, r, cfg.maxIdle)
+	}
+	rn.reader = bufio.NewReaderSize(r, rn.bufSize)
+	return rn, nil
+}
+
+// close releases resources held for the run.
+func (rn *run) close() {
+	if rn.memory != nil {
+		rn.res.PeakMemory = rn.memory.close()
+		if rn.res.PeakMemory > uint64(rn.cfg.maxMemory) {
+			rn.log.Warn(fmt.Sprintf("Memory use peaked at %s, over the %s limit", SizeString(int64(rn.res.PeakMemory)), SizeString(rn.cfg.maxMemory)),
+				"peak", rn.res.PeakMemory, "limit", rn.cfg.maxMemory)
+		}
+	}
+	if rn.mapped != nil {
+		rn.mapped.Close()
+	}
+	if rn.readAhead != nil {
+		rn.readAhead.Close()
+	}
+	if rn.transform != nil {
+		rn.transform.Close()
+	}
+}
+
+// split runs the read loop to completion, cancellation or the first error.
+func (rn *run) split() (res *Result, err error) {
+	cfg := rn.cfg
+	if cfg.maxMemory > 0 {
+		rn.memory = watchMemory()
+	}
+	if rn.mapped != nil {
+		defer panicOnFault()()
+		defer rn.recoverFault(&res, &err)
+	}
+	if _, err := rn.reader.Peek(1); err == io.EOF && !cfg.allowEmpty {
+		rn.log.Warn("Input is empty, no parts created")
+		return rn.finish()
+	}
+	if cfg.byteChunk > 0 {
+		return rn.splitBytes()
+	}
+	if cfg.vertical != nil {
+		return rn.splitVertical()
+	}
+	if cfg.shuffleParts > 0 {
+		return rn.splitShuffle()
+	}
+	if cfg.syslog {
+		return rn.splitSyslog()
+	}
+	if cfg.sizeAlign {
+		return rn.splitSizeCopy()
+	}
+	if cfg.fastSize() {
+		return rn.splitSizeFast()
+	}
+	var pending []byte // a line longer than the read buffer that must be handled whole
+
+	// Time window and named pattern parts are opened by their first line,
+	// which may name them.
+	lazyStart := cfg.timeField != nil || rn.nameGroup > 0
+	if !lazyStart {
+		if err := rn.newPart(ReasonStart); err != nil {
+			return rn.fail(err)
+		}
+	}
+	// Lines before the first match always form their own part.
+	rn.matchesInPart = cfg.matchesPerPart
+
+	for iter := 0; ; iter++ {
+		if iter%ctxCheckInterval == 0 {
+			if rn.ctx.Err() != nil {
+				return rn.abort()
+			}
+			rn.progress.maybeReport(rn.snapshot)
+		}
+
+		lineBytes, err := rn.reader.ReadSlice('\n')
+		rn.res.BytesRead += int64(len(lineBytes))
+		if lerr := rn.checkLineLen(len(lineBytes), err); lerr != nil {
+			return rn.fail(lerr)
+		}
+		if rn.streaming && (err == nil || err == io.EOF) {
+			if werr := rn.endLongLine(lineBytes); werr != nil {
+				return rn.fail(werr)
+			}
+			if err == io.EOF {
+				break
+			}
+			continue
+		}
+		if pending != nil && (err == nil || err == io.EOF) {
+			lineBytes = append(pending, lineBytes...)
+			pending = nil
+		}
+		// The last line may lack a line ending; it is split like any other
+		// and the next read ends the loop.
+		if err == io.EOF {
+			if len(lineBytes) == 0 {
+				break
+			}
+			err = nil
+		}
+		if errors.Is(err, errIdle) {
+			pending = append(pending, lineBytes...)
+			if rn.lineLen == 0 {
+				if err := rn.closeIdle(); err != nil {
+					return rn.fail(err)
+				}
+			}
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				if cfg.columns != nil || rn.dedupe != nil || cfg.sampleRate > 0 || cfg.overlap > 0 || cfg.records() || cfg.softWrap > 0 || (lazyStart && rn.opened == 0) || rn.idleClosed {
+					pending = append(pending, lineBytes...)
+					continue
+				}
+				if cfg.truncate > 0 {
+					// Only the kept bytes are needed, plus one to know the line
+					// is over the limit.
+					if room := cfg.truncate + 1 - len(pending); room > 0 {
+						pending = append(pending, lineBytes[:min(room, len(lineBytes))]...)
+					}
+					continue
+				}
+				if rn.streaming {
+					if err := rn.writeThrough(lineBytes); err != nil {
+						return rn.fail(err)
+					}
+					continue
+				}
+				pending = append(pending, lineBytes...)
+				if rn.maxSize > 0 && rn.lineCount > 0 && rn.written+int64(len(pending)) <= rn.maxSize {
+					// The line may still fit in the current part; only its
+					// full length can tell.
+					continue
+				}
+				// Enough of the line is read to choose its part, so the
+				// rest is written as it is read rather than held.
+				matched, label, window := rn.classify(pending)
+				if err := rn.rotateFor(int64(len(pending)), matched, label, window); err != nil {
+					return rn.fail(err)
+				}
+				if err := rn.writeThrough(pending); err != nil {
+					return rn.fail(err)
+				}
+				rn.streaming, pending = true, nil
+				continue
+			}
+			if rn.ctx.Err() != nil {
+				return rn.abort()
+			}
+			return rn.fail(rn.inputErr(fmt.Errorf("read line %d: %w", rn.res.Lines+1, err)))
+		}
+
+		matched, label, window := rn.classify(lineBytes)
+		raw := lineBytes
+		if cfg.columns != nil {
+			if lineBytes, err = cfg.columns.apply(lineBytes); err != nil {
+				return rn.fail(rn.inputErr(fmt.Errorf("select columns on line %d: %w", rn.res.Lines+1, err)))
+			}
+		}
+		lineBytes = rn.endLine(rn.softWrap(rn.truncate(lineBytes)))
+		if rn.duplicate(lineBytes) || rn.sampledOut() {
+			continue
+		}
+		if cfg.records() {
+			if taken, err := rn.bufferRecord(trimEOL(raw), lineBytes); err != nil {
+				return rn.fail(err)
+			} else if taken {
+				continue
+			}
+		}
+		if err := rn.rotateFor(int64(len(lineBytes)), matched, label, window); err != nil {
+			return rn.fail(err)
+		}
+
+		if err := rn.write(lineBytes); err != nil {
+			return rn.fail(err)
+		}
+		rn.record(lineBytes)
+		rn.warnLongLine()
+		rn.addTail(lineBytes)
+		rn.lineCount++
+		rn.written += int64(len(lineBytes))
+	}
+
+	if err := rn.endRecords(); err != nil {
+		return rn.fail(err)
+	}
+	if lazyStart && cfg.allowEmpty && rn.opened == 0 {
+		var err error
+		if cfg.timeField != nil {
+			err = rn.newWindow(untimedWindow)
+		} else {
+			err = rn.newPart(ReasonStart)
+		}
+		if err != nil {
+			return rn.fail(err)
+		}
+	}
+	return rn.finish()
+}
+
+// classify matches line against the pattern and finds its time window.
+// Patterns see the line without its ending so that `$` anchors work.
+func (rn *run) classify(line []byte) (matched bool, label, window string) {
+	if rn.nameGroup > 0 {
+		label, matched = rn.matchLabel(line)
+	} else {
+		matched = rn.match != nil && rn.match(trimEOL(line))
+	}
+	if rn.cfg.timeField != nil {
+		window = rn.lineWindow(line)
+	}
+	return matched, label, window
+}
+
+// rotateFor starts the part a line of n bytes goes in, if that isn't the
+// current one, given what classify found for the line.
+func (rn *run) rotateFor(n int64, matched bool, label, window string) error {
+	cfg := rn.cfg
+	var reason RotateReason
+	rotate := true
+	switch {
+	case cfg.timeField != nil && window != rn.window:
+		if err := rn.newWindow(window); err != nil {
+			return err
+		}
+		rotate = false
+	case rn.nameGroup > 0 && rn.opened == 0:
+		rn.label = label
+		if err := rn.newPart(ReasonStart); err != nil {
+			return err
+		}
+		if !matched {
+			rn.matchesInPart = cfg.matchesPerPart
+		}
+		rotate = false
+	case rn.idleClosed:
+		reason = ReasonIdle
+	case cfg.maxLines > 0 && rn.atLineLimit():
+		reason = ReasonLines
+	case cfg.rotateEvery > 0 && rn.lineCount > 0 && time.Since(rn.partOpened) >= cfg.rotateEvery:
+		reason = ReasonInterval
+	case rn.maxSize > 0 && rn.lineCount > 0 && rn.written+n > rn.maxSize:
+		// A line larger than the limit goes in the current part if it is
+		// still empty, rather than leaving an empty part behind.
+		reason = ReasonSize
+	case matched && rn.matchesInPart >= cfg.matchesPerPart && rn.lineCount == 0:
+		// A match on the first line is the first part's own match rather
+		// than the end of an empty part.
+		rn.matchesInPart = 0
+		rotate = false
+	case matched && rn.matchesInPart >= cfg.matchesPerPart:
+		reason = ReasonPattern
+		rn.label = label
+	default:
+		rotate = false
+	}
+	if rotate {
+		if err := rn.newPart(reason); err != nil {
+			return err
+		}
+	}
+	if matched {
+		rn.matchesInPart++
+	}
+	return nil
+}
+
+// writeThrough writes part of a line longer than the read buffer to the
+// current part as it is read.
+func (rn *run) writeThrough(p []byte) error {
+	if err := rn.write(p); err != nil {
+		return err
+	}
+	n := int64(len(p))
+	rn.res.Parts[len(rn.res.Parts)-1].Bytes += n
+	rn.res.BytesWritten += n
+	rn.written += n
+	return nil
+}
+
+// endLongLine writes the end of a line being written through and accounts
+// the whole line once.
+func (rn *run) endLongLine(last []byte) error {
+	rn.streaming = false
+	eol := len(last) - len(trimEOL(last))
+	if err := rn.writeThrough(rn.endLine(last)); err != nil {
+		return err
+	}
+	rn.recordLine(0, int(rn.lastLineLen)-eol)
+	rn.warnLongLine()
+	rn.lineCount++
+	return nil
+}
+
+// checkLineLen accounts n more bytes of the line being read, which ends
+// unless readErr is bufio.ErrBufferFull or errIdle, and fails once the line is longer
+// than the line length limit. It also tracks the longest line read.
+func (rn *run) checkLineLen(n int, readErr error) error {
+	rn.lineLen += int64(n)
+	if rn.cfg.maxLineLen > 0 && rn.lineLen > rn.cfg.maxLineLen {
+		return rn.inputErr(fmt.Errorf("line %d is longer than the %d-byte limit", rn.res.Lines+1, rn.cfg.maxLineLen))
+	}
+	if !errors.Is(readErr, bufio.ErrBufferFull) && !errors.Is(readErr, errIdle) {
+		rn.lastLineLen, rn.lineLen = rn.lineLen, 0
+		rn.res.LongestLine = max(rn.res.LongestLine, rn.lastLineLen)
+	}
+	return nil
+}
+
+// warnLongLine reports the line just written if it is over the warning
+// threshold.
+func (rn *run) warnLongLine() {
+	limit := rn.cfg.warnLineLen
+	if limit == 0 || rn.lastLineLen <= limit {
+		return
+	}
+	rn.res.LongLines++
+	part := rn.outInfo.Index
+	rn.log.Warn(fmt.Sprintf("Line %d in part %d is %d bytes, over the %d-byte limit", rn.res.Lines, part, rn.lastLineLen, limit),
+		"line", rn.res.Lines, "part", part, "length", rn.lastLineLen, "limit", limit)
+}
+
+// atLineLimit reports whether the line limit ends the current part. With
+// line alignment the part ends on the last multiple of alignLines, counted
+// over the whole input, that keeps it within the limit.
+func (rn *run) atLineLimit() bool {
+	if a := int64(rn.cfg.alignLines); a > 0 {
+		return rn.lineCount > 0 && rn.res.Lines%a == 0 && int64(rn.lineCount)+a > int64(rn.cfg.maxLines)
+	}
+	return rn.lineCount >= rn.cfg.maxLines
+}
+
+// finish closes the last part and completes the result of a successful run.
+func (rn *run) finish() (*Result, error) {
+	if err := rn.writeTrailer(); err != nil {
+		return rn.fail(err)
+	}
+	if err := rn.finishPart(); err != nil {
+		return rn.fail(err)
+	}
+	rn.collectHashes(true)
+	if rn.inputHash != nil {
+		rn.res.InputChecksum = hex.EncodeToString(rn.inputHash.Sum(nil))
+	}
+	rn.progress.report(rn.snapshot)
+	rn.res.Elapsed = time.Since(rn.start)
+	if n := rn.cfg.expectParts; n > 0 && len(rn.res.Parts) != n {
+		return rn.res, &PartCountError{Expected: n, Actual: len(rn.res.Parts)}
+	}
+	return rn.res, nil
+}
+
+// closeIdle closes the current part once the input has been idle
+// WithMaxIdle, unless it has no lines yet. The next line starts a new part.
+func (rn *run) closeIdle() error {
+	if rn.idleClosed || rn.lineCount == 0 {
+		return nil
+	}
+	rn.log.Info("💤 Input idle, closing part", "part", rn.outInfo.Index, "file", rn.outInfo.Name, "idle", rn.cfg.maxIdle.String())
+	rn.idleClosed = true
+	return rn.finishPart()
+}
+
+// writeTrailer appends the WithTrailer block to the last part.
+func (rn *run) writeTrailer() error {
+	if rn.cfg.trailer == nil || rn.out == nil {
+		return nil
+	}
+	rn.collectHashes(true)
+	cur := &rn.res.Parts[len(rn.res.Parts)-1]
+	if rn.hasher != nil {
+		cur.Checksum = hex.EncodeToString(rn.hasher.Sum(nil))
+	}
+	if rn.async != nil {
+		cur.Checksum = rn.async.sumSoFar()
+	}
+	trailer := rn.cfg.trailer(slices.Clone(rn.res.Parts))
+	if len(trailer) == 0 {
+		return nil
+	}
+	if cur.Bytes > 0 && !rn.endsLine {
+		trailer = append([]byte{'\n'}, trailer...)
+	}
+	if err := rn.write(trailer); err != nil {
+		return err
+	}
+	cur.Bytes += int64(len(trailer))
+	rn.res.BytesWritten += int64(len(trailer))
+	return nil
+}
+
+// splitBytes copies the input into parts of exactly cfg.byteChunk bytes.
+// No part is created until there is data for it.
+func (rn *run) splitBytes() (*Result, error) {
+	buf := make([]byte, rn.bufSize)
+	started := false
+	var inPart int64
+	for {
+		if rn.ctx.Err() != nil {
+			return rn.abort()
+		}
+		rn.progress.maybeReport(rn.snapshot)
+
+		if inPart == rn.cfg.byteChunk {
+			inPart = 0
+		}
+		want := min(int64(len(buf)), rn.cfg.byteChunk-inPart)
+		n, err := io.ReadFull(rn.reader, buf[:want])
+		rn.res.BytesRead += int64(n)
+		if n > 0 {
+			if !started || inPart == 0 {
+				reason := ReasonSize
+				if !started {
+					reason = ReasonStart
+				}
+				if err := rn.newPart(reason); err != nil {
+					return rn.fail(err)
+				}
+				started = true
+			}
+			if err := rn.write(buf[:n]); err != nil {
+				return rn.fail(err)
+			}
+			cur := &rn.res.Parts[len(rn.res.Parts)-1]
+			cur.Bytes += int64(n)
+			cur.InputEnd = rn.res.BytesRead
+			rn.res.BytesWritten += int64(n)
+			inPart += int64(n)
+			rn.written = inPart
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			if rn.ctx.Err() != nil {
+				return rn.abort()
+			}
+			return rn.fail(rn.inputErr(err))
+		}
+	}
+	if !started && rn.cfg.allowEmpty {
+		if err := rn.newPart(ReasonStart); err != nil {
+			return rn.fail(err)
+		}
+	}
+	return rn.finish()
+}
+
+// newPart closes the current part and opens the next one.
+func (rn *run) newPart(reason RotateReason) error {
+	cfg := rn.cfg
+	if err := rn.finishPart(); err != nil {
+		return err
+	}
+	index, err := rn.index()
+	if err != nil {
+		return err
+	}
+	suffix := fmt.Sprintf("%0*d", cfg.padWidth, index)
+	if cfg.timeField != nil {
+		suffix = rn.labelSuffix(rn.window)
+	} else if label := cfg.partLabel(rn.label); label != "" {
+		suffix = rn.labelSuffix(label)
+	}
+	if cfg.timestamp {
+		suffix = fmt.Sprintf("%s_%s", suffix, cfg.partTime(time.Now()))
+	}
+	if err := rn.openPart(reason, filepath.Join(cfg.outputDir, fmt.Sprintf("%s%s.%s", cfg.prefix, suffix, cfg.ext)), index); err != nil {
+		return err
+	}
+	if cfg.timeField == nil {
+		rn.res.Parts[len(rn.res.Parts)-1].Capture = rn.label
+	}
+	return rn.writeOverlap()
+}
+
+// writeOverlap starts the new part with the lines kept WithOverlap. They
+// are accounted in the part's stats, but not in the counts its limits
+// are checked against.
+func (rn *run) writeOverlap() error {
+	cur := &rn.res.Parts[len(rn.res.Parts)-1]
+	for _, line := range rn.tail.lines() {
+		if err := rn.write(line.text); err != nil {
+			return err
+		}
+		if rn.cfg.lineStats {
+			rn.lengths.add(len(trimEOL(line.text)))
+		}
+		if cur.Lines == 0 {
+			cur.StartLine = line.num
+		}
+		cur.Lines++
+		cur.Bytes += int64(len(line.text))
+		rn.res.BytesWritten += int64(len(line.text))
+	}
+	return nil
+}
+
+// addTail keeps line for the overlap, warning the first time the memory
+// limit leaves room for fewer lines than asked for.
+func (rn *run) addTail(line []byte) {
+	if rn.tail.add(line, rn.res.Lines) && !rn.tailShrunk {
+		rn.tailShrunk = true
+		rn.log.Warn(fmt.Sprintf("Overlap reduced to %d of %d lines to stay within the memory limit", rn.tail.n, rn.cfg.overlap), "lines", rn.tail.n, "requested", rn.cfg.overlap)
+	}
+}
+
+// newWindow starts the part of a new time window.
+func (rn *run) newWindow(window string) error {
+	reason := ReasonTime
+	if rn.window == "" {
+		reason = ReasonStart
+	}
+	rn.window = window
+	return rn.newPart(reason)
+}
+
+// index returns the number of the next part: rn.part, or counted down
+// from the last number WithReverseIndex.
+func (rn *run) index() (int, error) {
+	total := rn.cfg.reverseTotal
+	if total == 0 {
+		return rn.part, nil
+	}
+	n := rn.part - rn.cfg.firstPart
+	if n >= total {
+		return 0, fmt.Errorf("part %d is more than the %d parts given for reverse indexing", n+1, total)
+	}
+	return rn.cfg.firstPart + total - 1 - n, nil
+}
+
+// openPart opens the next part under filename, numbered index, and makes
+// it current.
+func (rn *run) openPart(reason RotateReason, filename string, index int) error {
+	cfg := rn.cfg
+	appending := cfg.appendTo > 0 && rn.opened == 0
+	info := PartInfo{Index: index, Name: filename, Reason: reason, StartLine: rn.res.Lines + 1, Append: appending}
+	rn.outInfo = info
+	if cfg.dryRun {
+		// Nothing is opened, but the part is accounted like a real one.
+		rn.dryOpen = true
+	} else {
+		if err := cfg.fault.createErr(rn.part); err != nil {
+			return &OutputError{Part: filename, Err: err}
+		}
+		var w io.WriteCloser
+		var err error
+		if appending {
+			w, err = cfg.sink.(PartAppender).AppendPart(info)
+		} else {
+			w, err = cfg.sink.NewPart(info)
+		}
+		if err != nil {
+			return &OutputError{Part: filename, Err: err}
+		}
+		rn.out = w
+		rn.writer = w
+		if cfg.fault.failsWrite(rn.part) {
+			rn.writer = failingWriter{part: rn.part}
+		}
+		if rn.limiter != nil {
+			rn.writer = &rateLimitedWriter{ctx: rn.ctx, w: rn.writer, limiter: rn.limiter}
+		}
+		if rn.hashes != nil {
+			rn.async = rn.hashes.start()
+			rn.writer = io.MultiWriter(rn.writer, rn.async)
+			if appending {
+				if err := rn.hashExisting(info); err != nil {
+					return err
+				}
+			}
+		} else if cfg.checksum {
+			rn.hasher = sha256.New()
+			rn.writer = io.MultiWriter(rn.writer, rn.hasher)
+			if appending {
+				if err := rn.hashExisting(info); err != nil {
+					return err
+				}
+			}
+		}
+		if appending {
+			rn.log.Info("📎 Appending to", "part", index, "file", filename)
+		} else {
+			rn.log.Info("✂️  Creating", "part", index, "file", filename, "reason", reason.String())
+		}
+	}
+	rn.res.Parts = append(rn.res.Parts, PartStats{Name: filename})
+	rn.written = 0
+	rn.lineCount = 0
+	if appending {
+		cur := &rn.res.Parts[len(rn.res.Parts)-1]
+		cur.Lines, cur.Bytes = cfg.appendLines, cfg.appendBytes
+		rn.lineCount, rn.written = int(cfg.appendLines), cfg.appendBytes
+	}
+	rn.idleClosed = false
+	rn.matchesInPart = 0
+	rn.maxSize = cfg.partMaxSize(rn.opened)
+	rn.partOpened = time.Now()
+	rn.opened++
+	rn.part++
+	return nil
+}
+
+// hashExisting feeds what the part being appended to already holds to
+// its checksum.
+func (rn *run) hashExisting(info PartInfo) error {
+	r, err := rn.cfg.sink.(PartAppender).OpenPart(info)
+	if err != nil {
+		return &OutputError{Part: info.Name, Err: err}
+	}
+	defer r.Close()
+	var h io.Writer = rn.hasher
+	if rn.async != nil {
+		h = rn.async
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return &OutputError{Part: info.Name, Err: err}
+	}
+	return nil
+}
+
+// finishPart closes the current part, if any, and completes its stats.
+func (rn *run) finishPart() error {
+	if rn.dryOpen {
+		rn.dryOpen = false
+		rn.logDryPart(rn.outInfo, len(rn.res.Parts)-1)
+		return nil
+	}
+	if rn.out == nil {
+		return nil
+	}
+	_, left := rn.out.(partialCloser)
+	err := rn.out.Close()
+	rn.out = nil
+	cur := &rn.res.Parts[len(rn.res.Parts)-1]
+	cur.Closed = time.Now()
+	if rn.hasher != nil {
+		cur.Checksum = hex.EncodeToString(rn.hasher.Sum(nil))
+	}
+	h := rn.async
+	if h != nil {
+		h.close()
+		rn.async = nil
+	}
+	if rn.cfg.lineStats {
+		cur.MinLineLen, cur.MaxLineLen = rn.lengths.min, rn.lengths.max
+		cur.MeanLineLen, cur.StddevLineLen = rn.lengths.mean, rn.lengths.stddev()
+		rn.lengths = lineStats{}
+	}
+	if err != nil {
+		err = &OutputError{Part: cur.Name, Offset: cur.Bytes, Err: err}
+		if !rn.failed {
+			rn.discardFailed(rn.outInfo, left)
+		}
+		return err
+	}
+	if rn.cfg.pruneEmpty && cur.Bytes == 0 {
+		return rn.prune()
+	}
+	if h != nil {
+		rn.hashes.pending = append(rn.hashes.pending, pendingHash{info: rn.outInfo, stats: len(rn.res.Parts) - 1, h: h})
+		rn.collectHashes(false)
+		return nil
+	}
+	rn.partDone(rn.outInfo, *cur)
+	return nil
+}
+
+// collectHashes sets the checksums of closed parts whose hashes are done
+// WithParallelHash, and reports the parts as done, in the order they were
+// closed. With wait, it waits for every hash.
+func (rn *run) collectHashes(wait bool) {
+	if rn.hashes == nil {
+		return
+	}
+	p := rn.hashes
+	n := 0
+	for _, ph := range p.pending {
+		if !wait && !ph.h.finished() {
+			break
+		}
+		sum := ph.h.wait()
+		n++
+		if ph.stats >= len(rn.res.Parts) || rn.res.Parts[ph.stats].Name != ph.info.Name {
+			continue
+		}
+		cur := &rn.res.Parts[ph.stats]
+		cur.Checksum = sum
+		rn.partDone(ph.info, *cur)
+	}
+	p.pending = p.pending[n:]
+}
+
+// partDone reports a complete part WithPartDone.
+func (rn *run) partDone(info PartInfo, stats PartStats) {
+	if rn.cfg.onPartDone != nil && !rn.failed {
+		rn.cfg.onPartDone(info, stats)
+	}
+}
+
+// logDryPart reports the part a dry run would have written, with the
+// lines and bytes it would hold.
+func (rn *run) logDryPart(info PartInfo, stats int) {
+	p := rn.res.Parts[stats]
+	rn.log.Info(fmt.Sprintf("[DryRun] Would create (%d lines, %d bytes)", p.Lines, p.Bytes),
+		"part", info.Index, "file", info.Name, "lines", p.Lines, "bytes", p.Bytes)
+}
+
+// prune deletes the part just closed. When renumbering, it is dropped
+// from the result and its index is given to the next part.
+func (rn *run) prune() error {
+	info := rn.outInfo
+	if err := rn.cfg.sink.(PartRemover).Remove(info); err != nil {
+		return &OutputError{Part: info.Name, Err: err}
+	}
+	rn.res.PrunedParts++
+	rn.log.Info("🗑️  Pruned empty part", "part", info.Index, "file", info.Name)
+	if rn.cfg.keepNumbering {
+		rn.res.Parts[len(rn.res.Parts)-1].Pruned = true
+		return nil
+	}
+	rn.res.Parts = rn.res.Parts[:len(rn.res.Parts)-1]
+	rn.part--
+	return nil
+}
+
+// duplicate reports whether line is a near-duplicate to be skipped, and
+// accounts it as read if so.
+func (rn *run) duplicate(line []byte) bool {
+	if rn.dedupe == nil {
+		return false
+	}
+	wasFull := rn.dedupe.full()
+	if rn.dedupe.seen(trimEOL(line)) {
+		rn.res.Lines++
+		rn.res.Duplicates++
+		return true
+	}
+	if !wasFull && rn.dedupe.full() && rn.dedupe.evict {
+		rn.log.Warn(fmt.Sprintf("Fuzzy dedupe holds the %d fingerprints the memory limit allows, the least recently seen are forgotten from now on", rn.dedupe.limit), "limit", rn.dedupe.limit)
+	} else if !wasFull && rn.dedupe.full() {
+		rn.log.Warn("Fuzzy dedupe limit reached, later lines are only checked against earlier ones", "limit", rn.cfg.dedupeLimit)
+	}
+	return false
+}
+
+// truncationMarker ends a line shortened by truncate.
+const truncationMarker = "..."
+
+// truncate cuts a line body longer than cfg.truncate bytes down to that
+// length, ending in truncationMarker, and keeps the line ending. The cut
+// never splits a UTF-8 sequence.
+func (rn *run) truncate(line []byte) []byte {
+	n := rn.cfg.truncate
+	body := trimEOL(line)
+	if n == 0 || len(body) <= n {
+		return line
+	}
+	keep := max(n-len(truncationMarker), 0)
+	for keep > 0 && !utf8.RuneStart(body[keep]) {
+		keep--
+	}
+	out := make([]byte, 0, n+2)
+	out = append(out, body[:keep]...)
+	out = append(out, truncationMarker[:min(len(truncationMarker), n)]...)
+	rn.res.TruncatedLines++
+	return append(out, line[len(body):]...)
+}
+
+// softWrap breaks line after every cfg.softWrap characters of its body,
+// ending each piece as the line ends, or with the record separator when
+// line endings are being rewritten. Characters are UTF-8 runes, which are
+// never split; bytes that aren't valid UTF-8 count one each.
+func (rn *run) softWrap(line []byte) []byte {
+	width := rn.cfg.softWrap
+	// A line of no more bytes than width has no more characters either.
+	if width == 0 || len(line) <= width {
+		return line
+	}
+	body := trimEOL(line)
+	brk := line[len(body):]
+	switch {
+	case rn.cfg.rewriteEOL:
+		brk = rn.cfg.recordSep
+	case len(brk) == 0:
+		brk = []byte{'\n'}
+	}
+	out := make([]byte, 0, len(line)+len(body)/width*len(brk))
+	start, cols := 0, 0
+	for i := 0; i < len(body); {
+		if cols == width {
+			out = append(append(out, body[start:i]...), brk...)
+			start, cols = i, 0
+		}
+		_, size := utf8.DecodeRune(body[i:])
+		i += size
+		cols++
+	}
+	out = append(out, body[start:]...)
+	return append(out, line[len(body):]...)
+}
+
+// endLine replaces the ending of line with the record separator when
+// line endings are being rewritten. A final line without an ending is
+// left as is.
+func (rn *run) endLine(line []byte) []byte {
+	if !rn.cfg.rewriteEOL {
+		return line
+	}
+	body := trimEOL(line)
+	if len(body) == len(line) {
+		return line
+	}
+	// The full slice expression keeps append from overwriting the read buffer.
+	return append(body[:len(body):len(body)], rn.cfg.recordSep...)
+}
+
+// write writes p to the current part. A dry run writes nothing.
+func (rn *run) write(p []byte) error {
+	if rn.cfg.dryRun {
+		return nil
+	}
+	if _, err := rn.writer.Write(p); err != nil {
+		return &OutputError{Part: rn.outInfo.Name, Offset: rn.res.Parts[len(rn.res.Parts)-1].Bytes, Err: err}
+	}
+	if len(p) > 0 {
+		rn.endsLine = p[len(p)-1] == '\n'
+	}
+	return nil
+}
+
+// inputErr wraps err with the current input offset.
+func (rn *run) inputErr(err error) error {
+	return &InputError{Offset: rn.res.BytesRead, Err: err}
+}
+
+// record accounts a complete line in the current part's stats.
+func (rn *run) record(line []byte) {
+	rn.recordLine(int64(len(line)), len(trimEOL(line)))
+}
+
+// recordLine is record for a line of body bytes without its ending, of
+// which n bytes are yet to be added to the part's size.
+func (rn *run) recordLine(n int64, body int) {
+	rn.res.Lines++
+	if rn.cfg.lineStats {
+		rn.lengths.add(body)
+	}
+	cur := &rn.res.Parts[len(rn.res.Parts)-1]
+	if cur.StartLine == 0 {
+		cur.StartLine = rn.res.Lines
+	}
+	cur.Lines++
+	cur.EndLine = rn.res.Lines
+	cur.InputEnd = rn.res.BytesRead
+	cur.Bytes += n
+	rn.res.BytesWritten += n
+}
+
+// fail closes the current part and returns the partial result with err.
+func (rn *run) fail(err error) (*Result, error) {
+	rn.collectHashes(true)
+	rn.failed = true
+	var outErr *OutputError
+	if rn.out != nil && errors.As(err, &outErr) && outErr.Part == rn.outInfo.Name {
+		info := rn.outInfo
+		var left bool
+		rn.out, left = leavePartial(rn.out)
+		pruned := rn.res.PrunedParts
+		rn.finishPart()
+		if rn.res.PrunedParts == pruned {
+			rn.discardFailed(info, left)
+		}
+	}
+	rn.finishPart()
+	rn.collectHashes(true)
+	rn.res.Truncated = true
+	rn.res.Elapsed = time.Since(rn.start)
+	return rn.res, err
+}
+
+// abort closes the part being written when ctx is done, optionally
+// removing it since its content is incomplete.
+func (rn *run) abort() (*Result, error) {
+	rn.collectHashes(true)
+	rn.failed = true
+	completed := len(rn.res.Parts)
+	if rn.dryOpen {
+		completed--
+		rn.finishPart()
+	}
+	var partial []string
+	if rn.out != nil {
+		completed--
+		info := rn.outInfo
+		var left bool
+		rn.out, left = leavePartial(rn.out)
+		pruned := rn.res.PrunedParts
+		rn.finishPart()
+		if rn.res.PrunedParts == pruned {
+			partial = rn.abandon(info, left, partial)
+		}
+	}
+	rn.collectHashes(true)
+	rn.res.Truncated = true
+	rn.res.Elapsed = time.Since(rn.start)
+	return rn.res, &CancelError{BytesRead: rn.res.BytesRead, Lines: rn.res.Lines, PartsCompleted: completed, Partial: partial, Err: rn.ctx.Err()}
+}
+
+// leavePartial makes closing w leave its part incomplete rather than
+// complete it, and reports whether w completes parts on Close.
+func leavePartial(w io.WriteCloser) (io.WriteCloser, bool) {
+	if pc, ok := w.(partialCloser); ok {
+		return partialClose{w, pc}, true
+	}
+	return w, false
+}
+
+// partialClose is a part writer whose Close leaves the part incomplete.
+type partialClose struct {
+	io.WriteCloser
+	pc partialCloser
+}
+
+func (p partialClose) Close() error { return p.pc.ClosePartial() }
+
+// abandon removes or marks the incomplete part described by info, already
+// closed, and appends where it was left to partial. left says the part
+// was left under its partial name by an atomic writer.
+func (rn *run) abandon(info PartInfo, left bool, partial []string) []string {
+	cfg := rn.cfg
+	switch {
+	case info.Append:
+		// The part held complete data before this run; leave it be.
+	case cfg.removePartial:
+		if a, ok := cfg.sink.(PartAborter); ok && a.Abort(info) == nil {
+			return partial
+		}
+	case cfg.markPartial:
+		name, err := cfg.sink.(PartMarker).MarkPartial(info)
+		if err == nil {
+			return append(partial, name)
+		}
+		rn.log.Warn(fmt.Sprintf("Could not mark incomplete part partial: %v", err), "part", info.Index, "file", info.Name)
+	}
+	if left {
+		return append(partial, info.Name+partialSuffix)
+	}
+	return append(partial, info.Name)
+}
+
+// discardFailed removes the part described by info, already closed, whose
+// write or close failed so that it ends wherever writing stopped, or marks
+// it partial WithMarkPartial, if the sink can. It is then no longer counted
+// as written. left says the part is still under its partial name. A part
+// appended to is left as it is, since it held complete data before this
+// run.
+func (rn *run) discardFailed(info PartInfo, left bool) {
+	cfg := rn.cfg
+	if info.Append {
+		return
+	}
+	name := info.Name
+	if left {
+		name += partialSuffix
+	}
+	if cfg.markPartial {
+		marked, err := cfg.sink.(PartMarker).MarkPartial(info)
+		if err == nil {
+			rn.log.Warn("Incomplete part marked partial", "part", info.Index, "file", marked)
+			rn.dropPart(info)
+			return
+		}
+		rn.log.Warn(fmt.Sprintf("Could not mark incomplete part partial: %v", err), "part", info.Index, "file", name)
+	} else if a, ok := cfg.sink.(PartAborter); ok {
+		err := a.Abort(info)
+		if err == nil {
+			rn.log.Warn("🗑️  Removed incomplete part", "part", info.Index, "file", name)
+			rn.dropPart(info)
+			return
+		}
+		rn.log.Warn(fmt.Sprintf("Could not remove incomplete part: %v", err), "part", info.Index, "file", name)
+	}
+}
+
+// dropPart removes the stats of the part described by info, if it is the
+// last part, from the result.
+func (rn *run) dropPart(info PartInfo) {
+	if last := len(rn.res.Parts) - 1; last >= 0 && rn.res.Parts[last].Name == info.Name {
+		rn.res.Parts = rn.res.Parts[:last]
+	}
+}
+
+func (rn *run) snapshot() Progress {
+	current := rn.outInfo.Index
+	if current < 1 {
+		current = 1
+	}
+	return Progress{BytesRead: rn.res.BytesRead, TotalBytes: rn.totalBytes, Lines: rn.res.Lines, Part: current, PartBytes: rn.written}
+}
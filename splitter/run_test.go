@@ -0,0 +1,209 @@
+package splitter
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestLongLines splits lines of 200KB and 5MB, longer than the read
+// buffer, against limits they overflow. Each long line must be counted
+// once, be measured at its full length for -size, and never be split.
+func TestLongLines(t *testing.T) {
+	long := strings.Repeat("x", 200<<10) + "\n"
+	huge := "START" + strings.Repeat("y", 5<<20) + "\n"
+	input := "a\nb\n" + long + "c\n" + huge + "d\n"
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		parts []string
+		lines []int64
+	}{{
+		name:  "size",
+		opts:  []Option{WithMaxSize(1 << 10)},
+		parts: []string{"a\nb\n", long, "c\n", huge, "d\n"},
+		lines: []int64{2, 1, 1, 1, 1},
+	}, {
+		// -lines keeps the line loop rather than the size-only fast path.
+		name:  "size in line loop",
+		opts:  []Option{WithMaxSize(1 << 10), WithMaxLines(1000)},
+		parts: []string{"a\nb\n", long, "c\n", huge, "d\n"},
+		lines: []int64{2, 1, 1, 1, 1},
+	}, {
+		name:  "line fits",
+		opts:  []Option{WithMaxSize(300 << 10), WithMaxLines(1000)},
+		parts: []string{"a\nb\n" + long + "c\n", huge, "d\n"},
+		lines: []int64{4, 1, 1},
+	}, {
+		name:  "lines",
+		opts:  []Option{WithMaxLines(2)},
+		parts: []string{"a\nb\n", long + "c\n", huge + "d\n"},
+		lines: []int64{2, 2, 2},
+	}, {
+		name:  "pattern on long line",
+		opts:  []Option{WithPattern(regexp.MustCompile(`^START`))},
+		parts: []string{"a\nb\n" + long + "c\n", huge + "d\n"},
+		lines: []int64{4, 2},
+	}, {
+		name:  "small buffer",
+		opts:  []Option{WithMaxSize(1 << 10), WithMaxLines(1000), WithBufferSize(minBufSize)},
+		parts: []string{"a\nb\n", long, "c\n", huge, "d\n"},
+		lines: []int64{2, 1, 1, 1, 1},
+	}, {
+		name:  "no read-ahead",
+		opts:  []Option{WithMaxSize(1 << 10), WithMaxLines(1000), WithPipelineDepth(0)},
+		parts: []string{"a\nb\n", long, "c\n", huge, "d\n"},
+		lines: []int64{2, 1, 1, 1, 1},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, sink := splitString(t, input, tt.opts...)
+			got := sink.contents()
+			if len(got) != len(tt.parts) {
+				t.Fatalf("got %d parts of %v bytes, want %d", len(got), partLens(got), len(tt.parts))
+			}
+			for i := range got {
+				if got[i] != tt.parts[i] {
+					t.Errorf("part %d holds %d bytes, want %d", i+1, len(got[i]), len(tt.parts[i]))
+				}
+				p := res.Parts[i]
+				if p.Lines != tt.lines[i] || p.Bytes != int64(len(tt.parts[i])) {
+					t.Errorf("part %d stats: %d lines, %d bytes; want %d lines, %d bytes", i+1, p.Lines, p.Bytes, tt.lines[i], len(tt.parts[i]))
+				}
+			}
+			if res.Lines != 6 || res.BytesWritten != int64(len(input)) {
+				t.Errorf("result: %d lines, %d bytes written; want 6 lines, %d bytes", res.Lines, res.BytesWritten, len(input))
+			}
+			if res.LongestLine != int64(len(huge)) {
+				t.Errorf("longest line %d, want %d", res.LongestLine, len(huge))
+			}
+		})
+	}
+}
+
+func partLens(parts []string) []int {
+	n := make([]int, len(parts))
+	for i, p := range parts {
+		n[i] = len(p)
+	}
+	return n
+}
+
+// TestPatternFirstLineMatch checks that a match on the first line starts
+// the first part instead of ending an empty one.
+func TestPatternFirstLineMatch(t *testing.T) {
+	start := regexp.MustCompile(`^START`)
+	tests := []struct {
+		name  string
+		input string
+		opts  []Option
+		parts []string
+	}{{
+		name:  "first line matches",
+		input: "START 1\na\nSTART 2\nb\n",
+		opts:  []Option{WithPattern(start)},
+		parts: []string{"START 1\na\n", "START 2\nb\n"},
+	}, {
+		name:  "lines before the first match",
+		input: "x\nSTART 1\na\n",
+		opts:  []Option{WithPattern(start)},
+		parts: []string{"x\n", "START 1\na\n"},
+	}, {
+		name:  "first match counts toward matches per part",
+		input: "START 1\nSTART 2\nSTART 3\n",
+		opts:  []Option{WithPattern(start), WithMatchesPerPart(2)},
+		parts: []string{"START 1\nSTART 2\n", "START 3\n"},
+	}, {
+		name:  "dry run",
+		input: "START 1\na\nSTART 2\nb\n",
+		opts:  []Option{WithPattern(start), WithDryRun()},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, sink := splitString(t, tt.input, tt.opts...)
+			if got := sink.contents(); len(tt.parts) > 0 && !slices.Equal(got, tt.parts) {
+				t.Errorf("got parts %q, want %q", got, tt.parts)
+			}
+			for i, p := range res.Parts {
+				if p.Lines == 0 {
+					t.Errorf("part %d (%s) is empty", i+1, p.Name)
+				}
+			}
+			if tt.parts == nil && len(res.Parts) != 2 {
+				t.Errorf("dry run planned %d parts, want 2", len(res.Parts))
+			}
+		})
+	}
+}
+
+// TestAnchoredPattern checks that $-anchored patterns match lines without
+// their line ending, with LF and CRLF endings and on a last line that has
+// none, through both the literal and the regex matcher.
+func TestAnchoredPattern(t *testing.T) {
+	lines := []string{"a", "x END", "b", "END here", "c", "END"}
+	patterns := []struct {
+		expr   string
+		starts []int // lines, 0-based, that start a part
+	}{
+		{`END$`, []int{0, 1, 5}},
+		{`E[N]D$`, []int{0, 1, 5}},
+		{`^END$`, []int{0, 5}},
+		{`^E[N]D$`, []int{0, 5}},
+		{`(?i)end$`, []int{0, 1, 5}},
+	}
+	for _, eol := range []string{"\n", "\r\n"} {
+		for _, lastEOL := range []bool{true, false} {
+			for _, p := range patterns {
+				name := fmt.Sprintf("%s %q last line ended %v", p.expr, eol, lastEOL)
+				t.Run(name, func(t *testing.T) {
+					text := make([]string, len(lines))
+					for i, l := range lines {
+						text[i] = l + eol
+					}
+					if !lastEOL {
+						text[len(text)-1] = lines[len(lines)-1]
+					}
+					var want []string
+					for i, start := range p.starts {
+						end := len(text)
+						if i+1 < len(p.starts) {
+							end = p.starts[i+1]
+						}
+						want = append(want, strings.Join(text[start:end], ""))
+					}
+					_, sink := splitString(t, strings.Join(text, ""), WithPattern(regexp.MustCompile(p.expr)))
+					if got := sink.contents(); !slices.Equal(got, want) {
+						t.Errorf("got parts %q, want %q", got, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+// TestLastLineWithoutEnding checks that a final line without a line ending
+// is split like any other rather than added to the current part.
+func TestLastLineWithoutEnding(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		parts []string
+	}{
+		{"lines", []Option{WithMaxLines(2)}, []string{"aa\nbb\n", "cc"}},
+		{"size", []Option{WithMaxSize(6)}, []string{"aa\nbb\n", "cc"}},
+		{"size line loop", []Option{WithMaxSize(6), WithMaxLines(100)}, []string{"aa\nbb\n", "cc"}},
+		{"size long last line", []Option{WithMaxSize(6), WithBufferSize(minBufSize)}, []string{"aa\nbb\n", strings.Repeat("c", 2*minBufSize)}},
+		{"pattern", []Option{WithPattern(regexp.MustCompile(`^c`))}, []string{"aa\nbb\n", "cc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, sink := splitString(t, strings.Join(tt.parts, ""), tt.opts...)
+			if got := sink.contents(); !slices.Equal(got, tt.parts) {
+				t.Errorf("got parts %q, want %q", got, tt.parts)
+			}
+		})
+	}
+}
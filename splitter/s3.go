@@ -0,0 +1,492 @@
+package splitter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// S3 is the OutputBackend for an Amazon S3 bucket, or storage with the
+// same API such as MinIO. Paths are object keys. Requests are signed with
+// AWS Signature Version 4 and sent with the standard library's HTTP client.
+//
+// An object is uploaded when its writer is closed, in a single request if
+// it is at most PartSize, and as a multipart upload of PartSize parts,
+// started once that much has been written, otherwise. Rename copies the
+// object and deletes the original, as S3 has no rename.
+type S3 struct {
+	Bucket string
+	// Region is the bucket's region; empty means us-east-1.
+	Region string
+	// Endpoint is the service's base URL; empty means AWS's endpoint for
+	// Region.
+	Endpoint string
+	// PathStyle puts the bucket in the URL path rather than the host
+	// name, as most S3-compatible servers need.
+	PathStyle bool
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set with temporary credentials.
+	SessionToken string
+
+	// PartSize is the size of each multipart upload part, and so what
+	// each open object buffers in memory; zero means 16MB. S3 needs at
+	// least 5MB.
+	PartSize int64
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Retry retries requests that fail with a server error, throttling or
+	// a transient network error.
+	Retry Retry
+}
+
+const (
+	s3DefaultPartSize = 16 << 20
+	// s3MinPartSize is the smallest multipart upload part but the last.
+	s3MinPartSize = 5 << 20
+	// s3MaxCopy is the largest object one CopyObject request copies;
+	// larger ones are copied in parts of s3CopyPartSize.
+	s3MaxCopy      = 5 << 30
+	s3CopyPartSize = 1 << 30
+)
+
+// Create starts an object at key path, uploaded when it is closed.
+func (s S3) Create(path string) (io.WriteCloser, error) {
+	size := s.PartSize
+	if size <= 0 {
+		size = s3DefaultPartSize
+	}
+	if size < s3MinPartSize {
+		return nil, fmt.Errorf("s3 part size %s is below the 5MB minimum", SizeString(size))
+	}
+	return &s3Object{s: s, key: s3Key(path), size: int(size)}, nil
+}
+
+// Rename copies the object at oldPath to newPath and deletes the original.
+func (s S3) Rename(oldPath, newPath string) error {
+	from, to := s3Key(oldPath), s3Key(newPath)
+	resp, err := s.do("rename", http.MethodHead, from, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.ContentLength > s3MaxCopy {
+		err = s.copyParts(from, to, resp.ContentLength)
+	} else {
+		err = s.copy(from, to)
+	}
+	if err != nil {
+		return err
+	}
+	return s.Remove(oldPath)
+}
+
+// Remove deletes the object at key path.
+func (s S3) Remove(path string) error {
+	_, err := s.do("remove", http.MethodDelete, s3Key(path), nil, nil, nil)
+	return err
+}
+
+// copy copies from to to in one request.
+func (s S3) copy(from, to string) error {
+	h := http.Header{"X-Amz-Copy-Source": {s.copySource(from)}}
+	resp, err := s.do("rename", http.MethodPut, to, nil, h, nil)
+	if err != nil {
+		return err
+	}
+	var res struct {
+		ETag string
+	}
+	return s.decode("rename", to, resp, &res)
+}
+
+// copyParts copies from, of size bytes, to to with a multipart upload
+// whose parts are ranges of from.
+func (s S3) copyParts(from, to string, size int64) error {
+	up, err := s.startUpload(to)
+	if err != nil {
+		return err
+	}
+	for off := int64(0); off < size; off += s3CopyPartSize {
+		end := min(off+s3CopyPartSize, size) - 1
+		h := http.Header{
+			"X-Amz-Copy-Source":       {s.copySource(from)},
+			"X-Amz-Copy-Source-Range": {fmt.Sprintf("bytes=%d-%d", off, end)},
+		}
+		resp, err := s.do("rename", http.MethodPut, to, up.query(len(up.parts)+1), h, nil)
+		if err == nil {
+			var res struct {
+				ETag string
+			}
+			if err = s.decode("rename", to, resp, &res); err == nil {
+				up.parts = append(up.parts, s3Part{Number: len(up.parts) + 1, ETag: res.ETag})
+			}
+		}
+		if err != nil {
+			up.abort()
+			return err
+		}
+	}
+	return up.complete()
+}
+
+func (s S3) copySource(key string) string {
+	return "/" + s.Bucket + "/" + s3Escape(key, true)
+}
+
+// s3Key returns the object key for a part path, which uses the local path
+// separator and may start with one.
+func s3Key(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
+
+// s3Object buffers an object's data until there is a part of it to upload,
+// or the object is closed.
+type s3Object struct {
+	s    S3
+	key  string
+	size int // bytes of each part
+	buf  []byte
+	up   *s3Upload // nil until the first part is uploaded
+	err  error
+}
+
+func (o *s3Object) Write(b []byte) (int, error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+	n := len(b)
+	for len(b) > 0 {
+		if o.buf == nil {
+			o.buf = make([]byte, 0, o.size)
+		}
+		c := min(len(b), o.size-len(o.buf))
+		o.buf, b = append(o.buf, b[:c]...), b[c:]
+		if len(o.buf) == o.size {
+			// Nothing of a part that failed to upload is kept.
+			if o.err = o.uploadPart(); o.err != nil {
+				return 0, o.err
+			}
+		}
+	}
+	return n, nil
+}
+
+// uploadPart uploads the buffered data as the next part, starting the
+// multipart upload first if this is the first part.
+func (o *s3Object) uploadPart() error {
+	if o.up == nil {
+		up, err := o.s.startUpload(o.key)
+		if err != nil {
+			return err
+		}
+		o.up = up
+	}
+	n := len(o.up.parts) + 1
+	resp, err := o.s.do("write", http.MethodPut, o.key, o.up.query(n), nil, o.buf)
+	if err != nil {
+		o.up.abort()
+		return err
+	}
+	resp.Body.Close()
+	o.up.parts = append(o.up.parts, s3Part{Number: n, ETag: resp.Header.Get("ETag")})
+	o.buf = o.buf[:0]
+	return nil
+}
+
+func (o *s3Object) Close() error {
+	if o.err != nil {
+		return o.err
+	}
+	o.err = errors.New("object already closed")
+	if o.up == nil {
+		resp, err := o.s.do("write", http.MethodPut, o.key, nil, nil, o.buf)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	if len(o.buf) > 0 {
+		if err := o.uploadPart(); err != nil {
+			return err
+		}
+	}
+	return o.up.complete()
+}
+
+// s3Upload is a multipart upload in progress.
+type s3Upload struct {
+	s     S3
+	key   string
+	id    string
+	parts []s3Part
+}
+
+type s3Part struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+}
+
+func (s S3) startUpload(key string) (*s3Upload, error) {
+	resp, err := s.do("create", http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := s.decode("create", key, resp, &res); err != nil {
+		return nil, err
+	}
+	return &s3Upload{s: s, key: key, id: res.UploadID}, nil
+}
+
+// query returns the query of a request for part n of the upload.
+func (u *s3Upload) query(n int) url.Values {
+	return url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {u.id}}
+}
+
+func (u *s3Upload) complete() error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: u.parts})
+	if err != nil {
+		return err
+	}
+	resp, err := u.s.do("close", http.MethodPost, u.key, url.Values{"uploadId": {u.id}}, nil, body)
+	if err == nil {
+		var res struct {
+			ETag string
+		}
+		err = u.s.decode("close", u.key, resp, &res)
+	}
+	if err != nil {
+		u.abort()
+	}
+	return err
+}
+
+// abort drops the parts uploaded so far, which S3 would otherwise keep,
+// and bill for, until the upload is completed.
+func (u *s3Upload) abort() {
+	if resp, err := u.s.do("abort", http.MethodDelete, u.key, url.Values{"uploadId": {u.id}}, nil, nil); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// S3Error is an error response from an S3 request.
+type S3Error struct {
+	Op      string // "create", "write", "close", "rename", "remove" or "abort"
+	Key     string
+	Status  int    // HTTP status code
+	Code    string // S3 error code, such as "AccessDenied"
+	Message string
+}
+
+func (e *S3Error) Error() string {
+	msg := e.Code
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	return fmt.Sprintf("s3 %s %s: %d %s", e.Op, e.Key, e.Status, msg)
+}
+
+// Unwrap returns syscall.EAGAIN for server errors and throttling, which
+// are worth retrying, so that Retry retries them.
+func (e *S3Error) Unwrap() error {
+	if e.Status >= 500 || e.Status == http.StatusTooManyRequests {
+		return syscall.EAGAIN
+	}
+	return nil
+}
+
+// do sends a signed request for key, retrying as s.Retry allows, and
+// returns the response if it succeeded. Any other status is an *S3Error.
+func (s S3) do(op, method, key string, query url.Values, h http.Header, body []byte) (*http.Response, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var resp *http.Response
+	err := s.Retry.do(op, key, func() error {
+		req, err := s.request(method, key, query, h, body)
+		if err != nil {
+			return err
+		}
+		s.sign(req, body, time.Now())
+		if resp, err = client.Do(req); err != nil {
+			return err
+		}
+		if resp.StatusCode/100 == 2 {
+			return nil
+		}
+		return s.fail(op, key, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// request builds an unsigned request for key.
+func (s S3) request(method, key string, query url.Values, h http.Header, body []byte) (*http.Request, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.region() + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	path := "/" + key
+	if s.PathStyle {
+		path = "/" + s.Bucket + path
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawPath = s3Escape(u.Path, true)
+	u.RawQuery = s3Query(query)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range h {
+		req.Header[name] = values
+	}
+	return req, nil
+}
+
+// fail returns the *S3Error of a response that didn't succeed.
+func (s S3) fail(op, key string, resp *http.Response) error {
+	defer resp.Body.Close()
+	e := &S3Error{Op: op, Key: key, Status: resp.StatusCode}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	xml.Unmarshal(b, e)
+	return e
+}
+
+// decode reads the XML result of a successful response into v. Some
+// requests, such as CopyObject, report errors after their 200 status, in
+// the body, which are returned as an *S3Error.
+func (s S3) decode(op, key string, resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var probe struct {
+		XMLName xml.Name
+	}
+	if xml.Unmarshal(b, &probe) == nil && probe.XMLName.Local == "Error" {
+		e := &S3Error{Op: op, Key: key, Status: resp.StatusCode}
+		xml.Unmarshal(b, e)
+		return e
+	}
+	return xml.Unmarshal(b, v)
+}
+
+func (s S3) region() string {
+	if s.Region == "" {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+// sign adds the AWS Signature Version 4 headers to req, whose body is
+// body, as of now. Every header already set is signed.
+func (s S3) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	stamp, day := now.Format("20060102T150405Z"), now.Format("20060102")
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	names := []string{"host"}
+	values := map[string]string{"host": req.URL.Host}
+	for name, v := range req.Header {
+		name = strings.ToLower(name)
+		names = append(names, name)
+		values[name] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	fmt.Fprintf(&canonical, "%s\n%s\n%s\n", req.Method, req.URL.EscapedPath(), req.URL.RawQuery)
+	for _, name := range names {
+		fmt.Fprintf(&canonical, "%s:%s\n", name, values[name])
+	}
+	signed := strings.Join(names, ";")
+	fmt.Fprintf(&canonical, "\n%s\n%s", signed, payload)
+
+	scope := day + "/" + s.region() + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{day, s.region(), "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape percent-encodes s as SigV4 requires: every byte but letters,
+// digits and "-._~", and "/" too unless keepSlash.
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Query encodes q in the canonical form SigV4 signs: sorted by name,
+// each name and value escaped.
+func s3Query(q url.Values) string {
+	names := make([]string, 0, len(q))
+	for name := range q {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		for _, v := range q[name] {
+			pairs = append(pairs, s3Escape(name, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
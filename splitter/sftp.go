@@ -0,0 +1,531 @@
+package splitter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SFTP is the OutputBackend for a server speaking the SSH File Transfer
+// Protocol, version 3, as OpenSSH's sftp-server and most others do. It is
+// a client of the protocol only: the SSH connection is made by DialSFTP
+// with the ssh command, or by the caller for NewSFTP.
+//
+// Writes are sent in 32KB requests, up to 64 of which may be waiting for
+// the server's reply at once, so throughput doesn't suffer much from the
+// connection's latency. An SFTP is safe for concurrent use.
+type SFTP struct {
+	// Mode is the permission of created files and, with its execute bits
+	// added, directories. When zero, files are created with 0666 and
+	// directories with 0777, before the server's umask.
+	Mode os.FileMode
+
+	rw          io.ReadWriteCloser
+	cmd         *exec.Cmd
+	stderr      *limitedBuffer // of cmd, for errors
+	posixRename bool           // the server replaces existing files on rename
+
+	wmu     sync.Mutex // held while a request is written
+	mu      sync.Mutex // guards the fields below
+	next    uint32
+	pending map[uint32]chan []byte
+	err     error // why the connection was lost
+}
+
+// SFTP packet types, and the status codes of version 3.
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpWrite    = 6
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpStat     = 17
+	sftpRename   = 18
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpAttrs    = 105
+	sftpExtended = 200
+
+	sftpOK            = 0
+	sftpNoSuchFile    = 2
+	sftpPermDenied    = 3
+	sftpFailure       = 4
+	sftpOpUnsupported = 8
+
+	// Open flags and the attribute flag for permissions.
+	sftpFlagWrite = 0x02
+	sftpFlagCreat = 0x08
+	sftpFlagTrunc = 0x10
+	sftpAttrPerm  = 0x04
+
+	// sftpChunk is the data sent in one write request, which every server
+	// accepts, and sftpWindow how many of them may await a reply.
+	sftpChunk  = 32 << 10
+	sftpWindow = 64
+
+	// sftpMaxPacket bounds the packets read, guarding against a stream
+	// that isn't SFTP.
+	sftpMaxPacket = 256 << 10
+)
+
+// DialSFTP connects to the SFTP server of dest, "[user@]host", by running
+// "ssh args... -s dest sftp" and speaking the protocol over its standard
+// input and output. args holds any other ssh options, such as "-p" and a
+// port. Authentication is left to ssh, its configuration and agent.
+func DialSFTP(dest string, args ...string) (*SFTP, error) {
+	cmd := exec.Command("ssh", append(args, "-s", dest, "sftp")...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &limitedBuffer{n: 4 << 10}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	s, err := newSFTP(struct {
+		io.Reader
+		io.WriteCloser
+	}{stdout, stdin}, cmd, stderr)
+	if err != nil {
+		stdin.Close()
+		cmd.Wait()
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("sftp %s: %w", dest, err)
+	}
+	return s, nil
+}
+
+// NewSFTP starts an SFTP session over rw, such as the "sftp" subsystem of
+// an SSH session. Close closes rw.
+func NewSFTP(rw io.ReadWriteCloser) (*SFTP, error) {
+	return newSFTP(rw, nil, nil)
+}
+
+func newSFTP(rw io.ReadWriteCloser, cmd *exec.Cmd, stderr *limitedBuffer) (*SFTP, error) {
+	s := &SFTP{rw: rw, cmd: cmd, stderr: stderr, pending: make(map[uint32]chan []byte)}
+	if err := writeSFTPPacket(rw, sftpInit, sftpU32(3)); err != nil {
+		return nil, err
+	}
+	typ, p, err := readSFTPPacket(rw)
+	if err != nil {
+		return nil, fmt.Errorf("no SFTP version from the server: %w", err)
+	}
+	if typ != sftpVersion || len(p) < 4 {
+		return nil, fmt.Errorf("unexpected SFTP packet type %d instead of the server's version", typ)
+	}
+	for p = p[4:]; len(p) > 0; {
+		var name string
+		if name, p = sftpString(p); name == "posix-rename@openssh.com" {
+			s.posixRename = true
+		}
+		_, p = sftpString(p)
+	}
+	go s.readReplies()
+	return s, nil
+}
+
+// Create creates or truncates path and returns a writer for it.
+func (s *SFTP) Create(name string) (io.WriteCloser, error) {
+	name = filepath.ToSlash(name)
+	mode := s.Mode.Perm()
+	if mode == 0 {
+		mode = 0o666
+	}
+	reply, err := s.call(sftpOpen, sftpStr(name), sftpU32(sftpFlagWrite|sftpFlagCreat|sftpFlagTrunc), sftpU32(sftpAttrPerm), sftpU32(uint32(mode)))
+	if err == nil {
+		var handle string
+		if handle, err = sftpHandleReply(reply); err == nil {
+			return &sftpFile{s: s, name: name, handle: handle}, nil
+		}
+	}
+	return nil, &os.PathError{Op: "create", Path: name, Err: err}
+}
+
+// Rename moves oldPath to newPath, replacing any file already there. A
+// server without the posix-rename extension can't replace a file, so the
+// old one is removed first.
+func (s *SFTP) Rename(oldPath, newPath string) error {
+	oldPath, newPath = filepath.ToSlash(oldPath), filepath.ToSlash(newPath)
+	var err error
+	if s.posixRename {
+		err = sftpErr(s.call(sftpExtended, sftpStr("posix-rename@openssh.com"), sftpStr(oldPath), sftpStr(newPath)))
+	} else {
+		if err = sftpErr(s.call(sftpRemove, sftpStr(newPath))); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		if err == nil {
+			err = sftpErr(s.call(sftpRename, sftpStr(oldPath), sftpStr(newPath)))
+		}
+	}
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: err}
+	}
+	return nil
+}
+
+// Remove deletes the file path.
+func (s *SFTP) Remove(name string) error {
+	name = filepath.ToSlash(name)
+	if err := sftpErr(s.call(sftpRemove, sftpStr(name))); err != nil {
+		return &os.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+// MkdirAll creates dir and any of its parents that don't exist yet.
+func (s *SFTP) MkdirAll(dir string) error {
+	dir = filepath.ToSlash(dir)
+	if dir == "" || dir == "." || dir == "/" || s.isDir(dir) {
+		return nil
+	}
+	if err := s.MkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+	mode := s.Mode.Perm() | (s.Mode.Perm()&0o444)>>2
+	if mode == 0 {
+		mode = 0o777
+	}
+	err := sftpErr(s.call(sftpMkdir, sftpStr(dir), sftpU32(sftpAttrPerm), sftpU32(uint32(mode))))
+	// Servers only say that it failed when the directory exists, which
+	// another client may have just created.
+	if err != nil && !s.isDir(dir) {
+		return &os.PathError{Op: "mkdir", Path: dir, Err: err}
+	}
+	return nil
+}
+
+// isDir reports whether dir exists and is a directory.
+func (s *SFTP) isDir(dir string) bool {
+	reply, err := s.call(sftpStat, sftpStr(dir))
+	if err != nil || reply[0] != sftpAttrs || len(reply) < 9 {
+		return false
+	}
+	flags := binary.BigEndian.Uint32(reply[5:])
+	p := reply[9:]
+	if flags&0x01 != 0 { // size
+		p = p[min(8, len(p)):]
+	}
+	if flags&0x02 != 0 { // uid and gid
+		p = p[min(8, len(p)):]
+	}
+	return flags&sftpAttrPerm != 0 && len(p) >= 4 && fs.FileMode(binary.BigEndian.Uint32(p))&0o170000 == 0o040000
+}
+
+// Close ends the session, and the ssh command DialSFTP ran.
+func (s *SFTP) Close() error {
+	err := s.rw.Close()
+	if s.cmd != nil {
+		s.cmd.Wait()
+	}
+	return err
+}
+
+// sftpFile writes a file opened on the server, keeping up to sftpWindow
+// write requests in flight.
+type sftpFile struct {
+	s       *SFTP
+	name    string
+	handle  string
+	off     uint64
+	buf     []byte
+	pending []chan []byte
+	err     error
+	closed  bool
+}
+
+func (f *sftpFile) Write(b []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	n := len(b)
+	for len(b) > 0 {
+		if f.buf == nil {
+			f.buf = make([]byte, 0, sftpChunk)
+		}
+		c := min(len(b), sftpChunk-len(f.buf))
+		f.buf, b = append(f.buf, b[:c]...), b[c:]
+		if len(f.buf) == sftpChunk {
+			if f.err = f.send(); f.err != nil {
+				return n - len(b), f.err
+			}
+		}
+	}
+	return n, nil
+}
+
+// send sends the buffered data, first waiting for the oldest write if the
+// window is full.
+func (f *sftpFile) send() error {
+	if len(f.pending) == sftpWindow {
+		if err := f.wait(1); err != nil {
+			return err
+		}
+	}
+	ch, err := f.s.send(sftpWrite, sftpStr(f.handle), sftpU64(f.off), sftpStr(string(f.buf)))
+	if err != nil {
+		return &os.PathError{Op: "write", Path: f.name, Err: err}
+	}
+	f.pending = append(f.pending, ch)
+	f.off += uint64(len(f.buf))
+	f.buf = f.buf[:0]
+	return nil
+}
+
+// wait waits for the replies to the oldest n writes.
+func (f *sftpFile) wait(n int) error {
+	for _, ch := range f.pending[:n] {
+		if err := sftpErr(f.s.reply(ch)); err != nil {
+			return &os.PathError{Op: "write", Path: f.name, Err: err}
+		}
+	}
+	f.pending = f.pending[n:]
+	return nil
+}
+
+func (f *sftpFile) Close() error {
+	if f.closed {
+		return errors.New("file already closed")
+	}
+	f.closed = true
+	if f.err == nil && len(f.buf) > 0 {
+		f.err = f.send()
+	}
+	if f.err == nil {
+		f.err = f.wait(len(f.pending))
+	}
+	// The handle is closed even after a failed write, so that the server
+	// doesn't keep it open.
+	closeErr := sftpErr(f.s.call(sftpClose, sftpStr(f.handle)))
+	if f.err != nil {
+		return f.err
+	}
+	if closeErr != nil {
+		return &os.PathError{Op: "close", Path: f.name, Err: closeErr}
+	}
+	return nil
+}
+
+// SFTPError is a status other than success from an SFTP server.
+type SFTPError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *SFTPError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("sftp status %d", e.Code)
+}
+
+// Is matches the fs errors that statuses stand for, so that
+// errors.Is(err, fs.ErrNotExist) works as it would locally.
+func (e *SFTPError) Is(target error) bool {
+	switch e.Code {
+	case sftpNoSuchFile:
+		return target == fs.ErrNotExist
+	case sftpPermDenied:
+		return target == fs.ErrPermission
+	case sftpOpUnsupported:
+		return target == errors.ErrUnsupported
+	}
+	return false
+}
+
+// call sends a request and waits for its reply.
+func (s *SFTP) call(typ byte, fields ...[]byte) ([]byte, error) {
+	ch, err := s.send(typ, fields...)
+	if err != nil {
+		return nil, err
+	}
+	return s.reply(ch)
+}
+
+// send sends a request, with a new id before fields, and returns the
+// channel its reply will arrive on.
+func (s *SFTP) send(typ byte, fields ...[]byte) (chan []byte, error) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return nil, s.err
+	}
+	id := s.next
+	s.next++
+	ch := make(chan []byte, 1)
+	s.pending[id] = ch
+	s.mu.Unlock()
+
+	// Writing may block until the server reads, which it may not do until
+	// its replies are read, so replies must keep being handed out.
+	s.wmu.Lock()
+	err := writeSFTPPacket(s.rw, typ, append([][]byte{sftpU32(id)}, fields...)...)
+	s.wmu.Unlock()
+	if err != nil {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+		return nil, err
+	}
+	return ch, nil
+}
+
+// reply waits for a reply on ch and returns it, starting with its type.
+func (s *SFTP) reply(ch chan []byte) ([]byte, error) {
+	if reply, ok := <-ch; ok {
+		return reply, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return nil, s.err
+}
+
+// sftpErr returns the error of a reply that is a status, and an error for
+// any other reply.
+func sftpErr(reply []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	if reply[0] != sftpStatus || len(reply) < 9 {
+		return fmt.Errorf("unexpected SFTP packet type %d instead of a status", reply[0])
+	}
+	code := binary.BigEndian.Uint32(reply[5:])
+	if code == sftpOK {
+		return nil
+	}
+	msg, _ := sftpString(reply[9:])
+	return &SFTPError{Code: code, Message: msg}
+}
+
+// sftpHandleReply returns the handle of a reply to an open.
+func sftpHandleReply(reply []byte) (string, error) {
+	if reply[0] == sftpHandle && len(reply) >= 5 {
+		handle, _ := sftpString(reply[5:])
+		return handle, nil
+	}
+	return "", sftpErr(reply, nil)
+}
+
+// readReplies hands each reply to the request with its id, until the
+// connection fails; then every waiting request fails with it.
+func (s *SFTP) readReplies() {
+	for {
+		typ, p, err := readSFTPPacket(s.rw)
+		if err == nil && len(p) < 4 {
+			err = fmt.Errorf("short SFTP reply of type %d", typ)
+		}
+		s.mu.Lock()
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, os.ErrClosed) {
+				err = errors.New("sftp connection lost")
+				if s.stderr != nil {
+					if msg := strings.TrimSpace(s.stderr.String()); msg != "" {
+						err = fmt.Errorf("%w: %s", err, msg)
+					}
+				}
+			}
+			s.err = err
+			for id, ch := range s.pending {
+				close(ch)
+				delete(s.pending, id)
+			}
+			s.mu.Unlock()
+			return
+		}
+		id := binary.BigEndian.Uint32(p)
+		if ch, ok := s.pending[id]; ok {
+			ch <- append([]byte{typ}, p...)
+			delete(s.pending, id)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func writeSFTPPacket(w io.Writer, typ byte, fields ...[]byte) error {
+	n := 1
+	for _, f := range fields {
+		n += len(f)
+	}
+	p := make([]byte, 0, 4+n)
+	p = binary.BigEndian.AppendUint32(p, uint32(n))
+	p = append(p, typ)
+	for _, f := range fields {
+		p = append(p, f...)
+	}
+	_, err := w.Write(p)
+	return err
+}
+
+// readSFTPPacket reads a packet, returning its type and the rest.
+func readSFTPPacket(r io.Reader) (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n == 0 || n > sftpMaxPacket {
+		return 0, nil, fmt.Errorf("invalid SFTP packet length %d", n)
+	}
+	p := make([]byte, n-1)
+	if _, err := io.ReadFull(r, p); err != nil {
+		return 0, nil, err
+	}
+	return hdr[4], p, nil
+}
+
+func sftpU32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func sftpU64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+
+// sftpStr encodes s as an SFTP string: its length, then its bytes.
+func sftpStr(s string) []byte { return append(sftpU32(uint32(len(s))), s...) }
+
+// sftpString decodes the string at the start of p and returns the rest.
+func sftpString(p []byte) (string, []byte) {
+	if len(p) < 4 {
+		return "", nil
+	}
+	n := binary.BigEndian.Uint32(p)
+	if uint64(n) > uint64(len(p)-4) {
+		return "", nil
+	}
+	return string(p[4 : 4+n]), p[4+n:]
+}
+
+// limitedBuffer keeps the first n bytes written to it and drops the rest.
+type limitedBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+	n  int
+}
+
+func (l *limitedBuffer) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.String()
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if room := l.n - l.b.Len(); room > 0 {
+		l.b.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
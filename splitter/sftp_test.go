@@ -0,0 +1,262 @@
+package splitter
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSFTPServer serves SFTP version 3 from files and directories held in
+// memory.
+type fakeSFTPServer struct {
+	posixRename bool
+	failWrite   string // a file whose writes fail
+
+	mu      sync.Mutex
+	files   map[string][]byte
+	dirs    map[string]bool
+	handles map[string]string
+	opened  int
+	writes  int // write requests waiting for a reply at once, at most
+}
+
+// newFakeSFTP starts a fake server and returns a client connected to it.
+func newFakeSFTP(t *testing.T, srv *fakeSFTPServer) *SFTP {
+	t.Helper()
+	srv.files, srv.dirs, srv.handles = map[string][]byte{}, map[string]bool{}, map[string]string{}
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	go func() {
+		srv.serve(toServer, fromServer)
+		fromServer.Close()
+	}()
+	s, err := NewSFTP(struct {
+		io.Reader
+		io.WriteCloser
+	}{toClient, fromClient})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func (srv *fakeSFTPServer) serve(r io.Reader, w io.Writer) {
+	if typ, _, err := readSFTPPacket(r); err != nil || typ != sftpInit {
+		return
+	}
+	var ext []byte
+	if srv.posixRename {
+		ext = append(sftpStr("posix-rename@openssh.com"), sftpStr("1")...)
+	}
+	writeSFTPPacket(w, sftpVersion, sftpU32(3), ext)
+	type request struct {
+		typ byte
+		p   []byte
+	}
+	requests := make(chan request)
+	go func() {
+		defer close(requests)
+		for {
+			typ, p, err := readSFTPPacket(r)
+			if err != nil {
+				return
+			}
+			requests <- request{typ, p}
+		}
+	}()
+	// Write replies are held back while more requests keep coming, up to
+	// a window's worth, so that a client that doesn't wait for them has
+	// several in flight.
+	var queued [][]byte
+	flush := func() {
+		for _, q := range queued {
+			writeSFTPPacket(w, q[0], q[1:])
+		}
+		queued = queued[:0]
+	}
+	for {
+		var req request
+		var more bool
+		select {
+		case req, more = <-requests:
+		case <-time.After(100 * time.Millisecond):
+			flush()
+			req, more = <-requests
+		}
+		if !more {
+			return
+		}
+		id, reply := req.p[:4], srv.handle(req.typ, req.p[4:])
+		queued = append(queued, append([]byte{reply[0]}, append(id, reply[1:]...)...))
+		if req.typ == sftpWrite {
+			srv.writes = max(srv.writes, len(queued))
+			if len(queued) < sftpWindow {
+				continue
+			}
+		}
+		flush()
+	}
+}
+
+// handle returns the reply type and fields after the id for a request.
+func (srv *fakeSFTPServer) handle(typ byte, p []byte) []byte {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	status := func(code uint32, msg string) []byte {
+		return append(append([]byte{sftpStatus}, sftpU32(code)...), append(sftpStr(msg), sftpStr("")...)...)
+	}
+	ok := status(sftpOK, "")
+	name, rest := sftpString(p)
+	switch typ {
+	case sftpOpen:
+		if !srv.dirs[path.Dir(name)] && path.Dir(name) != "." {
+			return status(sftpNoSuchFile, "No such file")
+		}
+		srv.files[name] = nil
+		srv.opened++
+		h := fmt.Sprint("h", srv.opened)
+		srv.handles[h] = name
+		return append([]byte{sftpHandle}, sftpStr(h)...)
+	case sftpWrite:
+		file := srv.handles[name]
+		if file == srv.failWrite {
+			return status(sftpFailure, "No space left on device")
+		}
+		off := binary.BigEndian.Uint64(rest)
+		data, _ := sftpString(rest[8:])
+		b := srv.files[file]
+		b = append(b, make([]byte, max(0, int(off)+len(data)-len(b)))...)
+		copy(b[off:], data)
+		srv.files[file] = b
+		return ok
+	case sftpClose:
+		delete(srv.handles, name)
+		return ok
+	case sftpRemove:
+		if _, found := srv.files[name]; !found {
+			return status(sftpNoSuchFile, "No such file")
+		}
+		delete(srv.files, name)
+		return ok
+	case sftpRename, sftpExtended:
+		if typ == sftpExtended {
+			name, rest = sftpString(rest)
+		}
+		to, _ := sftpString(rest)
+		if _, found := srv.files[name]; !found {
+			return status(sftpNoSuchFile, "No such file")
+		}
+		if _, found := srv.files[to]; found && typ == sftpRename {
+			return status(sftpFailure, "Failure")
+		}
+		srv.files[to] = srv.files[name]
+		delete(srv.files, name)
+		return ok
+	case sftpMkdir:
+		if srv.dirs[name] {
+			return status(sftpFailure, "Failure")
+		}
+		if !srv.dirs[path.Dir(name)] && path.Dir(name) != "." && path.Dir(name) != "/" {
+			return status(sftpNoSuchFile, "No such file")
+		}
+		srv.dirs[name] = true
+		return ok
+	case sftpStat:
+		switch b, found := srv.files[name]; {
+		case srv.dirs[name]:
+			return append(append([]byte{sftpAttrs}, sftpU32(sftpAttrPerm)...), sftpU32(0o040755)...)
+		case found:
+			attrs := append(sftpU32(0x01|sftpAttrPerm), sftpU64(uint64(len(b)))...)
+			return append(append([]byte{sftpAttrs}, attrs...), sftpU32(0o100644)...)
+		}
+		return status(sftpNoSuchFile, "No such file")
+	}
+	return status(sftpOpUnsupported, "Unsupported")
+}
+
+// splitToBackend splits input into b with opts.
+func splitToBackend(b OutputBackend, input string, opts ...Option) (*Result, error) {
+	return SplitContext(context.Background(), strings.NewReader(input), append(opts, WithOutputBackend(b))...)
+}
+
+// TestSFTPBackend splits onto a fake server, with and without the
+// posix-rename extension, and checks every part arrives whole under its
+// final name.
+func TestSFTPBackend(t *testing.T) {
+	// Over 2MB per part, so that writes fill the window.
+	input := numberedLines(600_000)
+	opts := []Option{WithMaxLines(400_000), WithOutputDir("out/parts")}
+	_, want := splitString(t, input, opts...)
+	for _, posix := range []bool{true, false} {
+		t.Run(fmt.Sprint("posix rename ", posix), func(t *testing.T) {
+			srv := &fakeSFTPServer{posixRename: posix}
+			s := newFakeSFTP(t, srv)
+			if err := s.MkdirAll("out/parts"); err != nil {
+				t.Fatal(err)
+			}
+			// Left over from an earlier run, and replaced.
+			srv.files["out/parts/part001.txt"] = []byte("stale\n")
+			res, err := splitToBackend(s, input, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(srv.files) != len(res.Parts) {
+				t.Errorf("server holds %d files, want %d parts", len(srv.files), len(res.Parts))
+			}
+			for i, p := range res.Parts {
+				if got := string(srv.files[p.Name]); got != want.contents()[i] {
+					t.Errorf("%s holds %d bytes, want %d", p.Name, len(got), len(want.contents()[i]))
+				}
+			}
+			if len(srv.handles) != 0 {
+				t.Errorf("%d handles left open", len(srv.handles))
+			}
+			if srv.writes < sftpWindow {
+				t.Errorf("at most %d writes were in flight, want %d", srv.writes, sftpWindow)
+			}
+		})
+	}
+}
+
+// TestSFTPWriteError checks that a write the server refuses fails the
+// split, and the part is removed rather than left half written.
+func TestSFTPWriteError(t *testing.T) {
+	srv := &fakeSFTPServer{failWrite: "part002.txt.partial"}
+	s := newFakeSFTP(t, srv)
+	_, err := splitToBackend(s, numberedLines(100_000), WithMaxLines(50_000))
+	var outErr *OutputError
+	if !errors.As(err, &outErr) || !strings.Contains(err.Error(), "No space left on device") {
+		t.Fatalf("Split returned %v, want an OutputError with the server's message", err)
+	}
+	if _, found := srv.files["part002.txt.partial"]; found {
+		t.Error("failed part left on the server")
+	}
+	if len(srv.handles) != 0 {
+		t.Errorf("%d handles left open", len(srv.handles))
+	}
+}
+
+// TestSFTPErrors checks that statuses match the fs errors they stand for,
+// and that requests fail once the connection is lost rather than hang.
+func TestSFTPErrors(t *testing.T) {
+	s := newFakeSFTP(t, &fakeSFTPServer{})
+	if _, err := s.Create("missing/part001.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Create in a missing directory returned %v, want fs.ErrNotExist", err)
+	}
+	if err := s.Remove("part001.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Remove of a missing file returned %v, want fs.ErrNotExist", err)
+	}
+	s.Close()
+	if _, err := s.Create("part001.txt"); err == nil {
+		t.Error("Create succeeded after the connection was closed")
+	}
+}
@@ -0,0 +1,29 @@
+package splitter
+
+import (
+	"math/rand/v2"
+)
+
+// splitShuffle writes each line to one of cfg.shuffleParts parts chosen at
+// random, for example to make train/test splits. All parts are opened up
+// front and stay open for the whole split.
+func (rn *run) splitShuffle() (*Result, error) {
+	n := rn.cfg.shuffleParts
+	return rn.splitRouted(n, true, func([]byte) int { return rn.rng.IntN(n) }, nil)
+}
+
+// sampledOut reports whether the line just read is left out by sampling,
+// and accounts it as read if so.
+func (rn *run) sampledOut() bool {
+	if rn.cfg.sampleRate == 0 || rn.rng.Float64() < rn.cfg.sampleRate {
+		return false
+	}
+	rn.res.Lines++
+	rn.res.SampledOut++
+	return true
+}
+
+// newRand returns the random source for shuffling and sampling.
+func newRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, 0))
+}
@@ -0,0 +1,330 @@
+package splitter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"math/rand"
+)
+
+// fingerprint is a 128-bit SimHash.
+type fingerprint struct{ hi, lo uint64 }
+
+// simhash fingerprints line from its whitespace-separated words, so lines
+// sharing most of their words get fingerprints a few bits apart.
+func simhash(line []byte) fingerprint {
+	var weights [128]int
+	h := fnv.New128a()
+	var sum [16]byte
+	for _, word := range bytes.Fields(line) {
+		h.Reset()
+		h.Write(word)
+		s := h.Sum(sum[:0])
+		// FNV barely changes the low bits for words that differ only at
+		// the end, so mix it before using its bits as votes.
+		lo := mix64(binary.LittleEndian.Uint64(s[:8]))
+		hi := mix64(binary.LittleEndian.Uint64(s[8:]) ^ lo)
+		for i := 0; i < 128; i++ {
+			word := lo
+			if i >= 64 {
+				word = hi
+			}
+			if word>>(i%64)&1 == 1 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	var fp fingerprint
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		if i < 64 {
+			fp.lo |= 1 << i
+		} else {
+			fp.hi |= 1 << (i - 64)
+		}
+	}
+	return fp
+}
+
+// mix64 is the splitmix64 finalizer.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+func (f fingerprint) distance(g fingerprint) int {
+	return bits.OnesCount64(f.hi^g.hi) + bits.OnesCount64(f.lo^g.lo)
+}
+
+// bit returns bit i of f.
+func (f fingerprint) bit(i uint8) uint64 {
+	if i < 64 {
+		return f.lo >> i & 1
+	}
+	return f.hi >> (i - 64) & 1
+}
+
+// Near-duplicates are found through tables that each index a fingerprint
+// under some of its bits, so only fingerprints sharing a table key are
+// compared.
+const (
+	// lshTables is how many tables of randomly chosen bits are kept when
+	// the distance allowed is too large for exact bands.
+	lshTables = 8
+	// minKeyBits and maxKeyBits bound the bits of a table key, sized
+	// to the fingerprint limit so that a bucket holds at most about one
+	// fingerprint when the store is full.
+	minKeyBits = 14
+	maxKeyBits = 24
+)
+
+// fuzzyDedupe remembers line fingerprints and spots near-duplicates.
+//
+// Two fingerprints within maxDist bits agree exactly on at least one of
+// maxDist+1 disjoint bands. When those bands are at least as wide as a
+// table key should be, they are the tables, and every near-duplicate is
+// found. Otherwise bands that narrow would put a large share of all
+// fingerprints in every bucket, making each lookup linear in the store.
+// Instead there are lshTables tables keyed by randomly chosen bits, and
+// each is probed under its key and every key one bit away from it. Two
+// fingerprints meet when a table's bits differ by at most one, which is
+// near certain for close ones but not for those near maxDist: at the
+// default similarity and limit, pairs 10 bits apart are caught over 99% of
+// the time, 15 bits apart about 94% and 25 bits apart about 40%. Lookups
+// cost the same however many fingerprints are kept, and candidates are
+// always checked against maxDist.
+//
+// Fingerprints are numbered in the order they are remembered. When
+// evicting, fps is a ring of limit slots and a new fingerprint takes the
+// slot of the oldest; bucket entries of forgotten fingerprints are
+// skipped, and dropped once they outnumber the live ones.
+type fuzzyDedupe struct {
+	maxDist int
+	tables  [][]uint8 // bits of fingerprints each table is keyed by
+	probe   bool      // also look up keys one bit away
+	limit   int       // fingerprints kept; later lines are only checked
+	evict   bool      // at the limit, forget the least recently seen fingerprint instead
+	fps     []fingerprint
+	seqs    []uint64            // number of the fingerprint in each slot of fps, when evicting
+	next    uint64              // number of the next fingerprint
+	stale   int                 // bucket entries of forgotten fingerprints
+	buckets map[uint64][]uint64 // table key to fingerprint numbers
+	// filled marks, with probe, the table bits any fingerprint was
+	// remembered under, so that most probes skip the map. Marks are not
+	// cleared when fingerprints are forgotten.
+	filled [][]uint64
+	vals   []uint64 // scratch for a fingerprint's table bits
+}
+
+func newFuzzyDedupe(similarity float64, limit int) *fuzzyDedupe {
+	d := &fuzzyDedupe{
+		maxDist: int(math.Floor((1 - similarity) * 128)),
+		buckets: make(map[uint64][]uint64),
+	}
+	d.setLimit(limit)
+	return d
+}
+
+// setLimit sets the fingerprint limit, and the tables to suit it.
+func (d *fuzzyDedupe) setLimit(n int) {
+	d.limit = n
+	keyBits := maxKeyBits
+	if n > 0 {
+		keyBits = min(max(bits.Len(uint(n)), minKeyBits), maxKeyBits)
+	}
+	d.tables, d.probe, d.filled = nil, false, nil
+	if bands := d.maxDist + 1; bands*keyBits <= 128 {
+		for i := range bands {
+			var table []uint8
+			for b := i * 128 / bands; b < (i+1)*128/bands; b++ {
+				table = append(table, uint8(b))
+			}
+			d.tables = append(d.tables, table)
+		}
+	} else {
+		// A fixed seed keeps which lines are dropped reproducible.
+		r := rand.New(rand.NewSource(1))
+		for range lshTables {
+			table := make([]uint8, keyBits)
+			for i, b := range r.Perm(128)[:keyBits] {
+				table[i] = uint8(b)
+			}
+			d.tables = append(d.tables, table)
+			d.filled = append(d.filled, make([]uint64, 1<<keyBits/64))
+		}
+		d.probe = true
+	}
+	d.vals = make([]uint64, len(d.tables))
+}
+
+// entries is how many bucket entries each remembered fingerprint takes.
+func (d *fuzzyDedupe) entries() int {
+	return len(d.tables)
+}
+
+// evictAt makes d keep at most n fingerprints, forgetting the least
+// recently seen one to remember a new one.
+func (d *fuzzyDedupe) evictAt(n int) {
+	d.setLimit(n)
+	d.evict = true
+	d.fps = make([]fingerprint, 0, n)
+	d.seqs = make([]uint64, 0, n)
+}
+
+// seen reports whether line is a near-duplicate of an earlier line, and
+// remembers it otherwise while below the limit, or always when evicting.
+func (d *fuzzyDedupe) seen(line []byte) bool {
+	return d.seenFingerprint(simhash(line))
+}
+
+// seenFingerprint is seen for a line whose fingerprint is fp.
+func (d *fuzzyDedupe) seenFingerprint(fp fingerprint) bool {
+	for t := range d.tables {
+		v := d.tableBits(fp, t)
+		d.vals[t] = v
+		if d.near(fp, t, v) {
+			return true
+		}
+		if !d.probe {
+			continue
+		}
+		for i := range d.tables[t] {
+			if d.near(fp, t, v^1<<i) {
+				return true
+			}
+		}
+	}
+	if d.full() && !d.evict {
+		return false
+	}
+	d.remember(fp, d.vals)
+	return false
+}
+
+// near reports whether a fingerprint remembered in table t under bits v is
+// within maxDist bits of fp, keeping it from being evicted soon if so.
+func (d *fuzzyDedupe) near(fp fingerprint, t int, v uint64) bool {
+	if d.filled != nil && d.filled[t][v/64]&(1<<(v%64)) == 0 {
+		return false
+	}
+	for _, seq := range d.buckets[d.key(v, t)] {
+		slot, ok := d.slot(seq)
+		if ok && d.fps[slot].distance(fp) <= d.maxDist {
+			d.touch(seq, slot)
+			return true
+		}
+	}
+	return false
+}
+
+// slot returns where fingerprint seq is kept, and false if it has been
+// forgotten.
+func (d *fuzzyDedupe) slot(seq uint64) (int, bool) {
+	if !d.evict {
+		return int(seq), true
+	}
+	slot := int(seq % uint64(d.limit))
+	return slot, slot < len(d.seqs) && d.seqs[slot] == seq
+}
+
+// remember adds fp, whose bits in each table are vals, taking the oldest
+// slot when evicting at the limit.
+func (d *fuzzyDedupe) remember(fp fingerprint, vals []uint64) {
+	seq := d.next
+	d.next++
+	for t, v := range vals {
+		k := d.key(v, t)
+		d.buckets[k] = append(d.buckets[k], seq)
+		if d.filled != nil {
+			d.filled[t][v/64] |= 1 << (v % 64)
+		}
+	}
+	if !d.evict {
+		d.fps = append(d.fps, fp)
+		return
+	}
+	if slot := int(seq % uint64(d.limit)); slot < len(d.fps) {
+		if d.seqs[slot] != noSeq {
+			d.stale += len(vals)
+		}
+		d.fps[slot], d.seqs[slot] = fp, seq
+	} else {
+		d.fps = append(d.fps, fp)
+		d.seqs = append(d.seqs, seq)
+	}
+	if d.stale > len(d.fps)*d.entries() {
+		d.compact()
+	}
+}
+
+// noSeq marks a slot whose fingerprint was moved to a newer one.
+const noSeq = math.MaxUint64
+
+// touch keeps fingerprint seq, found in slot, from being evicted soon: one
+// in the older half of the ring is remembered again as the newest. Moving
+// only those keeps the ring from filling with the holes they leave.
+func (d *fuzzyDedupe) touch(seq uint64, slot int) {
+	if !d.evict || d.next-seq <= uint64(d.limit)/2 {
+		return
+	}
+	fp := d.fps[slot]
+	d.seqs[slot] = noSeq
+	d.stale += d.entries()
+	vals := make([]uint64, len(d.tables))
+	for t := range vals {
+		vals[t] = d.tableBits(fp, t)
+	}
+	d.remember(fp, vals)
+}
+
+// compact drops the bucket entries of forgotten fingerprints.
+func (d *fuzzyDedupe) compact() {
+	for k, seqs := range d.buckets {
+		live := seqs[:0]
+		for _, seq := range seqs {
+			if _, ok := d.slot(seq); ok {
+				live = append(live, seq)
+			}
+		}
+		if len(live) == 0 {
+			delete(d.buckets, k)
+		} else {
+			d.buckets[k] = live
+		}
+	}
+	d.stale = 0
+}
+
+// full reports whether the fingerprint limit has been reached.
+func (d *fuzzyDedupe) full() bool {
+	return d.limit > 0 && len(d.fps) >= d.limit
+}
+
+// tableBits returns the bits of fp that table t is keyed by.
+func (d *fuzzyDedupe) tableBits(fp fingerprint, t int) uint64 {
+	var v uint64
+	for i, b := range d.tables[t] {
+		if i == 64 {
+			// Only the single band of maxDist 0 is wider; folding its
+			// high half in costs at most an extra comparison.
+			v = mix64(v)
+		}
+		v ^= fp.bit(b) << (i % 64)
+	}
+	return v
+}
+
+// key is the bucket for bits v of table t. Keys of different tables may
+// collide, which only costs an extra comparison.
+func (d *fuzzyDedupe) key(v uint64, t int) uint64 {
+	return v*0x9e3779b97f4a7c15 + uint64(t)
+}
@@ -0,0 +1,186 @@
+package splitter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// flipBits returns fp with n of its bits, chosen by r, flipped.
+func flipBits(r *rand.Rand, fp fingerprint, n int) fingerprint {
+	for _, b := range r.Perm(128)[:n] {
+		if b < 64 {
+			fp.lo ^= 1 << b
+		} else {
+			fp.hi ^= 1 << (b - 64)
+		}
+	}
+	return fp
+}
+
+// dedupeRecall returns the share of fingerprints dist bits from one in a
+// store of n random fingerprints that d finds.
+func dedupeRecall(r *rand.Rand, d *fuzzyDedupe, n, dist, trials int) float64 {
+	for range n {
+		d.seenFingerprint(fingerprint{r.Uint64(), r.Uint64()})
+	}
+	found := 0
+	for range trials {
+		fp := fingerprint{r.Uint64(), r.Uint64()}
+		d.seenFingerprint(fp)
+		if d.seenFingerprint(flipBits(r, fp, dist)) {
+			found++
+		}
+	}
+	return float64(found) / float64(trials)
+}
+
+// TestFuzzyDedupeExactBands checks that a distance allowed small enough
+// for wide exact bands finds every near-duplicate and nothing further.
+func TestFuzzyDedupeExactBands(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, sim := range []float64{1, 0.99, 0.97, 0.96} {
+		d := newFuzzyDedupe(sim, 10_000)
+		if d.probe {
+			t.Fatalf("similarity %g uses probed tables, want exact bands", sim)
+		}
+		for dist := 0; dist <= d.maxDist+3; dist++ {
+			want := 0.0
+			if dist <= d.maxDist {
+				want = 1
+			}
+			if got := dedupeRecall(r, d, 0, dist, 200); got != want {
+				t.Errorf("similarity %g: found %.0f%% of fingerprints %d bits apart, want %.0f%%", sim, got*100, dist, want*100)
+			}
+		}
+	}
+}
+
+// TestFuzzyDedupeProbedTables checks that the default similarity finds
+// close near-duplicates among many fingerprints, and never one further
+// than it allows.
+func TestFuzzyDedupeProbedTables(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	tests := []struct {
+		dist int
+		min  float64
+	}{
+		{0, 1},
+		{5, 0.99},
+		{10, 0.98},
+		{15, 0.85},
+	}
+	for _, tt := range tests {
+		d := newFuzzyDedupe(0.8, 1_000_000)
+		if got := dedupeRecall(r, d, 20_000, tt.dist, 500); got < tt.min {
+			t.Errorf("found %.1f%% of fingerprints %d bits apart, want at least %.0f%%", got*100, tt.dist, tt.min*100)
+		}
+	}
+	d := newFuzzyDedupe(0.8, 1_000_000)
+	for _, dist := range []int{26, 30, 40} {
+		if got := dedupeRecall(r, d, 20_000, dist, 500); got != 0 {
+			t.Errorf("found %.1f%% of fingerprints %d bits apart, over the %d allowed", got*100, dist, d.maxDist)
+		}
+	}
+}
+
+// TestFuzzyDedupeEvicts checks that at its limit an evicting store keeps
+// the most recent fingerprints and forgets the oldest.
+func TestFuzzyDedupeEvicts(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	d := newFuzzyDedupe(0.8, 0)
+	d.evictAt(100)
+	fps := make([]fingerprint, 1000)
+	for i := range fps {
+		fps[i] = fingerprint{r.Uint64(), r.Uint64()}
+		if d.seenFingerprint(fps[i]) {
+			t.Fatalf("random fingerprint %d taken for a near-duplicate", i)
+		}
+	}
+	if len(d.fps) != 100 {
+		t.Errorf("store holds %d fingerprints, want 100", len(d.fps))
+	}
+	for i, fp := range fps[950:] {
+		if !d.seenFingerprint(flipBits(r, fp, 3)) {
+			t.Errorf("recent fingerprint %d forgotten", 950+i)
+		}
+	}
+	if d.seenFingerprint(fps[0]) {
+		t.Error("oldest fingerprint still remembered")
+	}
+}
+
+// TestFuzzyDedupeSplit checks that repeated and slightly changed lines
+// are skipped and counted.
+func TestFuzzyDedupeSplit(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	var in strings.Builder
+	var originals []string
+	for i := 0; i < 200; i++ {
+		words := make([]string, 30)
+		for j := range words {
+			words[j] = fmt.Sprintf("w%d", r.Intn(1_000_000))
+		}
+		originals = append(originals, strings.Join(words, " "))
+		in.WriteString(originals[i] + "\n")
+	}
+	for i, line := range originals {
+		// Each line comes back with one word changed, and once as is.
+		words := strings.Fields(line)
+		words[r.Intn(len(words))] = "changed"
+		in.WriteString(strings.Join(words, " ") + "\n")
+		in.WriteString(originals[i] + "\n")
+	}
+	res, sink := splitString(t, in.String(), WithMaxLines(1000), WithFuzzyDedupe(0.8, 0))
+	// Exact repeats are always caught; a changed line is caught unless its
+	// fingerprint lands further off than the tables reliably find.
+	out := strings.Join(sink.contents(), "")
+	if want := strings.Join(originals, "\n") + "\n"; !strings.HasPrefix(out, want) {
+		t.Errorf("output does not start with the %d originals", len(originals))
+	}
+	if kept := int64(strings.Count(out, "\n")); kept+res.Duplicates != 600 {
+		t.Errorf("kept %d lines and counted %d duplicates of 600", kept, res.Duplicates)
+	}
+	if res.Duplicates < 390 {
+		t.Errorf("counted %d duplicates, want at least 390 of 400", res.Duplicates)
+	}
+}
+
+// BenchmarkFuzzyDedupe splits growing inputs of distinct lines, reporting
+// the time per line. It grows somewhat as the tables outgrow the caches,
+// but not with the fingerprints kept, as comparing against them all did.
+func BenchmarkFuzzyDedupe(b *testing.B) {
+	r := rand.New(rand.NewSource(5))
+	var all bytes.Buffer
+	for i := 0; i < 160_000; i++ {
+		for j := 0; j < 12; j++ {
+			fmt.Fprintf(&all, "w%d ", r.Intn(1_000_000))
+		}
+		all.WriteString("\n")
+	}
+	for _, n := range []int{10_000, 40_000, 160_000} {
+		input := all.Bytes()
+		for i, at := 0, 0; i < n; i++ {
+			at += bytes.IndexByte(input[at:], '\n') + 1
+			if i == n-1 {
+				input = input[:at]
+			}
+		}
+		b.Run(fmt.Sprintf("%d lines", n), func(b *testing.B) {
+			sp, err := New(WithSink(discardSink{}), WithMaxLines(1<<30), WithFuzzyDedupe(0.8, 1_000_000))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				if _, err := sp.Split(context.Background(), bytes.NewReader(input)); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/line")
+		})
+	}
+}
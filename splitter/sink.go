@@ -0,0 +1,221 @@
+package splitter
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// RotateReason says why a new part was started.
+type RotateReason int
+
+const (
+	ReasonStart    RotateReason = iota // the first part of a split
+	ReasonLines                        // the line limit was reached
+	ReasonSize                         // the size limit would have been exceeded
+	ReasonPattern                      // a line matched the split pattern
+	ReasonTime                         // a line's timestamp fell in a new time window
+	ReasonInterval                     // the part had been open for the rotation interval
+	ReasonIdle                         // the part before was closed when the input went idle
+)
+
+func (r RotateReason) String() string {
+	switch r {
+	case ReasonStart:
+		return "start"
+	case ReasonLines:
+		return "lines"
+	case ReasonSize:
+		return "size"
+	case ReasonPattern:
+		return "pattern"
+	case ReasonTime:
+		return "time"
+	case ReasonInterval:
+		return "interval"
+	case ReasonIdle:
+		return "idle"
+	default:
+		return "unknown"
+	}
+}
+
+// PartInfo describes a part about to be written.
+type PartInfo struct {
+	Index     int          // 1-based part index
+	Name      string       // rendered filename, including the output directory
+	Reason    RotateReason // why this part was started
+	StartLine int64        // input line number of the part's first line
+	Append    bool         // the part already exists and is continued, WithAppend
+}
+
+// PartSink opens the destination for each part. The splitter writes a
+// part line by line, so sinks should buffer if their writes are costly.
+// The returned writer is closed when the part is complete.
+type PartSink interface {
+	NewPart(meta PartInfo) (io.WriteCloser, error)
+}
+
+// PartAppender is optionally implemented by a PartSink that can continue
+// a part written by an earlier run, as WithAppend requires.
+type PartAppender interface {
+	// AppendPart opens the existing part for writing after its end.
+	AppendPart(meta PartInfo) (io.WriteCloser, error)
+	// OpenPart opens the existing part for reading, so that its checksum
+	// can cover what it already holds.
+	OpenPart(meta PartInfo) (io.ReadCloser, error)
+}
+
+// PartAborter is optionally implemented by a PartSink that can clean up a
+// part left incomplete by a cancelled split.
+type PartAborter interface {
+	Abort(meta PartInfo) error
+}
+
+// PartMarker is optionally implemented by a PartSink that can set aside a
+// part left incomplete by a cancelled split under a name marking it
+// partial, as WithMarkPartial requires. It returns that name.
+type PartMarker interface {
+	MarkPartial(meta PartInfo) (string, error)
+}
+
+// partialCloser is implemented by part writers that complete the part on
+// Close, such as the atomic rename of an OutputBackend part. ClosePartial
+// closes the writer and leaves the part incomplete.
+type partialCloser interface {
+	ClosePartial() error
+}
+
+// PartRemover is optionally implemented by a PartSink that can delete a
+// completed part, as WithPruneEmpty requires.
+type PartRemover interface {
+	Remove(meta PartInfo) error
+}
+
+// FileSink writes each part to a file named after PartInfo.Name. It is the
+// default sink.
+type FileSink struct {
+	// Mode is the permission of part files; zero means 0666 before umask.
+	Mode os.FileMode
+	// Sync fsyncs each part, and its directory, before Close returns.
+	Sync bool
+	// BufferSize is the size of each part's write buffer; zero means 128KB.
+	BufferSize int
+	// Preallocate reserves this many bytes for each new part, as
+	// LocalFS.Preallocate does.
+	Preallocate int64
+	// Retry retries creating and writing parts that fail transiently.
+	Retry Retry
+}
+
+// NewPart creates the part file and buffers writes to it.
+func (s FileSink) NewPart(meta PartInfo) (io.WriteCloser, error) {
+	w, err := LocalFS{Mode: s.Mode, Sync: s.Sync, BufferSize: s.BufferSize, Preallocate: s.Preallocate, Retry: s.Retry}.Create(meta.Name)
+	if err != nil || !s.Sync {
+		return w, err
+	}
+	// A new file's directory entry is only durable once the directory is
+	// synced too.
+	return &syncDirOnClose{WriteCloser: w, dir: filepath.Dir(meta.Name)}, nil
+}
+
+// AppendPart opens the existing part file for appending and buffers
+// writes to it.
+func (s FileSink) AppendPart(meta PartInfo) (io.WriteCloser, error) {
+	var f *os.File
+	err := s.Retry.do("open", meta.Name, func() (err error) {
+		f, err = os.OpenFile(meta.Name, os.O_WRONLY|os.O_APPEND, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	size := s.BufferSize
+	if size <= 0 {
+		size = bufSize
+	}
+	w := io.WriteCloser(&bufferedFile{Writer: bufio.NewWriterSize(fileWriter(f, s.Retry), size), f: f, sync: s.Sync})
+	if s.Sync {
+		w = &syncDirOnClose{WriteCloser: w, dir: filepath.Dir(meta.Name)}
+	}
+	return w, nil
+}
+
+// OpenPart opens the existing part file for reading.
+func (FileSink) OpenPart(meta PartInfo) (io.ReadCloser, error) {
+	return os.Open(meta.Name)
+}
+
+// Abort removes the part file.
+func (FileSink) Abort(meta PartInfo) error {
+	return LocalFS{}.Remove(meta.Name)
+}
+
+// MarkPartial renames the part file to its name plus ".partial".
+func (FileSink) MarkPartial(meta PartInfo) (string, error) {
+	return meta.Name + partialSuffix, os.Rename(meta.Name, meta.Name+partialSuffix)
+}
+
+// Remove deletes the part file.
+func (FileSink) Remove(meta PartInfo) error {
+	return LocalFS{}.Remove(meta.Name)
+}
+
+// bufferedFile flushes its buffer, and optionally trims preallocated
+// space and fsyncs, before closing the file.
+type bufferedFile struct {
+	*bufio.Writer
+	f    *os.File
+	sync bool
+	trim bool
+}
+
+func (b *bufferedFile) Close() error {
+	if err := b.Flush(); err != nil {
+		// Whatever was written still shouldn't be followed by the
+		// reserved space, though the flush error is the one to report.
+		if b.trim {
+			trimToOffset(b.f)
+		}
+		b.f.Close()
+		return err
+	}
+	if b.trim {
+		if err := trimToOffset(b.f); err != nil {
+			b.f.Close()
+			return err
+		}
+	}
+	if b.sync {
+		if err := b.f.Sync(); err != nil {
+			b.f.Close()
+			return err
+		}
+	}
+	return b.f.Close()
+}
+
+// syncDirOnClose fsyncs dir after closing the part written to it.
+type syncDirOnClose struct {
+	io.WriteCloser
+	dir string
+}
+
+func (s *syncDirOnClose) Close() error {
+	if err := s.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+// syncDir fsyncs the directory dir so entries created or renamed in it
+// survive a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,252 @@
+package splitter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// TestFileSinkPreallocate checks that a preallocated part is trimmed to
+// what was written when it is closed.
+func TestFileSinkPreallocate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "part001.txt")
+	w, err := FileSink{Preallocate: 1 << 20}.NewPart(PartInfo{Name: path, Index: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if fi.Size() != 1<<20 && fi.Size() != 0 {
+		// 0 where the filesystem can't preallocate.
+		t.Errorf("open part is %d bytes, want %d reserved", fi.Size(), 1<<20)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "hello\n" {
+		t.Errorf("closed part holds %q (%v), want %q", b, err, "hello\n")
+	}
+}
+
+// roomyFile writes to f until room bytes have been written, then fails
+// as a full disk would.
+type roomyFile struct {
+	f    *os.File
+	room int
+}
+
+func (r *roomyFile) Write(b []byte) (int, error) {
+	if len(b) > r.room {
+		n, _ := r.f.Write(b[:r.room])
+		r.room = 0
+		return n, &os.PathError{Op: "write", Path: r.f.Name(), Err: syscall.ENOSPC}
+	}
+	r.room -= len(b)
+	return r.f.Write(b)
+}
+
+// TestPreallocatedFlushError checks that a preallocated part whose last
+// flush fails is still trimmed to what was written, and that the flush
+// error is returned.
+func TestPreallocatedFlushError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "part001.txt")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := preallocate(f, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	b := &bufferedFile{Writer: bufio.NewWriter(&roomyFile{f: f, room: 4}), f: f, trim: true}
+	if _, err := b.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("Close returned %v, want the flush's ENOSPC", err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "hell" {
+		t.Errorf("closed part holds %q (%v), want %q", b, err, "hell")
+	}
+}
+
+// TestPreallocatedSplit splits into preallocated files and checks that
+// every part is exactly as long as its data, with no reserved zeros left.
+func TestPreallocatedSplit(t *testing.T) {
+	input := strings.Repeat("0123456789abcdef\n", 10000) // 170000 bytes
+	tests := []struct {
+		name string
+		opts []Option
+		size int64
+	}{
+		{"size", []Option{WithMaxSize(50 << 10)}, 50 << 10},
+		{"size line loop", []Option{WithMaxSize(50 << 10), WithMaxLines(1 << 30)}, 50 << 10},
+		{"size align lines", []Option{WithMaxSize(50 << 10), WithSizeAlignLines()}, 50 << 10},
+		{"bytes", []Option{WithByteChunks(64 << 10)}, 64 << 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := append([]Option{WithOutputDir(dir), WithSink(FileSink{Preallocate: tt.size})}, tt.opts...)
+			sp, err := New(opts...)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			res, err := sp.Split(context.Background(), strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("Split: %v", err)
+			}
+			var joined bytes.Buffer
+			for _, p := range res.Parts {
+				b, err := os.ReadFile(p.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if int64(len(b)) != p.Bytes {
+					t.Errorf("%s is %d bytes, want %d", p.Name, len(b), p.Bytes)
+				}
+				if bytes.HasSuffix(b, []byte{0}) {
+					t.Errorf("%s ends in a zero byte", p.Name)
+				}
+				joined.Write(b)
+			}
+			if joined.String() != input {
+				t.Errorf("parts joined are %d bytes, not the %d-byte input", joined.Len(), len(input))
+			}
+		})
+	}
+}
+
+// fullSink stands in for a filesystem that holds only room bytes across all
+// parts; the write that would pass it stores what fits and fails with
+// ENOSPC. With failClose set, closing part failClose fails instead.
+type fullSink struct {
+	room      int
+	failClose int
+	used      int
+	parts     []*fullPart
+	aborted   []string
+}
+
+type fullPart struct {
+	sink   *fullSink
+	info   PartInfo
+	buf    bytes.Buffer
+	before int // bytes the part held when a write failed, or -1
+}
+
+func (s *fullSink) NewPart(info PartInfo) (io.WriteCloser, error) {
+	p := &fullPart{sink: s, info: info, before: -1}
+	s.parts = append(s.parts, p)
+	return p, nil
+}
+
+func (s *fullSink) Abort(info PartInfo) error {
+	s.aborted = append(s.aborted, info.Name)
+	return nil
+}
+
+func (p *fullPart) Write(b []byte) (int, error) {
+	s := p.sink
+	if s.room > 0 && s.used+len(b) > s.room {
+		n := s.room - s.used
+		p.before = p.buf.Len()
+		p.buf.Write(b[:n])
+		s.used += n
+		return n, &os.PathError{Op: "write", Path: p.info.Name, Err: syscall.ENOSPC}
+	}
+	s.used += len(b)
+	return p.buf.Write(b)
+}
+
+func (p *fullPart) Close() error {
+	if p.info.Index == p.sink.failClose {
+		return &os.PathError{Op: "close", Path: p.info.Name, Err: syscall.EIO}
+	}
+	return nil
+}
+
+// TestWriteErrorStopsSplit checks that a failing part write stops the split
+// with an OutputError naming the part and offset, and that the truncated
+// part is aborted rather than reported.
+func TestWriteErrorStopsSplit(t *testing.T) {
+	input := numberedLines(100) // the first two parts hold 21 and 30 bytes
+	sink := &fullSink{room: 50}
+	sp, err := New(WithSink(sink), WithMaxLines(10))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := sp.Split(context.Background(), strings.NewReader(input))
+	var outErr *OutputError
+	if !errors.As(err, &outErr) {
+		t.Fatalf("Split error = %v, want an *OutputError", err)
+	}
+	if len(sink.parts) != 2 {
+		t.Fatalf("opened %d parts, want the split to stop at part 2", len(sink.parts))
+	}
+	failed := sink.parts[1]
+	if outErr.Part != failed.info.Name || outErr.Offset != int64(failed.before) || failed.before <= 0 {
+		t.Errorf("error names %s at byte %d, want %s at byte %d", outErr.Part, outErr.Offset, failed.info.Name, failed.before)
+	}
+	if !outErr.DiskFull() || !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("error %v is not reported as a full disk", err)
+	}
+	msg := err.Error()
+	for _, want := range []string{fmt.Sprintf("%s at byte %d", failed.info.Name, failed.before), "disk is full"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q does not mention %q", msg, want)
+		}
+	}
+	if len(sink.aborted) != 1 || sink.aborted[0] != failed.info.Name {
+		t.Errorf("aborted %v, want only %s", sink.aborted, failed.info.Name)
+	}
+	if !res.Truncated || len(res.Parts) != 1 || res.Parts[0].Name != sink.parts[0].info.Name {
+		t.Errorf("result has Truncated %v and parts %v, want only the complete first part", res.Truncated, partNames(res))
+	}
+}
+
+// TestCloseErrorStopsSplit checks that a failing Close is reported like a
+// failing write, at the end of the part.
+func TestCloseErrorStopsSplit(t *testing.T) {
+	sink := &fullSink{failClose: 2}
+	sp, err := New(WithSink(sink), WithMaxLines(10))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := sp.Split(context.Background(), strings.NewReader(numberedLines(100)))
+	var outErr *OutputError
+	if !errors.As(err, &outErr) || !errors.Is(err, syscall.EIO) {
+		t.Fatalf("Split error = %v, want an *OutputError for EIO", err)
+	}
+	failed := sink.parts[1]
+	if outErr.Part != failed.info.Name || outErr.Offset != int64(failed.buf.Len()) {
+		t.Errorf("error names %s at byte %d, want %s at byte %d", outErr.Part, outErr.Offset, failed.info.Name, failed.buf.Len())
+	}
+	if outErr.DiskFull() {
+		t.Errorf("EIO reported as a full disk: %v", err)
+	}
+	if len(sink.parts) > 3 {
+		t.Errorf("opened %d parts after the failed close, want the split to stop", len(sink.parts))
+	}
+	if !res.Truncated {
+		t.Error("result not marked Truncated")
+	}
+}
+
+func partNames(res *Result) []string {
+	names := make([]string, len(res.Parts))
+	for i, p := range res.Parts {
+		names[i] = p.Name
+	}
+	return names
+}
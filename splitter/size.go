@@ -0,0 +1,77 @@
+package splitter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sizeRe matches a size such as "512B", "100KB", "1.5GB" or "2GiB". A
+// fractional part is allowed but must have digits on both sides of the dot.
+var (
+	sizeRe        = regexp.MustCompile(`^(\d+(?:\.\d+)?)([KMGT]I?B|B)$`)
+	danglingDotRe = regexp.MustCompile(`^\d+\.([KMGT]I?B|B)$`)
+)
+
+// sizeUnits are the multiples of a byte ParseSize accepts. KB, MB, GB and
+// TB are 1024-based like their IEC spellings KiB, MiB, GiB and TiB.
+var sizeUnits = map[string]float64{
+	"B":  1,
+	"KB": 1 << 10, "KIB": 1 << 10,
+	"MB": 1 << 20, "MIB": 1 << 20,
+	"GB": 1 << 30, "GIB": 1 << 30,
+	"TB": 1 << 40, "TIB": 1 << 40,
+}
+
+// ParseSize parses a size such as "512B", "100KB", "1.5GB" or "2GiB" into
+// bytes. Units are case-insensitive and 1024-based; a fraction of a byte is
+// dropped. Negative sizes and sizes too large for an int64 are errors.
+func ParseSize(s string) (int64, error) {
+	norm := strings.ToUpper(strings.TrimSpace(s))
+	matches := sizeRe.FindStringSubmatch(norm)
+	if matches == nil {
+		switch {
+		case strings.HasPrefix(norm, "-"):
+			return 0, fmt.Errorf("invalid size %q: must not be negative", s)
+		case strings.HasPrefix(norm, "."):
+			return 0, fmt.Errorf("invalid size %q: a leading digit is required (e.g. 0.5MB)", s)
+		case danglingDotRe.MatchString(norm):
+			return 0, fmt.Errorf("invalid size %q: digits are required after the decimal point (e.g. 1.0GB)", s)
+		default:
+			return 0, fmt.Errorf("invalid size %q: expected a number followed by B, KB, MB, GB or TB (or KiB, MiB, GiB, TiB)", s)
+		}
+	}
+	num, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %v", s, err)
+	}
+	n := num * sizeUnits[matches[2]]
+	if n >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return int64(n), nil
+}
+
+// SizeString formats n bytes for people, in the largest unit up to GB that
+// keeps it at least 1: "512 B", "128 KB", "45.67 MB", "1.23 GB". Values
+// that are not a whole number of the unit get two decimals. A value that
+// would round to 1024 of a unit is shown in the next one, as "1.00 GB"
+// rather than "1024.00 MB".
+func SizeString(n int64) string {
+	unit, suffix := int64(1), "B"
+	for _, u := range []struct {
+		size   int64
+		suffix string
+	}{{1 << 30, "GB"}, {1 << 20, "MB"}, {1 << 10, "KB"}} {
+		if n >= u.size || math.Round(float64(n)/float64(u.size>>10)*100) >= 1024*100 {
+			unit, suffix = u.size, u.suffix
+			break
+		}
+	}
+	if n%unit == 0 {
+		return fmt.Sprintf("%d %s", n/unit, suffix)
+	}
+	return fmt.Sprintf("%.2f %s", float64(n)/float64(unit), suffix)
+}
@@ -0,0 +1,89 @@
+package splitter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		err  string // in the error; "" for none
+	}{
+		{"0B", 0, ""},
+		{"1B", 1, ""},
+		{"512B", 512, ""},
+		{"1KB", 1 << 10, ""},
+		{"100KB", 100 << 10, ""},
+		{"1MB", 1 << 20, ""},
+		{"1GB", 1 << 30, ""},
+		{"2TB", 2 << 40, ""},
+		{"1KiB", 1 << 10, ""},
+		{"2GiB", 2 << 30, ""},
+		{"1TiB", 1 << 40, ""},
+		{"10mb", 10 << 20, ""},
+		{"3Gb", 3 << 30, ""},
+		{"1mib", 1 << 20, ""},
+		{"  4KB  ", 4 << 10, ""},
+		{"1.5GB", 3 << 29, ""},
+		{"1.0GB", 1 << 30, ""},
+		{"0.5MB", 1 << 19, ""},
+		{"0.25KB", 256, ""},
+		{"1.7B", 1, ""}, // a fraction of a byte is dropped
+		{"8388607TB", 8388607 << 40, ""},
+		{"8388608TB", 0, "too large"}, // 1<<63
+		{"99999999999999999999B", 0, "too large"},
+		{"1.GB", 0, "digits are required after the decimal point"},
+		{"5.B", 0, "digits are required after the decimal point"},
+		{".5MB", 0, "a leading digit is required"},
+		{"-1MB", 0, "must not be negative"},
+		{"", 0, "expected a number followed by"},
+		{"10", 0, "expected a number followed by"},
+		{"MB", 0, "expected a number followed by"},
+		{"1 MB", 0, "expected a number followed by"},
+		{"1XB", 0, "expected a number followed by"},
+		{"1,5MB", 0, "expected a number followed by"},
+		{"1e3KB", 0, "expected a number followed by"},
+		{"1PB", 0, "expected a number followed by"},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("ParseSize(%q): unexpected error %v", tt.in, err)
+		case tt.err == "" && got != tt.want:
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("ParseSize(%q) = %d, %v; want an error with %q", tt.in, got, err, tt.err)
+		}
+	}
+}
+
+func TestSizeString(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1, "1 B"},
+		{1023, "1023 B"},
+		{1024, "1 KB"},
+		{1536, "1.50 KB"},
+		{128 << 10, "128 KB"},
+		{1<<20 - 1, "1.00 MB"},
+		{1<<20 - 6, "1023.99 KB"},
+		{1 << 20, "1 MB"},
+		{45<<20 + 700<<10, "45.68 MB"},
+		{1<<30 - 1, "1.00 GB"},
+		{1<<30 - 6<<10, "1023.99 MB"},
+		{1 << 30, "1 GB"},
+		{3 << 29, "1.50 GB"},
+		{5 << 40, "5120 GB"},
+	}
+	for _, tt := range tests {
+		if got := SizeString(tt.n); got != tt.want {
+			t.Errorf("SizeString(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
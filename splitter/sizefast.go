@@ -0,0 +1,261 @@
+package splitter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// fastSize reports whether the split is by size alone, which
+// splitSizeFast does without handling each line on its own.
+func (c *config) fastSize() bool {
+	return c.maxSize > 0 && c.maxLines == 0 && c.pattern == nil && c.columns == nil &&
+		c.truncate == 0 && c.softWrap == 0 && !c.rewriteEOL && !c.lineStats && c.warnLineLen == 0 &&
+		c.similarity == 0 && c.sampleRate == 0 && c.timeField == nil && c.rotateEvery == 0 &&
+		c.overlap == 0 && !c.records() && c.maxIdle == 0
+}
+
+// splitSizeFast is the read loop for splits by size alone. It finds the
+// line ends in the read buffer and writes each run of lines bound for the
+// same part at once, keeping the part boundaries of the line loop.
+func (rn *run) splitSizeFast() (*Result, error) {
+	if err := rn.newPart(ReasonStart); err != nil {
+		return rn.fail(err)
+	}
+	for {
+		if rn.ctx.Err() != nil {
+			return rn.abort()
+		}
+		rn.progress.maybeReport(rn.snapshot)
+
+		buf, err := rn.reader.Peek(rn.reader.Size())
+		if err != nil && err != io.EOF {
+			if rn.ctx.Err() != nil {
+				return rn.abort()
+			}
+			return rn.fail(rn.inputErr(fmt.Errorf("read line %d: %w", rn.res.Lines+1, err)))
+		}
+		if len(buf) == 0 {
+			break
+		}
+		start, end := 0, 0 // buf[start:end] holds lines not yet written to the current part
+		for {
+			i := bytes.IndexByte(buf[end:], '\n')
+			if i < 0 {
+				break
+			}
+			n := int64(i + 1)
+			rn.res.BytesRead += n
+			if lerr := rn.checkLineLen(i+1, nil); lerr != nil {
+				if err := rn.write(buf[start:end]); err != nil {
+					return rn.fail(err)
+				}
+				return rn.fail(lerr)
+			}
+			if rn.lineCount > 0 && rn.written+n > rn.maxSize {
+				if err := rn.write(buf[start:end]); err != nil {
+					return rn.fail(err)
+				}
+				if err := rn.newPart(ReasonSize); err != nil {
+					return rn.fail(err)
+				}
+				start = end
+			}
+			end += i + 1
+			rn.fastRecord(n)
+		}
+		if end == 0 {
+			// No line ends in buf: it is the input's final line, or the
+			// start of a line longer than the read buffer.
+			if err := rn.fastLongLine(); err != nil {
+				if rn.ctx.Err() != nil {
+					return rn.abort()
+				}
+				return rn.fail(err)
+			}
+			continue
+		}
+		if err := rn.write(buf[start:end]); err != nil {
+			return rn.fail(err)
+		}
+		rn.reader.Discard(end)
+	}
+	return rn.finish()
+}
+
+// fastLongLine writes a line that fills the read buffer without ending.
+// The line is held until enough of it is read to tell whether it fits in
+// the current part, starting a new part if it doesn't, and the rest is
+// written as it is read. The input's final line, which has no line ending,
+// is handled the same way.
+func (rn *run) fastLongLine() error {
+	var held []byte
+	placed := rn.lineCount == 0
+	var n int64
+	for {
+		piece, err := rn.reader.ReadSlice('\n')
+		n += int64(len(piece))
+		rn.res.BytesRead += int64(len(piece))
+		if lerr := rn.checkLineLen(len(piece), err); lerr != nil {
+			return lerr
+		}
+		more := errors.Is(err, bufio.ErrBufferFull)
+		if !placed {
+			held = append(held, piece...)
+			if more && rn.written+int64(len(held)) <= rn.maxSize {
+				continue
+			}
+			if rn.written+int64(len(held)) > rn.maxSize {
+				if err := rn.newPart(ReasonSize); err != nil {
+					return err
+				}
+			}
+			piece, placed = held, true
+		}
+		if werr := rn.write(piece); werr != nil {
+			return werr
+		}
+		if more {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return rn.inputErr(fmt.Errorf("read line %d: %w", rn.res.Lines+1, err))
+		}
+		break
+	}
+	if n > 0 {
+		rn.fastRecord(n)
+	}
+	return nil
+}
+
+// fastRecord accounts a line of n bytes in the current part, like record
+// without line stats, which the fast path doesn't compute.
+func (rn *run) fastRecord(n int64) {
+	rn.res.Lines++
+	cur := &rn.res.Parts[len(rn.res.Parts)-1]
+	if cur.StartLine == 0 {
+		cur.StartLine = rn.res.Lines
+	}
+	cur.Lines++
+	cur.EndLine = rn.res.Lines
+	cur.InputEnd = rn.res.BytesRead
+	cur.Bytes += n
+	rn.res.BytesWritten += n
+	rn.lineCount++
+	rn.written += n
+}
+
+// errCopyCancelled stops a copy WithSizeAlignLines when the context is
+// done.
+var errCopyCancelled = errors.New("copy cancelled")
+
+// splitSizeCopy is the read loop WithSizeAlignLines. Each part gets its
+// size limit copied from the input with io.CopyN, then the rest of the
+// line the copy ended in.
+func (rn *run) splitSizeCopy() (*Result, error) {
+	w := &copyWriter{rn: rn}
+	reason := ReasonStart
+	for {
+		if _, err := rn.reader.Peek(1); err == io.EOF {
+			break
+		} else if err != nil {
+			return rn.copyFail(w, err)
+		}
+		if err := rn.newPart(reason); err != nil {
+			return rn.fail(err)
+		}
+		reason = ReasonSize
+		w.endsLine = false
+		_, err := io.CopyN(w, rn.reader, max(rn.maxSize-rn.written, 0))
+		for err == nil && !w.endsLine {
+			var piece []byte
+			piece, err = rn.reader.ReadSlice('\n')
+			if _, werr := w.Write(piece); werr != nil {
+				err = werr
+			} else if errors.Is(err, bufio.ErrBufferFull) {
+				err = nil
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rn.copyFail(w, err)
+		}
+	}
+	if w.inLine {
+		// The input's final line has no line ending.
+		rn.copyLines(1)
+	}
+	if reason == ReasonStart && rn.cfg.allowEmpty {
+		if err := rn.newPart(ReasonStart); err != nil {
+			return rn.fail(err)
+		}
+	}
+	return rn.finish()
+}
+
+// copyFail ends a copy that stopped on err, which came from w or from
+// reading the input.
+func (rn *run) copyFail(w *copyWriter, err error) (*Result, error) {
+	switch {
+	case errors.Is(err, errCopyCancelled) || rn.ctx.Err() != nil:
+		return rn.abort()
+	case w.err != nil:
+		return rn.fail(w.err)
+	}
+	return rn.fail(rn.inputErr(err))
+}
+
+// copyWriter writes what splitSizeCopy copies to the current part,
+// counting its lines.
+type copyWriter struct {
+	rn       *run
+	endsLine bool  // the last byte written was a line end
+	inLine   bool  // a line has been started but not ended
+	err      error // from writing the part
+}
+
+func (w *copyWriter) Write(p []byte) (int, error) {
+	rn := w.rn
+	if rn.ctx.Err() != nil {
+		return 0, errCopyCancelled
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := rn.write(p); err != nil {
+		w.err = err
+		return 0, err
+	}
+	n := int64(len(p))
+	rn.res.BytesRead += n
+	rn.res.BytesWritten += n
+	rn.written += n
+	cur := &rn.res.Parts[len(rn.res.Parts)-1]
+	cur.Bytes += n
+	cur.InputEnd = rn.res.BytesRead
+	rn.copyLines(bytes.Count(p, []byte{'\n'}))
+	w.endsLine = p[len(p)-1] == '\n'
+	w.inLine = !w.endsLine
+	rn.progress.maybeReport(rn.snapshot)
+	return len(p), nil
+}
+
+// copyLines accounts n lines ending in the current part.
+func (rn *run) copyLines(n int) {
+	if n == 0 {
+		return
+	}
+	cur := &rn.res.Parts[len(rn.res.Parts)-1]
+	if cur.StartLine == 0 {
+		cur.StartLine = rn.res.Lines + 1
+	}
+	rn.res.Lines += int64(n)
+	cur.Lines += int64(n)
+	cur.EndLine = rn.res.Lines
+	rn.lineCount += n
+}
@@ -0,0 +1,215 @@
+package splitter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// randomLines returns n lines of random lengths up to maxLen, some ending
+// in "\r\n", and no line ending after the last one if unterminated is set.
+func randomLines(r *rand.Rand, n, maxLen int, unterminated bool) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString(strings.Repeat(string(rune('a'+i%26)), r.Intn(maxLen+1)))
+		if r.Intn(8) == 0 {
+			b.WriteByte('\r')
+		}
+		b.WriteByte('\n')
+	}
+	if unterminated {
+		b.WriteString("last")
+	}
+	return b.String()
+}
+
+// partSummary is what a test compares about a part.
+type partSummary struct {
+	content                   string
+	lines, startLine, endLine int64
+}
+
+func summarize(res *Result, sink *memSink) []partSummary {
+	contents := sink.contents()
+	out := make([]partSummary, len(res.Parts))
+	for i, p := range res.Parts {
+		out[i] = partSummary{contents[i], p.Lines, p.StartLine, p.EndLine}
+	}
+	return out
+}
+
+// TestSizeFastMatchesLineLoop checks that the fast path for splits by
+// size alone ends parts where the line loop does.
+func TestSizeFastMatchesLineLoop(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 40; i++ {
+		// Lines up to three times the read buffer exercise fastLongLine.
+		input := randomLines(r, 1+r.Intn(300), []int{10, 200, 3 * minBufSize}[i%3], i%2 == 0)
+		size := int64(1 + r.Intn(4*minBufSize))
+		name := fmt.Sprintf("input %d size %d", i, size)
+		fastRes, fastSink := splitString(t, input, WithMaxSize(size), WithBufferSize(minBufSize))
+		// A line limit that is never reached keeps the line loop.
+		loopRes, loopSink := splitString(t, input, WithMaxSize(size), WithBufferSize(minBufSize), WithMaxLines(1<<30))
+		fast, loop := summarize(fastRes, fastSink), summarize(loopRes, loopSink)
+		if !slices.Equal(fast, loop) {
+			t.Errorf("%s: fast path gives %d parts, line loop %d", name, len(fast), len(loop))
+		}
+		if fastRes.Lines != loopRes.Lines || fastRes.LongestLine != loopRes.LongestLine {
+			t.Errorf("%s: fast path counts %d lines, longest %d; line loop %d, longest %d",
+				name, fastRes.Lines, fastRes.LongestLine, loopRes.Lines, loopRes.LongestLine)
+		}
+	}
+}
+
+// alignedParts is the reference for WithSizeAlignLines: size bytes per
+// part, then up to the next line end.
+func alignedParts(input string, size int) []string {
+	var parts []string
+	for input != "" {
+		n := min(size, len(input))
+		if n > 0 && input[n-1] != '\n' {
+			if i := strings.IndexByte(input[n:], '\n'); i >= 0 {
+				n += i + 1
+			} else {
+				n = len(input)
+			}
+		}
+		parts = append(parts, input[:n])
+		input = input[n:]
+	}
+	return parts
+}
+
+func TestSizeAlignLines(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 40; i++ {
+		input := randomLines(r, 1+r.Intn(300), []int{10, 200, 3 * minBufSize}[i%3], i%2 == 0)
+		size := 1 + r.Intn(4*minBufSize)
+		name := fmt.Sprintf("input %d size %d", i, size)
+		res, sink := splitString(t, input, WithMaxSize(int64(size)), WithSizeAlignLines(), WithBufferSize(minBufSize))
+		want := alignedParts(input, size)
+		if got := sink.contents(); !slices.Equal(got, want) {
+			t.Errorf("%s: got %d parts of %v bytes, want %d of %v", name, len(got), partLens(got), len(want), partLens(want))
+			continue
+		}
+		var line int64
+		for j, p := range res.Parts {
+			lines := int64(strings.Count(want[j], "\n"))
+			if !strings.HasSuffix(want[j], "\n") {
+				lines++
+			}
+			if p.Lines != lines || p.StartLine != line+1 || p.EndLine != line+lines || p.Bytes != int64(len(want[j])) {
+				t.Errorf("%s: part %d stats %+v, want %d lines from %d, %d bytes", name, j+1, p, lines, line+1, len(want[j]))
+			}
+			line += lines
+		}
+		if res.Lines != line || res.BytesRead != int64(len(input)) || res.BytesWritten != int64(len(input)) {
+			t.Errorf("%s: result %d lines, %d read, %d written; want %d lines, %d bytes", name, res.Lines, res.BytesRead, res.BytesWritten, line, len(input))
+		}
+	}
+}
+
+func TestSizeAlignLinesSchedule(t *testing.T) {
+	input := numberedLines(30)
+	_, sink := splitString(t, input, WithSizeSchedule([]int64{5, 20}, false), WithSizeAlignLines())
+	// The schedule repeats: 5 bytes, 20, 5, 20, each run on to a line end.
+	want := []string{"1\n2\n3\n", "4\n5\n6\n7\n8\n9\n10\n11\n12\n", "13\n14\n", "15\n16\n17\n18\n19\n20\n21\n", "22\n23\n", "24\n25\n26\n27\n28\n29\n30\n"}
+	if got := sink.contents(); !slices.Equal(got, want) {
+		t.Errorf("got parts %q, want %q", got, want)
+	}
+}
+
+func TestSizeAlignLinesNeedsSizeAlone(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithSizeAlignLines(), WithMaxLines(3)},
+		{WithSizeAlignLines(), WithMaxSize(10), WithMaxLines(3)},
+		{WithSizeAlignLines(), WithMaxSize(10), WithMaxLineLength(100)},
+		{WithSizeAlignLines(), WithMaxSize(10), WithLineStats()},
+	} {
+		if _, err := New(opts...); err == nil || !strings.Contains(err.Error(), "aligning sizes to lines needs a split by size alone") {
+			t.Errorf("got %v, want the size alone error", err)
+		}
+	}
+}
+
+// discardSink drops every part.
+type discardSink struct{}
+
+type discardPart struct{ io.Writer }
+
+func (discardPart) Close() error { return nil }
+
+func (discardSink) NewPart(PartInfo) (io.WriteCloser, error) { return discardPart{io.Discard}, nil }
+
+// BenchmarkSplitSize compares the three ways of splitting 64 MB of text
+// into 1 MB parts: the line loop, the fast path that finds every line end,
+// and the byte copy of WithSizeAlignLines.
+func BenchmarkSplitSize(b *testing.B) {
+	input := []byte(randomLines(rand.New(rand.NewSource(3)), 800_000, 160, false))
+	modes := []struct {
+		name string
+		opts []Option
+	}{
+		{"line loop", []Option{WithMaxLines(1 << 30)}},
+		{"fast", nil},
+		{"align lines", []Option{WithSizeAlignLines()}},
+	}
+	for _, m := range modes {
+		b.Run(m.name, func(b *testing.B) {
+			sp, err := New(append([]Option{WithSink(discardSink{}), WithMaxSize(1 << 20)}, m.opts...)...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				if _, err := sp.Split(context.Background(), bytes.NewReader(input)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkBufferSize compares read buffer sizes, WithBufferSize, on the
+// same 64 MB of text split into 100,000-line parts by the line loop and
+// into 1 MB parts by the size fast path.
+func BenchmarkBufferSize(b *testing.B) {
+	input := []byte(randomLines(rand.New(rand.NewSource(3)), 800_000, 160, false))
+	modes := []struct {
+		name string
+		opts []Option
+	}{
+		{"lines", []Option{WithMaxLines(100_000)}},
+		{"size", []Option{WithMaxSize(1 << 20)}},
+	}
+	sizes := []struct {
+		name string
+		n    int
+	}{
+		{"16KB", 16 << 10},
+		{"128KB", 128 << 10},
+		{"1MB", 1 << 20},
+		{"4MB", 4 << 20},
+	}
+	for _, m := range modes {
+		for _, s := range sizes {
+			b.Run(m.name+"/"+s.name, func(b *testing.B) {
+				sp, err := New(append([]Option{WithSink(discardSink{}), WithBufferSize(s.n)}, m.opts...)...)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(input)))
+				for i := 0; i < b.N; i++ {
+					if _, err := sp.Split(context.Background(), bytes.NewReader(input)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package splitter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// errReaderClosed stops a split whose SplitReader was closed.
+var errReaderClosed = errors.New("split reader closed")
+
+// SplitReader exposes the parts of a split as a sequence of readers, so
+// callers can stream each part to any destination.
+type SplitReader struct {
+	parts   chan *Part
+	cur     *Part
+	err     error // result of the split, valid once parts is closed
+	cancel  context.CancelFunc
+	closing sync.Once
+}
+
+// NewSplitReader starts splitting r according to opts. Parts are produced
+// on demand as the caller reads them.
+func NewSplitReader(r io.Reader, opts Options) *SplitReader {
+	ctx, cancel := context.WithCancel(context.Background())
+	sr := &SplitReader{parts: make(chan *Part), cancel: cancel}
+
+	s, err := New(append(opts.options(), WithSink(pipeSink{ctx: ctx, opts: opts, parts: sr.parts}))...)
+	if err != nil {
+		sr.err = err
+		close(sr.parts)
+		return sr
+	}
+	go func() {
+		_, err := s.Split(ctx, r)
+		sr.err = err
+		close(sr.parts)
+	}()
+	return sr
+}
+
+// Next returns a reader for the next part. Any unread content of the
+// previous part is discarded. It returns io.EOF when there are no more
+// parts, or the error that stopped the split.
+func (sr *SplitReader) Next() (io.Reader, error) {
+	p, err := sr.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NextPart is like Next but returns the part with its metadata.
+func (sr *SplitReader) NextPart() (*Part, error) {
+	if sr.cur != nil {
+		io.Copy(io.Discard, sr.cur)
+		sr.cur = nil
+	}
+	p, ok := <-sr.parts
+	if !ok {
+		if sr.err != nil {
+			return nil, sr.err
+		}
+		return nil, io.EOF
+	}
+	sr.cur = p
+	return p, nil
+}
+
+// Close stops the split and releases its resources.
+func (sr *SplitReader) Close() error {
+	sr.closing.Do(func() {
+		sr.cancel()
+		if sr.cur != nil {
+			sr.cur.r.CloseWithError(errReaderClosed)
+		}
+		for p := range sr.parts {
+			p.r.CloseWithError(errReaderClosed)
+		}
+	})
+	return nil
+}
+
+// pipeSink hands each part to the SplitReader as the read end of a pipe.
+type pipeSink struct {
+	ctx   context.Context
+	opts  Options
+	parts chan<- *Part
+}
+
+func (s pipeSink) NewPart(info PartInfo) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	select {
+	case s.parts <- newPart(info, s.opts, pr):
+		return pw, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
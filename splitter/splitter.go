@@ -0,0 +1,145 @@
+// Package splitter splits line-oriented input into numbered part files by
+// line count, size or pattern.
+package splitter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+)
+
+const bufSize = 128 * 1024 // 128KB buffer for I/O, unless WithBufferSize
+
+// minBufSize is the smallest buffer WithBufferSize accepts.
+const minBufSize = 4 * 1024
+
+// ctxCheckInterval is how many lines are read between cancellation checks.
+const ctxCheckInterval = 1024
+
+// Result describes a completed or interrupted split.
+type Result struct {
+	Parts          []PartStats
+	BytesRead      int64         // input bytes consumed
+	Lines          int64         // input lines consumed
+	LongestLine    int64         // bytes of the longest input line, including its line ending; 0 in byte chunk mode and WithSizeAlignLines
+	BytesWritten   int64         // bytes written across all parts
+	Duplicates     int64         // near-duplicate lines skipped, only WithFuzzyDedupe
+	LongLines      int64         // lines over the WithLongLineWarning threshold
+	TruncatedLines int64         // lines shortened WithTruncate
+	PrunedParts    int           // empty parts deleted WithPruneEmpty
+	SampledOut     int64         // lines skipped WithSample
+	Seed           uint64        // seed of WithShuffle and WithSample, whether given WithSeed or random
+	InputChecksum  string        // hex SHA-256 of the whole input, only set WithChecksum
+	PeakMemory     uint64        // most memory the process held from the OS during the split, only set WithMaxMemory
+	Elapsed        time.Duration // wall time of the split
+	Truncated      bool          // the split stopped early due to an error or cancellation
+}
+
+// Skipped returns the input lines that were read but not written:
+// near-duplicates and lines sampled out.
+func (r *Result) Skipped() int64 {
+	return r.Duplicates + r.SampledOut
+}
+
+// Throughput returns the input read per second of Elapsed, in bytes and
+// in lines. Both are 0 when no time has elapsed.
+func (r *Result) Throughput() (bytesPerSec, linesPerSec float64) {
+	secs := r.Elapsed.Seconds()
+	if secs <= 0 {
+		return 0, 0
+	}
+	return float64(r.BytesRead) / secs, float64(r.Lines) / secs
+}
+
+// PartStats holds what was written to a single output part.
+type PartStats struct {
+	Name      string
+	Lines     int64
+	Bytes     int64
+	StartLine int64
+	EndLine   int64
+	InputEnd  int64     // bytes of input read up to the end of the part's last line
+	Checksum  string    // hex SHA-256, only set WithChecksum
+	Pruned    bool      // the part was empty and deleted, only set WithPruneEmpty without renumbering
+	Capture   string    // the pattern's named group value the part is named after
+	Closed    time.Time // when the part was closed
+
+	// Line lengths in bytes excluding the line ending, only set WithLineStats.
+	MinLineLen    int
+	MaxLineLen    int
+	MeanLineLen   float64
+	StddevLineLen float64
+}
+
+// Splitter splits input according to its options. It holds no per-run
+// state, so one Splitter may be used for several inputs.
+type Splitter struct {
+	cfg config
+}
+
+// New returns a Splitter configured by opts. Every invalid or conflicting
+// option is reported in the returned error, not just the first.
+func New(opts ...Option) (*Splitter, error) {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &Splitter{cfg: cfg}, nil
+}
+
+// Split reads r line by line and writes it into parts. The returned Result
+// is never nil: when an error stops the split it describes the parts written
+// so far and has Truncated set. If ctx is cancelled the current part is
+// closed and a *CancelError is returned.
+func (s *Splitter) Split(ctx context.Context, r io.Reader) (*Result, error) {
+	rn, err := newRun(ctx, &s.cfg, r)
+	if err != nil {
+		return rn.fail(err)
+	}
+	defer rn.close()
+	return rn.split()
+}
+
+// Count reads r as a dry run of Split would, but quietly: nothing is
+// written, logged or reported to callbacks, and no checksums or line
+// stats are computed. The Result holds the parts Split would create from
+// the same input, such as the total for WithReverseIndex.
+func (s *Splitter) Count(ctx context.Context, r io.Reader) (*Result, error) {
+	cfg := s.cfg
+	cfg.dryRun, cfg.checksum, cfg.lineStats = true, false, false
+	cfg.logger, cfg.onProgress, cfg.onPartDone, cfg.trailer = nopLogger{}, nil, nil, nil
+	cfg.rateLimit, cfg.expectParts, cfg.reverseTotal = 0, 0, 0
+	// The caller may reread r afterwards, so nothing may still be reading it.
+	cfg.pipelineDepth = 0
+	rn, err := newRun(ctx, &cfg, r)
+	if err != nil {
+		return rn.fail(err)
+	}
+	defer rn.close()
+	return rn.split()
+}
+
+// Split splits r with a Splitter configured by opts. Like Splitter.Split,
+// it always returns a non-nil Result.
+func Split(r io.Reader, opts ...Option) (*Result, error) {
+	return SplitContext(context.Background(), r, opts...)
+}
+
+// SplitContext is Split with a context for cancellation.
+func SplitContext(ctx context.Context, r io.Reader, opts ...Option) (*Result, error) {
+	s, err := New(opts...)
+	if err != nil {
+		return &Result{}, err
+	}
+	return s.Split(ctx, r)
+}
+
+// trimEOL returns line without its trailing "\n" or "\r\n".
+func trimEOL(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r"))
+}
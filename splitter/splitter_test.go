@@ -0,0 +1,144 @@
+package splitter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memSink keeps every part in memory, in the order the parts were opened.
+type memSink struct {
+	mu    sync.Mutex
+	parts []*memPart
+}
+
+type memPart struct {
+	info   PartInfo
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (p *memPart) Write(b []byte) (int, error) { return p.buf.Write(b) }
+func (p *memPart) Close() error                { p.closed = true; return nil }
+
+func (s *memSink) NewPart(info PartInfo) (io.WriteCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := &memPart{info: info}
+	s.parts = append(s.parts, p)
+	return p, nil
+}
+
+// contents returns what each part holds.
+func (s *memSink) contents() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, len(s.parts))
+	for i, p := range s.parts {
+		out[i] = p.buf.String()
+	}
+	return out
+}
+
+// logEvent is one call to a captureLogger.
+type logEvent struct {
+	level string
+	msg   string
+	args  []any
+}
+
+// attr returns the value of the key/value pair named key, or nil.
+func (e logEvent) attr(key string) any {
+	for i := 0; i+1 < len(e.args); i += 2 {
+		if e.args[i] == key {
+			return e.args[i+1]
+		}
+	}
+	return nil
+}
+
+// captureLogger records every event it is given.
+type captureLogger struct {
+	mu     sync.Mutex
+	events []logEvent
+}
+
+func (l *captureLogger) add(level, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, logEvent{level: level, msg: msg, args: args})
+}
+
+func (l *captureLogger) Info(msg string, args ...any)  { l.add("info", msg, args) }
+func (l *captureLogger) Warn(msg string, args ...any)  { l.add("warn", msg, args) }
+func (l *captureLogger) Error(msg string, args ...any) { l.add("error", msg, args) }
+
+// matching returns the events whose message starts with prefix.
+func (l *captureLogger) matching(prefix string) []logEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []logEvent
+	for _, e := range l.events {
+		if strings.HasPrefix(e.msg, prefix) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// numberedLines returns n lines "1\n" through "n\n".
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
+
+// splitString splits input into a memSink with opts and fails the test on
+// any error.
+func splitString(t *testing.T, input string, opts ...Option) (*Result, *memSink) {
+	t.Helper()
+	sink := &memSink{}
+	sp, err := New(append([]Option{WithSink(sink)}, opts...)...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := sp.Split(context.Background(), strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	return res, sink
+}
+
+func TestDryRunSimulatesEveryPart(t *testing.T) {
+	log := &captureLogger{}
+	res, sink := splitString(t, numberedLines(10), WithMaxLines(3), WithDryRun(), WithLogger(log))
+
+	if len(sink.parts) != 0 {
+		t.Errorf("dry run opened %d parts", len(sink.parts))
+	}
+	events := log.matching("[DryRun] Would create")
+	want := []struct {
+		index int
+		name  string
+		lines int64
+	}{{1, "part001.txt", 3}, {2, "part002.txt", 3}, {3, "part003.txt", 3}, {4, "part004.txt", 1}}
+	if len(events) != len(want) {
+		t.Fatalf("got %d [DryRun] Would create events, want %d: %v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.attr("part") != w.index || e.attr("file") != w.name || e.attr("lines") != w.lines {
+			t.Errorf("event %d: part %v, file %v, lines %v; want part %d, file %s, lines %d",
+				i, e.attr("part"), e.attr("file"), e.attr("lines"), w.index, w.name, w.lines)
+		}
+	}
+	if len(res.Parts) != len(want) || res.Lines != 10 {
+		t.Errorf("result has %d parts and %d lines, want %d and 10", len(res.Parts), res.Lines, len(want))
+	}
+}
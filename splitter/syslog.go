@@ -0,0 +1,52 @@
+package splitter
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// SyslogLevels are the parts of a syslog split, by index: the severities
+// a syslog priority maps to, then lines without a priority.
+var SyslogLevels = []string{"critical", "error", "warn", "info", "debug", "unparsed"}
+
+// syslogUnparsed is the slot of lines without a syslog priority.
+const syslogUnparsed = 5
+
+// syslogSlot maps the <PRI> prefix of an RFC 3164 or RFC 5424 line to its
+// slot in SyslogLevels. The severity is PRI mod 8: emergency, alert and
+// critical (0-2) are critical, notice and informational (5-6) are info.
+func syslogSlot(line []byte) int {
+	if len(line) < 3 || line[0] != '<' {
+		return syslogUnparsed
+	}
+	pri, i := 0, 1
+	for ; i < len(line) && i <= 4 && line[i] >= '0' && line[i] <= '9'; i++ {
+		pri = pri*10 + int(line[i]-'0')
+	}
+	if i == 1 || i > 4 || i >= len(line) || line[i] != '>' || pri > 191 {
+		return syslogUnparsed
+	}
+	switch sev := pri % 8; {
+	case sev <= 2:
+		return 0
+	case sev == 3:
+		return 1
+	case sev == 4:
+		return 2
+	case sev <= 6:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// splitSyslog routes each line to a part per severity, named
+// <prefix>_<level>.<ext>. Parts are created on their first line and stay
+// open for the whole split.
+func (rn *run) splitSyslog() (*Result, error) {
+	cfg := rn.cfg
+	name := func(slot int) string {
+		return filepath.Join(cfg.outputDir, fmt.Sprintf("%s_%s.%s", cfg.prefix, SyslogLevels[slot], cfg.ext))
+	}
+	return rn.splitRouted(len(SyslogLevels), false, syslogSlot, name)
+}
@@ -0,0 +1,37 @@
+package splitter
+
+import "time"
+
+// untimedWindow names the part of lines before the first timestamp of a
+// time window split.
+const untimedWindow = "untimed"
+
+// lineWindow returns the name of the time window line falls in: the start
+// of the window its timestamp is in, or the current window when it has no
+// timestamp.
+func (rn *run) lineWindow(line []byte) string {
+	if m := rn.cfg.timeField.FindSubmatch(trimEOL(line)); m != nil {
+		if t, ok := parseTimestamp(m[1]); ok {
+			return formatWindow(t.UTC().Truncate(rn.cfg.timeWindow), rn.cfg.timeWindow)
+		}
+	}
+	if rn.window != "" {
+		return rn.window
+	}
+	return untimedWindow
+}
+
+// formatWindow renders a window start to the precision of the window,
+// without colons so that it is safe in filenames everywhere.
+func formatWindow(t time.Time, window time.Duration) string {
+	switch {
+	case window%(24*time.Hour) == 0:
+		return t.Format("2006-01-02")
+	case window%time.Hour == 0:
+		return t.Format("2006-01-02T15")
+	case window%time.Minute == 0:
+		return t.Format("2006-01-02T1504")
+	default:
+		return t.Format("2006-01-02T150405")
+	}
+}
@@ -0,0 +1,97 @@
+package splitter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// maxStderrTail bounds how much of a transform command's stderr is kept
+// for error messages.
+const maxStderrTail = 4096
+
+// transformReader streams the input through a single long-lived command
+// and yields the command's stdout.
+type transformReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr *tailBuffer
+	once   sync.Once
+	err    error
+}
+
+func startTransform(ctx context.Context, r io.Reader, argv []string) (*transformReader, error) {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = r
+	stderr := &tailBuffer{max: maxStderrTail}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start transform %q: %w", strings.Join(argv, " "), err)
+	}
+	return &transformReader{cmd: cmd, stdout: stdout, stderr: stderr}, nil
+}
+
+// Read returns the command's output. Once the output ends, it reports an
+// error if the command did not exit successfully.
+func (t *transformReader) Read(p []byte) (int, error) {
+	n, err := t.stdout.Read(p)
+	if err == io.EOF {
+		if werr := t.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Close stops the command if it is still running.
+func (t *transformReader) Close() error {
+	t.once.Do(func() {
+		t.cmd.Process.Kill()
+		t.cmd.Wait()
+	})
+	return nil
+}
+
+func (t *transformReader) wait() error {
+	t.once.Do(func() {
+		if err := t.cmd.Wait(); err != nil {
+			msg := strings.TrimSpace(t.stderr.String())
+			if msg != "" {
+				err = fmt.Errorf("%w: %s", err, msg)
+			}
+			t.err = fmt.Errorf("transform command failed: %w", err)
+		}
+	})
+	return t.err
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Write(p)
+	if over := b.buf.Len() - b.max; over > 0 {
+		b.buf.Next(over)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,172 @@
+package splitter
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// column is one open part of a vertical split. In a dry run out and
+// writer are nil.
+type column struct {
+	out    io.WriteCloser
+	info   PartInfo
+	writer io.Writer
+	hasher hash.Hash
+	stats  int // index into Result.Parts
+}
+
+// splitVertical writes every row to one part per column group, each
+// projected to that group's columns.
+func (rn *run) splitVertical() (*Result, error) {
+	groups := rn.cfg.vertical
+	cols := make([]column, 0, len(groups))
+	for range groups {
+		if err := rn.newPart(ReasonStart); err != nil {
+			rn.closeColumns(cols)
+			return rn.fail(err)
+		}
+		cols = append(cols, column{out: rn.out, info: rn.outInfo, writer: rn.writer, hasher: rn.hasher, stats: len(rn.res.Parts) - 1})
+		rn.out, rn.dryOpen = nil, false
+	}
+
+	var pending []byte
+	for iter := 0; ; iter++ {
+		if iter%ctxCheckInterval == 0 {
+			if rn.ctx.Err() != nil {
+				return rn.abortColumns(cols)
+			}
+			rn.progress.maybeReport(rn.snapshot)
+		}
+
+		line, err := rn.reader.ReadSlice('\n')
+		rn.res.BytesRead += int64(len(line))
+		if lerr := rn.checkLineLen(len(line), err); lerr != nil {
+			rn.closeColumns(cols)
+			return rn.fail(lerr)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			pending = append(pending, line...)
+			continue
+		}
+		if pending != nil {
+			line = append(pending, line...)
+			pending = nil
+		}
+		if err != nil && err != io.EOF {
+			if rn.ctx.Err() != nil {
+				return rn.abortColumns(cols)
+			}
+			rn.closeColumns(cols)
+			return rn.fail(rn.inputErr(fmt.Errorf("read line %d: %w", rn.res.Lines+1, err)))
+		}
+		if len(line) == 0 {
+			break
+		}
+
+		body := trimEOL(line)
+		fields, ferr := groups[0].fields(body)
+		if ferr != nil {
+			rn.closeColumns(cols)
+			return rn.fail(rn.inputErr(fmt.Errorf("parse line %d: %w", rn.res.Lines+1, ferr)))
+		}
+		if rn.cfg.header && rn.res.Lines == 0 {
+			for _, g := range groups {
+				if n := g.maxColumn(); n > len(fields) {
+					rn.closeColumns(cols)
+					return rn.fail(rn.inputErr(fmt.Errorf("column %d is past the header's %d columns", n, len(fields))))
+				}
+			}
+		}
+		rn.res.Lines++
+		for i := range cols {
+			if err := rn.writeColumn(&cols[i], rn.endLine(append(groups[i].project(fields), line[len(body):]...))); err != nil {
+				rn.closeColumns(cols)
+				return rn.fail(err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if err := rn.closeColumns(cols); err != nil {
+		return rn.fail(err)
+	}
+	rn.columnsDone(cols)
+	return rn.finish()
+}
+
+// writeColumn writes one projected row to c and accounts it.
+func (rn *run) writeColumn(c *column, row []byte) error {
+	cur := &rn.res.Parts[c.stats]
+	if c.writer != nil {
+		if _, err := c.writer.Write(row); err != nil {
+			return &OutputError{Part: c.info.Name, Offset: cur.Bytes, Err: err}
+		}
+	}
+	if cur.Lines == 0 {
+		cur.StartLine = rn.res.Lines
+	}
+	cur.Lines++
+	cur.EndLine = rn.res.Lines
+	cur.InputEnd = rn.res.BytesRead
+	cur.Bytes += int64(len(row))
+	rn.res.BytesWritten += int64(len(row))
+	return nil
+}
+
+// columnsDone reports every part of a completed multi-part split
+// WithPartDone.
+func (rn *run) columnsDone(cols []column) {
+	for _, c := range cols {
+		if c.out != nil {
+			rn.partDone(c.info, rn.res.Parts[c.stats])
+		}
+	}
+}
+
+// closeColumns closes every open part and returns the first error.
+func (rn *run) closeColumns(cols []column) error {
+	var first error
+	for _, c := range cols {
+		if c.out == nil {
+			rn.logDryPart(c.info, c.stats)
+			continue
+		}
+		err := c.out.Close()
+		rn.res.Parts[c.stats].Closed = time.Now()
+		if c.hasher != nil {
+			rn.res.Parts[c.stats].Checksum = hex.EncodeToString(c.hasher.Sum(nil))
+		}
+		if err != nil && first == nil {
+			first = &OutputError{Part: c.info.Name, Offset: rn.res.Parts[c.stats].Bytes, Err: err}
+		}
+	}
+	return first
+}
+
+// abortColumns is abort for a vertical split: every part is incomplete.
+func (rn *run) abortColumns(cols []column) (*Result, error) {
+	rn.failed = true
+	left := make([]bool, len(cols))
+	for i := range cols {
+		if cols[i].out != nil {
+			cols[i].out, left[i] = leavePartial(cols[i].out)
+		}
+	}
+	rn.closeColumns(cols)
+	var partial []string
+	for i, c := range cols {
+		if c.out != nil {
+			partial = rn.abandon(c.info, left[i], partial)
+		}
+	}
+	rn.res.Truncated = true
+	rn.res.Elapsed = time.Since(rn.start)
+	return rn.res, &CancelError{BytesRead: rn.res.BytesRead, Lines: rn.res.Lines, Partial: partial, Err: rn.ctx.Err()}
+}
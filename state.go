@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// splitState is what -incremental remembers between runs.
+type splitState struct {
+	Offset   int64     `json:"offset"`    // input bytes already split
+	LastPart int       `json:"last_part"` // index of the last part written
+	Inode    uint64    `json:"inode"`     // input file identity, 0 when unknown
+	Size     int64     `json:"size"`      // input size at the last run
+	Updated  time.Time `json:"updated"`
+}
+
+// loadState reads the state file at path. A missing file is a first run.
+func loadState(path string) (splitState, error) {
+	var st splitState
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	return st, json.Unmarshal(b, &st)
+}
+
+// saveState replaces the state file at path, writing it under a temporary
+// name first so a crash never leaves it half-written.
+func saveState(path string, st splitState) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// lastLineEnd returns the offset just past the last newline in f between
+// from and to, or from if there is none. Content after it is an unfinished
+// line that a later run will pick up.
+func lastLineEnd(f io.ReaderAt, from, to int64) (int64, error) {
+	buf := make([]byte, 64*1024)
+	for end := to; end > from; {
+		start := max(from, end-int64(len(buf)))
+		n, err := f.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+	return from, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// sumsEscaper escapes a filename the way GNU sha256sum does for names
+// holding a backslash, newline or carriage return; such lines start with
+// a backslash.
+var sumsEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// writeSumsFile writes the checksum of every part to path in the format
+// of GNU sha256sum: the hex SHA-256, two spaces and the part's path
+// relative to the directory of path, so that sha256sum -c run there
+// checks the parts. Pruned parts are left out.
+func writeSumsFile(path string, parts []splitter.PartStats) error {
+	base, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, p := range parts {
+		if p.Pruned {
+			continue
+		}
+		name := p.Name
+		if abs, err := filepath.Abs(name); err == nil {
+			if rel, err := filepath.Rel(base, abs); err == nil {
+				name = rel
+			}
+		}
+		name = filepath.ToSlash(name)
+		if esc := sumsEscaper.Replace(name); esc != name {
+			fmt.Fprintf(w, "\\%s  %s\n", p.Checksum, esc)
+			continue
+		}
+		fmt.Fprintf(w, "%s  %s\n", p.Checksum, name)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readSumsFile parses a file in the format of sha256sum, as written by
+// writeSumsFile. Both the text ("  ") and binary (" *") separators are
+// accepted. Relative paths are resolved against the file's directory.
+// The rows carry no size, which the checksum covers.
+func readSumsFile(path string) ([]reportRow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dir := filepath.Dir(path)
+	var rows []reportRow
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		row, err := parseSumsLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if !filepath.IsAbs(row.name) {
+			row.name = filepath.Join(dir, row.name)
+		}
+		rows = append(rows, row)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no checksum lines found", path)
+	}
+	return rows, nil
+}
+
+// parseSumsLine parses one "<sha256>  <name>" line.
+func parseSumsLine(line string) (reportRow, error) {
+	escaped := strings.HasPrefix(line, `\`)
+	if escaped {
+		line = line[1:]
+	}
+	const hexLen = 64
+	if len(line) < hexLen+3 || (line[hexLen:hexLen+2] != "  " && line[hexLen:hexLen+2] != " *") {
+		return reportRow{}, errors.New("not a SHA-256 checksum line")
+	}
+	sum := strings.ToLower(line[:hexLen])
+	if _, err := hex.DecodeString(sum); err != nil {
+		return reportRow{}, fmt.Errorf("invalid checksum %q", line[:hexLen])
+	}
+	name := line[hexLen+2:]
+	if escaped {
+		var err error
+		if name, err = unescapeSumsName(name); err != nil {
+			return reportRow{}, err
+		}
+	}
+	return reportRow{name: name, bytes: -1, checksum: sum}, nil
+}
+
+// unescapeSumsName undoes sumsEscaper.
+func unescapeSumsName(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", errors.New("filename ends in a lone backslash")
+		}
+		switch s[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			return "", fmt.Errorf(`invalid escape \%c in filename`, s[i])
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestThreadsSetsGOMAXPROCS runs a split in-process and checks that
+// -threads leaves GOMAXPROCS at its value, and that no -threads leaves it
+// alone.
+func TestThreadsSetsGOMAXPROCS(t *testing.T) {
+	saved := *con
+	before := runtime.GOMAXPROCS(0)
+	t.Cleanup(func() {
+		*con = saved
+		runtime.GOMAXPROCS(before)
+	})
+	dir := t.TempDir()
+	input := writeInput(t, dir, 10)
+	for _, threads := range []int{0, 3, 1} {
+		args := []string{"-in", input, "-lines", "5", "-outdir", filepath.Join(dir, "out"), "-q", "-yes"}
+		want := runtime.GOMAXPROCS(0)
+		if threads > 0 {
+			args = append(args, "-threads", strconv.Itoa(threads))
+			want = threads
+		}
+		if err := runSplit(args, false); err != nil {
+			t.Fatalf("split %v: %v", args, err)
+		}
+		if got := runtime.GOMAXPROCS(-1); got != want {
+			t.Errorf("-threads %d: GOMAXPROCS is %d, want %d", threads, got, want)
+		}
+	}
+}
+
+// TestThreadsLogged checks that the effective GOMAXPROCS is logged.
+func TestThreadsLogged(t *testing.T) {
+	dir := t.TempDir()
+	input := writeInput(t, dir, 10)
+	res := runCLI(t, dir, nil, "split", "-in", input, "-lines", "5", "-outdir", filepath.Join(dir, "out"), "-threads", "3", "-log-format", "json")
+	if res.code != exitOK {
+		t.Fatalf("exit code %d, want %d\nstderr: %s", res.code, exitOK, res.stderr)
+	}
+	first, _, _ := strings.Cut(res.stdout, "\n")
+	var ev struct {
+		Msg        string `json:"msg"`
+		GOMAXPROCS int    `json:"gomaxprocs"`
+	}
+	if err := json.Unmarshal([]byte(first), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Msg != "threads" || ev.GOMAXPROCS != 3 {
+		t.Errorf("first event %s, want threads with gomaxprocs 3", first)
+	}
+
+	res = runCLI(t, dir, nil, "split", "-in", input, "-lines", "5", "-outdir", filepath.Join(dir, "plain"), "-threads", "2", "-plain")
+	if !strings.Contains(res.stdout, "Using 2 threads") {
+		t.Errorf("no thread count in:\n%s", res.stdout)
+	}
+}
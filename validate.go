@@ -0,0 +1,390 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// flagConflicts lists flags that can't be used together. New flags that
+// rule each other out belong here.
+var flagConflicts = []struct{ a, b, why string }{
+	{"bytes", "lines", "byte chunks ignore line boundaries"},
+	{"bytes", "size", "byte chunks already have an exact size"},
+	{"bytes", "pattern", "byte chunks ignore line boundaries"},
+	{"bytes", "select-columns", "byte chunks don't see columns"},
+	{"bytes", "line-stats", "byte chunks don't see lines"},
+	{"bytes", "strip-newline", "byte chunks don't see lines"},
+	{"bytes", "output-record-sep", "byte chunks don't see lines"},
+	{"bytes", "fuzzy-dedupe", "byte chunks don't see lines"},
+	{"bytes", "max-line", "byte chunks don't see lines"},
+	{"bytes", "max-line-length", "byte chunks don't see lines"},
+	{"bytes", "truncate", "byte chunks don't see lines"},
+	{"vertical", "truncate", "truncation is only done for row splits"},
+	{"bytes", "softwrap", "byte chunks don't see lines"},
+	{"vertical", "softwrap", "wrapping is only done for row splits"},
+	{"vertical", "lines", "a vertical split writes one part per column group"},
+	{"vertical", "size", "a vertical split writes one part per column group"},
+	{"vertical", "pattern", "a vertical split writes one part per column group"},
+	{"vertical", "bytes", "a vertical split writes one part per column group"},
+	{"vertical", "select-columns", "use -columns to choose the columns of each part"},
+	{"vertical", "line-stats", "line stats are per row-split part"},
+	{"vertical", "fuzzy-dedupe", "dedupe is only done for row splits"},
+	{"vertical", "prune-empty", "every column group part gets every row"},
+	{"allow-empty", "prune-empty", "the empty part would be pruned"},
+	{"shuffle", "lines", "-shuffle sets the number of parts"},
+	{"shuffle", "size", "-shuffle sets the number of parts"},
+	{"shuffle", "pattern", "-shuffle sets the number of parts"},
+	{"shuffle", "bytes", "-shuffle sets the number of parts"},
+	{"shuffle", "vertical", "-shuffle sets the number of parts"},
+	{"shuffle", "line-stats", "line stats are per sequential part"},
+	{"shuffle", "prune-empty", "shuffled parts stay open until the end"},
+	{"shuffle", "max-line-length", "long lines are reported per sequential part"},
+	{"syslog-split", "lines", "parts are chosen by severity"},
+	{"syslog-split", "size", "parts are chosen by severity"},
+	{"syslog-split", "pattern", "parts are chosen by severity"},
+	{"syslog-split", "bytes", "parts are chosen by severity"},
+	{"syslog-split", "vertical", "parts are chosen by severity"},
+	{"syslog-split", "shuffle", "parts are chosen by severity"},
+	{"syslog-split", "line-stats", "line stats are per sequential part"},
+	{"syslog-split", "prune-empty", "severity parts are only created for their first line"},
+	{"syslog-split", "max-line-length", "long lines are reported per sequential part"},
+	{"syslog-split", "ts", "severity parts are named by level"},
+	{"syslog-split", "ts-format", "severity parts are named by level"},
+	{"syslog-split", "ts-tz", "severity parts are named by level"},
+	{"size-schedule", "size", "-size-schedule sets the size of every part"},
+	{"size-align-lines", "lines", "it only copies whole sizes"},
+	{"size-align-lines", "pattern", "it only copies whole sizes"},
+	{"size-align-lines", "max-line-length", "it doesn't measure lines"},
+	{"size-align-lines", "line-stats", "it doesn't measure lines"},
+	{"size-schedule", "bytes", "byte chunks already have an exact size"},
+	{"size-schedule", "vertical", "a vertical split writes one part per column group"},
+	{"size-schedule", "shuffle", "-shuffle sets the number of parts"},
+	{"size-schedule", "syslog-split", "parts are chosen by severity"},
+	{"rotate-every", "bytes", "byte chunks already have an exact size"},
+	{"rotate-every", "vertical", "a vertical split writes one part per column group"},
+	{"rotate-every", "shuffle", "-shuffle sets the number of parts"},
+	{"rotate-every", "syslog-split", "parts are chosen by severity"},
+	{"max-idle", "bytes", "byte chunks already have an exact size"},
+	{"max-idle", "vertical", "a vertical split writes one part per column group"},
+	{"max-idle", "shuffle", "-shuffle sets the number of parts"},
+	{"max-idle", "syslog-split", "parts are chosen by severity"},
+	{"max-idle", "record-begin", "a record may still be arriving"},
+	{"max-idle", "paragraph", "a paragraph may still be arriving"},
+	{"max-idle", "inline-manifest", "the last part may already be closed"},
+	{"overlap", "bytes", "byte chunks don't see lines"},
+	{"overlap", "vertical", "a vertical split writes every row to every part"},
+	{"overlap", "shuffle", "shuffled parts are not consecutive"},
+	{"overlap", "syslog-split", "severity parts are not consecutive"},
+	{"overlap", "time-field", "a time window holds only its own lines"},
+	{"inline-manifest", "bytes", "byte chunks have an exact size"},
+	{"inline-manifest", "vertical", "a vertical split has no last part"},
+	{"inline-manifest", "shuffle", "shuffled parts have no last part"},
+	{"inline-manifest", "syslog-split", "severity parts have no last part"},
+	{"reverse-index", "incremental", "parts already written can't be renumbered"},
+	{"reverse-index", "prune-empty", "pruning changes the part count after it is known"},
+	{"reverse-index", "vertical", "column groups are numbered in column order"},
+	{"reverse-index", "syslog-split", "parts are named by severity"},
+	{"reverse-index", "rotate-every", "the part count depends on timing, so it can't be known ahead"},
+	{"reverse-index", "max-idle", "the part count depends on timing, so it can't be known ahead"},
+	{"record-begin", "bytes", "byte chunks don't see lines"},
+	{"record-begin", "pattern", "-pattern would split records"},
+	{"record-begin", "vertical", "a vertical split writes every row to every part"},
+	{"record-begin", "shuffle", "-shuffle routes single lines"},
+	{"record-begin", "syslog-split", "-syslog-split routes single lines"},
+	{"record-begin", "time-field", "a time window is chosen per line"},
+	{"record-begin", "align-lines", "records end parts where they end"},
+	{"record-begin", "sample", "-sample picks single lines"},
+	{"record-begin", "fuzzy-dedupe", "-fuzzy-dedupe skips single lines"},
+	{"paragraph", "record-begin", "paragraphs are already records"},
+	{"paragraph", "bytes", "byte chunks don't see lines"},
+	{"paragraph", "pattern", "-pattern would split paragraphs"},
+	{"paragraph", "vertical", "a vertical split writes every row to every part"},
+	{"paragraph", "shuffle", "-shuffle routes single lines"},
+	{"paragraph", "syslog-split", "-syslog-split routes single lines"},
+	{"paragraph", "time-field", "a time window is chosen per line"},
+	{"paragraph", "align-lines", "paragraphs end parts where they end"},
+	{"paragraph", "sample", "-sample picks single lines"},
+	{"paragraph", "fuzzy-dedupe", "-fuzzy-dedupe skips single lines"},
+	{"time-field", "bytes", "byte chunks don't see lines"},
+	{"time-field", "vertical", "a vertical split writes one part per column group"},
+	{"time-field", "shuffle", "-shuffle sets the number of parts"},
+	{"time-field", "syslog-split", "parts are chosen by severity"},
+	{"sample", "bytes", "byte chunks don't see lines"},
+	{"sample", "vertical", "a vertical split writes every row"},
+	{"mark-partial", "rm-partial", "the incomplete part is either renamed or deleted"},
+	{"fifo", "atomic", "a pipe can't be renamed into place"},
+	{"fifo", "fsync", "a pipe has nothing to flush to disk"},
+	{"fifo", "prune-empty", "a pipe already handed on can't be taken back"},
+	{"fifo", "rm-partial", "data already read from a pipe can't be taken back"},
+	{"fifo", "mark-partial", "data already read from a pipe can't be taken back"},
+	{"append", "bytes", "byte chunks have an exact size"},
+	{"append", "vertical", "a vertical split writes one part per column group"},
+	{"append", "shuffle", "-shuffle sets the number of parts"},
+	{"append", "syslog-split", "parts are chosen by severity"},
+	{"append", "time-field", "a time window holds only its own lines"},
+	{"append", "reverse-index", "parts already written can't be renumbered"},
+	{"append", "ts", "the part to append to has no known timestamp"},
+	{"append", "ts-format", "the part to append to has no known timestamp"},
+	{"append", "ts-tz", "the part to append to has no known timestamp"},
+	{"append", "fifo", "a pipe can't be appended to"},
+	{"append", "atomic", "an existing part can't be renamed into place"},
+	{"preallocate", "fifo", "a pipe has no space to reserve"},
+	{"retry-on-error", "fifo", "data already read from a pipe can't be written again"},
+	{"sort", "incremental", "only the new lines would be sorted"},
+	{"sort", "reverse-index", "the sorted input can't be read twice"},
+	{"sort", "record-begin", "sorting would take records apart"},
+	{"sort", "paragraph", "sorting would take paragraphs apart"},
+	{"sort", "header", "the header row would be sorted in with the rest"},
+	{"sort", "max-idle", "the whole input is read before the first line is written"},
+	{"mode", "preserve-perms", "both set the permissions of parts"},
+	{"dry", "line-stats", "a dry run doesn't write lines to measure"},
+	{"count", "report", "-count writes nothing"},
+	{"count", "done-file", "-count writes nothing"},
+	{"count", "verify-file", "-count writes nothing"},
+	{"count", "incremental", "-count writes nothing"},
+	{"count", "line-stats", "-count doesn't write lines to measure"},
+	{"dry-json", "count", "both print to stdout"},
+	{"dry-json", "report", "-dry-json writes nothing"},
+	{"dry-json", "done-file", "-dry-json writes nothing"},
+	{"dry-json", "verify-file", "-dry-json writes nothing"},
+	{"dry-json", "incremental", "-dry-json writes nothing"},
+	{"dry-json", "line-stats", "a dry run doesn't write lines to measure"},
+	{"plain", "log-format", "-plain is -log-format plain"},
+	{"v", "q", "-q hides what -v logs"},
+	{"vv", "q", "-q hides what -vv logs"},
+}
+
+// flagRequires lists flags that only make sense alongside another.
+var flagRequires = []struct{ flag, needs string }{
+	{"align-lines", "lines"},
+	{"matches-per-part", "pattern"},
+	{"fixed", "pattern"},
+	{"ignore-case", "pattern"},
+	{"slug", "pattern"},
+	{"sort-memory", "sort"},
+	{"serve-max-body", "serve"},
+	{"comment-prefix", "inline-manifest"},
+	{"header", "vertical"},
+	{"vertical", "columns"},
+	{"columns", "vertical"},
+	{"incremental", "state-file"},
+	{"state-file", "incremental"},
+	{"rate-limit-burst", "rate-limit"},
+	{"similarity", "fuzzy-dedupe"},
+	{"max-line-length-action", "max-line-length"},
+	{"fuzzy-dedupe-limit", "fuzzy-dedupe"},
+	{"no-renumber", "prune-empty"},
+	{"record-begin", "record-end"},
+	{"record-end", "record-begin"},
+	{"time-field", "time-window"},
+	{"time-window", "time-field"},
+}
+
+// flagAliases maps alias flags to the flag they set, so conflicts and
+// requirements apply to both names.
+var flagAliases = map[string]string{"delim": "field-sep", "count-only": "count"}
+
+// validateFlags checks every flag value and combination in fs, reporting
+// all problems in one usage error before any file is touched.
+func validateFlags(fs *flag.FlagSet) error {
+	str := func(name string) string { return fs.Lookup(name).Value.String() }
+	num := func(name string) int { n, _ := strconv.Atoi(str(name)); return n }
+	on := func(name string) bool { return str(name) == "true" }
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+		if name, ok := flagAliases[f.Name]; ok {
+			set[name] = true
+		}
+	})
+
+	if on("version") || str("serve") != "" {
+		return nil
+	}
+
+	var problems []string
+	fail := func(format string, args ...any) { problems = append(problems, fmt.Sprintf(format, args...)) }
+
+	if str("in") == "" {
+		fail("-in is required (use - for stdin)")
+	}
+	if num("lines") == 0 && str("size") == "" && str("size-schedule") == "" && str("bytes") == "" && str("pattern") == "" && !on("vertical") && num("shuffle") == 0 && !on("syslog-split") && str("time-field") == "" && !set["rotate-every"] && !set["max-idle"] && !on("copy-ok") && !on("count") {
+		fail("no split criterion given: use -lines, -size, -size-schedule, -bytes, -pattern, -vertical, -shuffle, -syslog-split, -time-field, -rotate-every or -max-idle (or -copy-ok to copy the whole input into one part)")
+	}
+
+	for _, name := range []string{"lines", "truncate", "softwrap", "align-lines", "expect-parts", "fuzzy-dedupe-limit", "shuffle", "overlap", "pipeline-depth", "retry-on-error", "threads", "parallel-hash"} {
+		if num(name) < 0 {
+			fail("-%s must not be negative, got %d", name, num(name))
+		}
+	}
+	if n := num("matches-per-part"); n < 1 {
+		fail("-matches-per-part must be at least 1, got %d", n)
+	}
+	if n := num("pad"); n < 1 {
+		fail("-pad must be at least 1, got %d", n)
+	}
+	if a, l := num("align-lines"), num("lines"); a > 0 && a > l {
+		fail("-align-lines %d must not exceed -lines %d", a, l)
+	}
+	if set["sample"] {
+		if r, _ := strconv.ParseFloat(str("sample"), 64); r <= 0 || r > 1 {
+			fail("-sample must be above 0 and at most 1, got %g", r)
+		}
+	}
+	if set["seed"] && !set["shuffle"] && !set["sample"] {
+		fail("-seed requires -shuffle or -sample")
+	}
+	for _, name := range []string{"progress", "rotate-every", "max-idle"} {
+		if d, _ := time.ParseDuration(str(name)); d < 0 {
+			fail("-%s must not be negative, got %s", name, d)
+		}
+	}
+	if on("fuzzy-dedupe") {
+		if s, _ := strconv.ParseFloat(str("similarity"), 64); s <= 0 || s > 1 {
+			fail("-similarity must be above 0 and at most 1, got %g", s)
+		}
+	}
+
+	for _, name := range []string{"size", "bytes", "max-line", "max-line-length", "rate-limit-burst"} {
+		if _, err := parseSize(str(name)); err != nil {
+			fail("invalid -%s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"size", "bytes"} {
+		// An empty flag is unset, but 0B would copy the whole input into one part.
+		if n, err := parseSize(str(name)); err == nil && n == 0 && str(name) != "" {
+			fail("-%s must be above zero, got %s", name, str(name))
+		}
+	}
+	if n, err := parseSize(str("sort-memory")); err != nil {
+		fail("invalid -sort-memory: %v", err)
+	} else if n < splitter.MinSortMemory {
+		fail("-sort-memory must be at least 1MB, got %s", str("sort-memory"))
+	}
+	if n, err := parseSize(str("max-memory")); err != nil {
+		fail("invalid -max-memory: %v", err)
+	} else if n > 0 && n < splitter.MinMaxMemory {
+		fail("-max-memory must be at least %s, got %s", splitter.SizeString(splitter.MinMaxMemory), str("max-memory"))
+	}
+	if s := str("ts-tz"); s != "" {
+		if _, err := time.LoadLocation(s); err != nil {
+			fail("invalid -ts-tz: %v", err)
+		}
+	}
+	if n, err := parseSize(str("buffer")); err != nil {
+		fail("invalid -buffer: %v", err)
+	} else if n < 4<<10 || n > 1<<30 {
+		fail("-buffer must be between 4KB and 1GB, got %s", str("buffer"))
+	}
+	if s := str("size-schedule"); s != "" {
+		if _, _, err := parseSchedule(s); err != nil {
+			fail("invalid -size-schedule: %v", err)
+		}
+	}
+	if _, err := parseSize(strings.TrimSuffix(strings.TrimSpace(str("rate-limit")), "/s")); err != nil {
+		fail("invalid -rate-limit: %v", err)
+	}
+	for _, name := range []string{"pattern", "record-begin", "record-end"} {
+		if name == "pattern" && on("fixed") {
+			continue
+		}
+		if _, err := regexp.Compile(str(name)); err != nil {
+			fail("invalid -%s: %v", name, err)
+		}
+	}
+	if s := str("time-field"); s != "" {
+		if re, err := regexp.Compile(s); err != nil {
+			fail("invalid -time-field: %v", err)
+		} else if re.NumSubexp() < 1 {
+			fail("invalid -time-field %q: it needs a capture group around the timestamp", s)
+		}
+	}
+	if s := str("time-window"); s != "" {
+		if _, err := parseWindow(s); err != nil {
+			fail("invalid -time-window: %v", err)
+		}
+	}
+	if m := str("mode"); m != "" {
+		if n, err := strconv.ParseUint(m, 8, 32); err != nil || n > 0o777 {
+			fail("invalid -mode %q: expected octal permissions such as 0600", m)
+		}
+	}
+	if a := str("max-line-length-action"); a != "warn" && a != "error" {
+		fail("invalid -max-line-length-action %q: expected warn or error", a)
+	}
+	if d := str("decode"); d != "" && d != "base64" && d != "hex" {
+		fail("invalid -decode %q: expected base64 or hex", d)
+	}
+	if s := str("output-record-sep"); s != "" {
+		if _, err := strconv.Unquote(`"` + s + `"`); err != nil {
+			fail("invalid -output-record-sep %q: %v", s, err)
+		}
+	}
+	if s := str("inject-error"); s != "" {
+		if _, err := parseFault(s); err != nil {
+			fail("%v", err)
+		}
+	}
+	if s := str("select-columns"); s != "" {
+		if _, err := splitter.NewColumnSelector(s, str("field-sep"), str("output-field-sep")); err != nil {
+			fail("invalid -select-columns: %v", err)
+		}
+	}
+	if s := str("columns"); s != "" {
+		if _, err := splitter.NewColumnGroups(s, str("field-sep"), str("output-field-sep")); err != nil {
+			fail("invalid -columns: %v", err)
+		}
+	}
+	if on("preallocate") && str("size") == "" && str("bytes") == "" {
+		fail("-preallocate needs -size or -bytes, so the size of each part is known")
+	}
+	if on("size-align-lines") && str("size") == "" && str("size-schedule") == "" {
+		fail("-size-align-lines needs -size or -size-schedule")
+	}
+	if on("incremental") && str("in") == "-" {
+		fail("-incremental needs an input file, not stdin")
+	}
+	if isURL(str("in")) {
+		for _, name := range []string{"incremental", "preserve-perms", "merge-by-time"} {
+			if on(name) {
+				fail("-%s needs an input file, not a URL", name)
+			}
+		}
+	} else if str("http-header") != "" {
+		fail("-http-header requires -in to be an http:// or https:// URL")
+	} else if paths := strings.Split(str("in"), ","); len(paths) > 1 {
+		for _, p := range paths {
+			if p == "" || p == "-" {
+				fail("-in with several files needs a path for each, got %q", str("in"))
+				break
+			}
+		}
+		if on("incremental") {
+			fail("-incremental needs a single input file")
+		}
+	} else if on("merge-by-time") {
+		fail("-merge-by-time needs several comma-separated -in files")
+	}
+
+	for _, c := range flagConflicts {
+		if set[c.a] && set[c.b] {
+			fail("-%s and -%s cannot be used together: %s", c.a, c.b, c.why)
+		}
+	}
+	for _, r := range flagRequires {
+		if set[r.flag] && !set[r.needs] {
+			fail("-%s requires -%s", r.flag, r.needs)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return usageErrorf("invalid flags:\n  %s", strings.Join(problems, "\n  "))
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+// splitFlags returns the flag set split registers, unparsed.
+func splitFlags(t *testing.T) *flag.FlagSet {
+	t.Helper()
+	var fs *flag.FlagSet
+	collectFlags = func(f *flag.FlagSet) { fs = f }
+	defer func() { collectFlags = nil }()
+	runSplit(nil, false)
+	if fs == nil {
+		t.Fatal("split registered no flag set")
+	}
+	return fs
+}
+
+// validate parses args as split's flags and returns validateFlags' error.
+func validate(t *testing.T, args ...string) error {
+	t.Helper()
+	fs := splitFlags(t)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("parsing %v: %v", args, err)
+	}
+	return validateFlags(fs)
+}
+
+// sampleValues are valid values for the non-boolean flags in flagConflicts
+// and flagRequires. A flag added to either table needs one here.
+var sampleValues = map[string]string{
+	"lines":                  "3",
+	"max-line":               "1MB",
+	"truncate":               "80",
+	"softwrap":               "80",
+	"size":                   "1MB",
+	"bytes":                  "1MB",
+	"pattern":                "^START",
+	"select-columns":         "1",
+	"columns":                "1;2",
+	"size-schedule":          "1MB,2MB",
+	"rotate-every":           "1h",
+	"max-idle":               "1m",
+	"overlap":                "2",
+	"time-field":             `^(\S+)`,
+	"time-window":            "1h",
+	"shuffle":                "4",
+	"align-lines":            "2",
+	"matches-per-part":       "2",
+	"sort-memory":            "64MB",
+	"serve-max-body":         "1GB",
+	"serve":                  ":8080",
+	"comment-prefix":         "//",
+	"state-file":             "state.json",
+	"rate-limit":             "1MB/s",
+	"rate-limit-burst":       "1MB",
+	"similarity":             "0.8",
+	"max-line-length":        "1KB",
+	"max-line-length-action": "error",
+	"fuzzy-dedupe-limit":     "10",
+	"record-begin":           "^BEGIN",
+	"record-end":             "^END",
+	"sample":                 "0.5",
+	"output-record-sep":      `\x00`,
+	"retry-on-error":         "2",
+	"mode":                   "0600",
+	"report":                 "report.json",
+	"done-file":              "_SUCCESS",
+	"verify-file":            "SHA256SUMS",
+	"log-format":             "json",
+	"ts-format":              "unix",
+	"ts-tz":                  "UTC",
+}
+
+// flagArgs returns the arguments that set name to a valid value.
+func flagArgs(t *testing.T, fs *flag.FlagSet, name string) []string {
+	t.Helper()
+	f := fs.Lookup(name)
+	if f == nil {
+		t.Fatalf("-%s is not a flag of split", name)
+	}
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return []string{"-" + name}
+	}
+	v, ok := sampleValues[name]
+	if !ok {
+		t.Fatalf("no sample value for -%s; add one to sampleValues", name)
+	}
+	return []string{"-" + name + "=" + v}
+}
+
+// TestFlagConflicts checks that every pair in flagConflicts is rejected
+// with its reason.
+func TestFlagConflicts(t *testing.T) {
+	fs := splitFlags(t)
+	for _, c := range flagConflicts {
+		t.Run(c.a+"+"+c.b, func(t *testing.T) {
+			want := "-" + c.a + " and -" + c.b + " cannot be used together: " + c.why
+			args := append([]string{"-in", "in.txt"}, flagArgs(t, fs, c.a)...)
+			args = append(args, flagArgs(t, fs, c.b)...)
+			err := validate(t, args...)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%v: got %v, want %q", args, err, want)
+			}
+		})
+	}
+}
+
+// TestFlagRequires checks that every flag in flagRequires is rejected
+// without the flag it needs, and that the requirement is met with it.
+func TestFlagRequires(t *testing.T) {
+	fs := splitFlags(t)
+	for _, r := range flagRequires {
+		t.Run(r.flag, func(t *testing.T) {
+			want := "-" + r.flag + " requires -" + r.needs
+			args := append([]string{"-in", "in.txt", "-copy-ok"}, flagArgs(t, fs, r.flag)...)
+			if err := validate(t, args...); err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%v: got %v, want %q", args, err, want)
+			}
+			args = append(args, flagArgs(t, fs, r.needs)...)
+			if err := validate(t, args...); err != nil && strings.Contains(err.Error(), want) {
+				t.Errorf("%v: still %q", args, want)
+			}
+		})
+	}
+}
+
+// TestValidateFlags lists invalid values and combinations outside the two
+// tables, each with the problem it must report.
+func TestValidateFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string // in the error; "" for none
+	}{
+		{"valid lines", []string{"-in", "in.txt", "-lines", "3"}, ""},
+		{"valid size", []string{"-in", "in.txt", "-size", "1.5MB"}, ""},
+		{"valid copy", []string{"-in", "-", "-copy-ok"}, ""},
+		{"no input", []string{"-lines", "3"}, "-in is required"},
+		{"no criterion", []string{"-in", "in.txt"}, "no split criterion given"},
+		{"negative lines", []string{"-in", "in.txt", "-lines", "-3"}, "-lines must not be negative"},
+		{"negative overlap", []string{"-in", "in.txt", "-lines", "3", "-overlap", "-1"}, "-overlap must not be negative"},
+		{"negative threads", []string{"-in", "in.txt", "-lines", "3", "-threads", "-1"}, "-threads must not be negative"},
+		{"zero pad", []string{"-in", "in.txt", "-lines", "3", "-pad", "0"}, "-pad must be at least 1"},
+		{"zero matches per part", []string{"-in", "in.txt", "-pattern", "x", "-matches-per-part", "0"}, "-matches-per-part must be at least 1"},
+		{"align above lines", []string{"-in", "in.txt", "-lines", "3", "-align-lines", "4"}, "-align-lines 4 must not exceed -lines 3"},
+		{"invalid size", []string{"-in", "in.txt", "-size", "abc"}, "invalid -size"},
+		{"zero size", []string{"-in", "in.txt", "-size", "0B"}, "-size must be above zero, got 0B"},
+		{"zero size in KB", []string{"-in", "in.txt", "-size", "0KB"}, "-size must be above zero, got 0KB"},
+		{"zero bytes", []string{"-in", "in.txt", "-bytes", "0B"}, "-bytes must be above zero, got 0B"},
+		{"zero schedule entry", []string{"-in", "in.txt", "-size-schedule", "1MB,0B"}, "every entry must be above zero"},
+		{"invalid schedule", []string{"-in", "in.txt", "-size-schedule", "1MB,,2MB"}, "invalid -size-schedule"},
+		{"leading dot size", []string{"-in", "in.txt", "-size", ".5MB"}, "a leading digit is required"},
+		{"small sort memory", []string{"-in", "in.txt", "-lines", "3", "-sort", "-sort-memory", "100KB"}, "-sort-memory must be at least 1MB"},
+		{"small max memory", []string{"-in", "in.txt", "-lines", "3", "-max-memory", "1MB"}, "-max-memory must be at least 16 MB"},
+		{"small buffer", []string{"-in", "in.txt", "-lines", "3", "-buffer", "1KB"}, "-buffer must be between 4KB and 1GB"},
+		{"zero sample", []string{"-in", "in.txt", "-lines", "3", "-sample", "0"}, "-sample must be above 0 and at most 1"},
+		{"seed alone", []string{"-in", "in.txt", "-lines", "3", "-seed", "1"}, "-seed requires -shuffle or -sample"},
+		{"negative rotation", []string{"-in", "in.txt", "-rotate-every", "-1h"}, "-rotate-every must not be negative"},
+		{"invalid regex", []string{"-in", "in.txt", "-pattern", "["}, "invalid -pattern"},
+		{"fixed skips regex check", []string{"-in", "in.txt", "-pattern", "[", "-fixed"}, ""},
+		{"time field without group", []string{"-in", "in.txt", "-time-field", `^\S+`, "-time-window", "1h"}, "it needs a capture group"},
+		{"invalid mode", []string{"-in", "in.txt", "-lines", "3", "-mode", "999"}, "invalid -mode"},
+		{"invalid decode", []string{"-in", "in.txt", "-lines", "3", "-decode", "rot13"}, "invalid -decode"},
+		{"invalid line action", []string{"-in", "in.txt", "-lines", "3", "-max-line-length", "1KB", "-max-line-length-action", "drop"}, "invalid -max-line-length-action"},
+		{"invalid fault", []string{"-in", "in.txt", "-lines", "3", "-inject-error", "write:x"}, "invalid -inject-error"},
+		{"align lines without size", []string{"-in", "in.txt", "-bytes", "1MB", "-size-align-lines"}, "-size-align-lines needs -size or -size-schedule"},
+		{"align lines with schedule", []string{"-in", "in.txt", "-size-schedule", "1MB,2MB", "-size-align-lines"}, ""},
+		{"preallocate without size", []string{"-in", "in.txt", "-lines", "3", "-preallocate"}, "-preallocate needs -size or -bytes"},
+		{"incremental stdin", []string{"-in", "-", "-lines", "3", "-incremental", "-state-file", "s.json"}, "-incremental needs an input file"},
+		{"alias conflict", []string{"-in", "in.txt", "-count-only", "-report", "r.json"}, "-count and -report cannot be used together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(t, tt.args...)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("%v: unexpected error %v", tt.args, err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("%v: got %v, want %q", tt.args, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateFlagsReportsAll checks that every problem is reported at
+// once, not just the first.
+func TestValidateFlagsReportsAll(t *testing.T) {
+	err := validate(t, "-lines", "-1", "-pad", "0", "-size", "0B")
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, want := range []string{"-in is required", "-lines must not be negative", "-pad must be at least 1", "-size must be above zero"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%v", want, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/basemax/filesplitter/splitter"
+)
+
+// runVerify checks the parts listed in a -report file against their
+// recorded sizes and SHA-256 checksums, or those listed in a -verify-file
+// against their checksums.
+func runVerify(args []string) error {
+	fs := newFlagSet("verify")
+	cf := addCommonFlags(fs)
+	report := fs.String("report", "", "Report file written by split -report (.tsv for tab-separated)")
+	sumsFile := fs.String("verify-file", "", "Checksum file written by split -verify-file, or any sha256sum output")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := cf.setup(func() error {
+		if (*report == "") == (*sumsFile == "") {
+			return usageErrorf("exactly one of -report or -verify-file is required")
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	dir := ""
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "outdir" {
+			dir = *cf.outputDir
+		}
+	})
+
+	source, read := *report, readReport
+	if *sumsFile != "" {
+		source, read = *sumsFile, readSumsFile
+	}
+	rows, err := read(source)
+	if err != nil {
+		return &splitter.InputError{Err: err}
+	}
+	bad := 0
+	for _, r := range rows {
+		if r.pruned {
+			continue
+		}
+		path := r.name
+		if dir != "" {
+			path = filepath.Join(dir, filepath.Base(r.name))
+		}
+		if reason := checkPart(path, r); reason != "" {
+			con.emit(slog.LevelWarn, fmt.Sprintf("%s: %s", path, reason), "part mismatch", "file", path, "reason", reason)
+			bad++
+		}
+	}
+	if bad > 0 {
+		return &verifyError{msg: fmt.Sprintf("%d of %d parts failed verification", bad, len(rows))}
+	}
+	con.emit(levelSuccess, fmt.Sprintf("✅ Verified %d parts against %s", len(rows), source),
+		"verified", "parts", len(rows), "report", source)
+	return nil
+}
+
+// reportRow is the part of a report line that verify checks.
+type reportRow struct {
+	name     string
+	bytes    int64 // -1 when only the checksum is known
+	checksum string
+	pruned   bool // the part was pruned as empty and should not exist
+}
+
+// readReport parses a report written by writeReport, locating columns by
+// header so reports with line stats read the same way.
+func readReport(path string) ([]reportRow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		r.Comma = '\t'
+	}
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading header: %w", path, err)
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[h] = i
+	}
+	for _, name := range []string{"filename", "bytes", "sha256"} {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("%s: missing %q column", path, name)
+		}
+	}
+	var rows []reportRow
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		n, err := strconv.ParseInt(rec[col["bytes"]], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid bytes %q", path, rec[col["bytes"]])
+		}
+		row := reportRow{name: rec[col["filename"]], bytes: n, checksum: rec[col["sha256"]]}
+		if i, ok := col["pruned"]; ok {
+			row.pruned = rec[i] == "true"
+		}
+		rows = append(rows, row)
+	}
+}
+
+// checkPart returns why the file at path does not match r, or "".
+func checkPart(path string, r reportRow) string {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "missing"
+		}
+		return err.Error()
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err.Error()
+	}
+	if r.bytes >= 0 && n != r.bytes {
+		return fmt.Sprintf("size %d, expected %d", n, r.bytes)
+	}
+	if r.checksum != "" && hex.EncodeToString(h.Sum(nil)) != r.checksum {
+		return "checksum mismatch"
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information, set at build time with:
+//
+//	go build -ldflags "-X main.version=1.2.3 -X main.commit=$(git rev-parse --short HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version = "1.0.0-dev"
+	commit  = "dev"
+	date    = "dev"
+)
+
+// runVersion implements "version".
+func runVersion(args []string) error {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Usage: filesplitter version\n\n%s.\n", findCommand("version").summary)
+		if args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+			return errHelp
+		}
+		return usageErrorf("version takes no arguments")
+	}
+	fmt.Println(versionString())
+	return nil
+}
+
+// versionString describes this build. Without ldflags, the commit and date
+// fall back to the VCS stamp the go command embeds, when available.
+func versionString() string {
+	c, d := commit, date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && c == "dev" && len(s.Value) >= 7:
+				c = s.Value[:7]
+			case s.Key == "vcs.time" && d == "dev":
+				d = s.Value
+			}
+		}
+	}
+	return fmt.Sprintf("filesplitter %s (commit %s, built %s, %s %s/%s)",
+		version, c, d, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
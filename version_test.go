@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"version"}, {"-version"}, {"split", "-version"}} {
+		res := runCLI(t, dir, nil, args...)
+		if res.code != exitOK || !strings.HasPrefix(res.stdout, "filesplitter "+version+" (") {
+			t.Errorf("%v: exit code %d, stdout %q", args, res.code, res.stdout)
+		}
+		if res.stderr != "" {
+			t.Errorf("%v: stderr %q, want nothing", args, res.stderr)
+		}
+	}
+	if res := runCLI(t, dir, nil, "version", "extra"); res.code != exitUsage {
+		t.Errorf("version extra: exit code %d, want %d", res.code, exitUsage)
+	}
+}
+
+// TestVersionInManifests checks that the done file and -inline-manifest
+// record the version that wrote them.
+func TestVersionInManifests(t *testing.T) {
+	dir := t.TempDir()
+	input := writeInput(t, dir, 10)
+	out := filepath.Join(dir, "out")
+	res := runCLI(t, dir, nil, "split", "-in", input, "-lines", "4", "-outdir", out, "-inline-manifest", "-done-file", filepath.Join(out, "_SUCCESS"))
+	if res.code != exitOK {
+		t.Fatalf("exit code %d\nstderr: %s", res.code, res.stderr)
+	}
+	b, err := os.ReadFile(filepath.Join(out, "_SUCCESS"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var done struct{ Version string }
+	if err := json.Unmarshal(b, &done); err != nil || done.Version != version {
+		t.Errorf("done file version %q (%v), want %q", done.Version, err, version)
+	}
+	last, err := os.ReadFile(filepath.Join(out, "part003.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# filesplitter " + version + " manifest: 3 parts, 10 lines\n"; !strings.Contains(string(last), want) {
+		t.Errorf("last part has no %q:\n%s", want, last)
+	}
+}